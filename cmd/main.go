@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"forum/server/clock"
 	"forum/server/config"
+	"forum/server/metrics"
 	"forum/server/migrations"
+	"forum/server/queries"
 	"forum/server/routes"
 	"forum/server/utils"
 
@@ -27,11 +32,45 @@ func main() {
 		config.BasePath = cfg.App.BasePath
 	}
 
+	// Set up the application logger
+	logger, err := utils.NewLoggerFromConfig(cfg.Log)
+	if err != nil {
+		log.Fatal("Logger setup error:", err)
+	}
+	defer logger.Close()
+
+	// Parse all templates up front so a broken one stops the deploy
+	utils.SetTemplatesDir(cfg.Web.TemplatesDir)
+	utils.SetNavCategoriesTTL(cfg.Cache.CountTTL)
+	if err := utils.PrewarmTemplates(); err != nil {
+		log.Fatal("Template error:", err)
+	}
+
+	// Same for a missing index.html in SPA mode
+	if cfg.Web.SPAFallback {
+		if _, err := os.Stat(cfg.Web.SPAIndex); err != nil {
+			log.Fatal("SPA fallback error:", err)
+		}
+	}
+
 	// Connect to the database
 	db, err := config.Connect()
 	if err != nil {
 		log.Fatal("Database connection error:", err)
 	}
+	defer db.Close()
+
+	// --healthcheck must not change anything, so it runs before the
+	// automatic migrations below; in Docker too
+	if len(os.Args) == 2 && strings.HasPrefix(os.Args[1], "--healthcheck") {
+		if err := utils.HandleFlags(os.Args[1:], db); err != nil {
+			if !errors.Is(err, utils.ErrUnhealthy) {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Handle database setup based on environment
 	if cfg.App.BasePath != "" {
@@ -62,13 +101,30 @@ func main() {
 			return
 		}
 	}
-	
 
-	
+	// Background loops (cache and rate limiter cleanup) run until the
+	// server has shut down
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// A failed warm-up only costs the first visitors some speed
+	postQueries := queries.NewCachedPostQueryService(background, db, cfg.Cache, cfg.Content)
+	if cfg.Cache.WarmOnStart {
+		if err := postQueries.Warm(background); err != nil {
+			log.Println("Cache warm-up failed:", err)
+		}
+	}
+
+	// A broken word list would let everything through, so refuse to start
+	wordFilter, err := utils.LoadWordFilter(cfg.Moderation)
+	if err != nil {
+		log.Fatal("Banned words error:", err)
+	}
+
 	// Start the HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:      routes.Routes(db),
+		Handler:      routes.Routes(background, db, cfg, logger, postQueries, wordFilter, clock.Real),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
@@ -76,12 +132,60 @@ func main() {
 
 	// Start server in goroutine so it doesn't block
 	go func() {
-		log.Printf("Server starting on http://localhost:%d (Environment: %s)", 
-			cfg.Server.Port, cfg.App.Environment)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			log.Printf("Server starting on https://localhost:%d (Environment: %s)",
+				cfg.Server.Port, cfg.App.Environment)
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			log.Printf("Server starting on http://localhost:%d (Environment: %s)",
+				cfg.Server.Port, cfg.App.Environment)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal("Server error:", err)
 		}
-	}()	// Wait for interrupt signal (Ctrl+C or kill command)
+	}()
+
+	// Optionally send plain HTTP visitors over to HTTPS
+	var redirectServer *http.Server
+	if cfg.Server.TLSEnabled() && cfg.Server.RedirectPort > 0 {
+		redirectServer = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.Server.RedirectPort),
+			Handler:      routes.RedirectToHTTPS(cfg.Server.Port, cfg.Server.AllowedHosts),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		go func() {
+			log.Printf("Redirecting http://localhost:%d to HTTPS", cfg.Server.RedirectPort)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("Redirect server error:", err)
+			}
+		}()
+	}
+
+	// Metrics on their own port, e.g. one only reachable internally
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Port > 0 {
+		metricsMux := http.NewServeMux()
+		metricsMux.HandleFunc("/metrics", metrics.Default.Handler())
+		metricsServer = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.Metrics.Port),
+			Handler:      metricsMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		go func() {
+			log.Printf("Serving metrics on http://localhost:%d/metrics", cfg.Metrics.Port)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("Metrics server error:", err)
+			}
+		}()
+	}
+
+	// Wait for interrupt signal (Ctrl+C or kill command)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
@@ -92,8 +196,25 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			log.Println("Redirect server forced to shutdown:", err)
+		}
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Println("Metrics server forced to shutdown:", err)
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		log.Println("Server forced to shutdown:", err)
+	}
+
+	// No requests are running any more: stop the background loops and
+	// close the database so SQLite releases its locks before we exit
+	stopBackground()
+	if err := db.Close(); err != nil {
+		log.Println("Error closing database:", err)
 	}
 
 	log.Println("Server stopped gracefully")
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditPostDelete     = "post.delete"
+	AuditPostRestore    = "post.restore"
+	AuditPostLock       = "post.lock"
+	AuditPostUnlock     = "post.unlock"
+	AuditPostPin        = "post.pin"
+	AuditPostUnpin      = "post.unpin"
+	AuditCommentDelete  = "comment.delete"
+	AuditCommentRestore = "comment.restore"
+	AuditCategoryCreate = "category.create"
+	AuditCategoryRename = "category.rename"
+	AuditCategoryDelete = "category.delete"
+)
+
+// auditReasonMaxLength bounds the reason an admin gives, in characters
+const auditReasonMaxLength = 500
+
+// AuditEntry describes one privileged action: who did what to which
+// post, comment or category, and why
+type AuditEntry struct {
+	ActorID    int
+	Action     string
+	TargetType string // post, comment or category
+	TargetID   int64
+	Reason     string
+}
+
+// AuditLogger records actions taken with admin rights in the audit_log
+// table. Unlike the application log it is durable and queryable, and an
+// entry is written in the same transaction as its action, so one is never
+// committed without the other.
+type AuditLogger struct {
+	db *sql.DB
+}
+
+// NewAuditLogger creates an audit logger writing to db
+func NewAuditLogger(db *sql.DB) *AuditLogger {
+	return &AuditLogger{db: db}
+}
+
+// Record adds entry to the audit log as part of tx
+func (l *AuditLogger) Record(tx *sql.Tx, entry AuditEntry) error {
+	_, err := tx.Exec(
+		`INSERT INTO audit_log (actor_id, action, target_type, target_id, reason)
+		VALUES (?, ?, ?, ?, ?)`,
+		entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Reason,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record %s in the audit log: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// Exec runs a single-statement action and records entry with it in one
+// transaction. Nothing is recorded when the statement changes no row.
+func (l *AuditLogger) Exec(entry AuditEntry, query string, args ...interface{}) (sql.Result, error) {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows > 0 {
+		if err := l.Record(tx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// checkReason trims the reason given for a privileged action and bounds
+// its length
+func checkReason(reason *string) error {
+	*reason = strings.TrimSpace(*reason)
+	if utf8.RuneCountInString(*reason) > auditReasonMaxLength {
+		return invalid("reason", "reason must be at most %d characters", auditReasonMaxLength)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"forum/server/utils"
+)
+
+// categoryLabelMaxLength keeps labels short enough for the sidebar
+const categoryLabelMaxLength = 30
+
+// CategoryCommandHandler handles all write operations for categories.
+// Every command is restricted to admins and recorded in the audit log.
+type CategoryCommandHandler struct {
+	db    *sql.DB
+	audit *AuditLogger
+}
+
+// NewCategoryCommandHandler creates a new command handler
+func NewCategoryCommandHandler(db *sql.DB) *CategoryCommandHandler {
+	return &CategoryCommandHandler{db: db, audit: NewAuditLogger(db)}
+}
+
+// Handle processes CreateCategoryCommand (admins only)
+func (h *CategoryCommandHandler) CreateCategory(cmd CreateCategoryCommand) (*CommandResult, error) {
+	if result, err := h.requireAdmin(cmd.UserID); result != nil || err != nil {
+		return result, err
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	label, failed, err := h.checkLabel(cmd.Label, 0)
+	if failed != nil || err != nil {
+		return failed, err
+	}
+
+	slug, err := h.uniqueSlug(label, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO categories (label, slug) VALUES (?, ?)", label, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert category: %w", err)
+	}
+
+	categoryID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category ID: %w", err)
+	}
+
+	err = h.audit.Record(tx, AuditEntry{ActorID: cmd.UserID, Action: AuditCategoryCreate, TargetType: "category", TargetID: categoryID, Reason: cmd.Reason})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"category_id": categoryID,
+			"label":       label,
+			"slug":        slug,
+		},
+	}, nil
+}
+
+// Handle processes RenameCategoryCommand (admins only)
+func (h *CategoryCommandHandler) RenameCategory(cmd RenameCategoryCommand) (*CommandResult, error) {
+	if result, err := h.requireAdmin(cmd.UserID); result != nil || err != nil {
+		return result, err
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	label, failed, err := h.checkLabel(cmd.Label, cmd.CategoryID)
+	if failed != nil || err != nil {
+		return failed, err
+	}
+
+	// The slug follows the label, so /c/ links use the new name
+	slug, err := h.uniqueSlug(label, cmd.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.audit.Exec(
+		AuditEntry{ActorID: cmd.UserID, Action: AuditCategoryRename, TargetType: "category", TargetID: int64(cmd.CategoryID), Reason: cmd.Reason},
+		"UPDATE categories SET label = ?, slug = ? WHERE id = ?", label, slug, cmd.CategoryID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename category: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return failure(CodeNotFound, "", "category not found"), nil
+	}
+
+	return &CommandResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"category_id": cmd.CategoryID,
+			"label":       label,
+			"slug":        slug,
+		},
+	}, nil
+}
+
+// Handle processes DeleteCategoryCommand (admins only)
+func (h *CategoryCommandHandler) DeleteCategory(cmd DeleteCategoryCommand) (*CommandResult, error) {
+	if result, err := h.requireAdmin(cmd.UserID); result != nil || err != nil {
+		return result, err
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", cmd.CategoryID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check category: %w", err)
+	}
+	if !exists {
+		return failure(CodeNotFound, "", "category not found"), nil
+	}
+
+	var postCount int
+	err = tx.QueryRow("SELECT COUNT(*) FROM post_category WHERE category_id = ?", cmd.CategoryID).Scan(&postCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count category posts: %w", err)
+	}
+	if postCount > 0 && !cmd.Force {
+		return failure(CodeConflict, "", fmt.Sprintf("category is used by %d posts", postCount)), nil
+	}
+
+	// post_category has no ON DELETE CASCADE, so drop the links first
+	if _, err := tx.Exec("DELETE FROM post_category WHERE category_id = ?", cmd.CategoryID); err != nil {
+		return nil, fmt.Errorf("failed to unlink category posts: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", cmd.CategoryID); err != nil {
+		return nil, fmt.Errorf("failed to delete category: %w", err)
+	}
+	err = h.audit.Record(tx, AuditEntry{ActorID: cmd.UserID, Action: AuditCategoryDelete, TargetType: "category", TargetID: int64(cmd.CategoryID), Reason: cmd.Reason})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &CommandResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"category_id":    cmd.CategoryID,
+			"unlinked_posts": postCount,
+		},
+	}, nil
+}
+
+// requireAdmin returns a failed result when userID is not an admin
+func (h *CategoryCommandHandler) requireAdmin(userID int) (*CommandResult, error) {
+	isAdmin, err := userIsAdmin(h.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return failure(CodeForbidden, "", "only admins can manage categories"), nil
+	}
+	return nil, nil
+}
+
+// uniqueSlug derives a slug from label that no other category (ignoring
+// excludeID) uses yet, appending -2, -3, ... on clashes. Labels without any
+// letters or digits fall back to "category".
+func (h *CategoryCommandHandler) uniqueSlug(label string, excludeID int) (string, error) {
+	// Labels arrive HTML-escaped by the Sanitize middleware
+	base := utils.Slugify(html.UnescapeString(label))
+	if base == "" {
+		base = "category"
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken bool
+		err := h.db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM categories WHERE slug = ? AND id <> ?)",
+			slug, excludeID,
+		).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check slug: %w", err)
+		}
+		if !taken {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// checkLabel trims the label and checks its length and that no other
+// category (ignoring excludeID) already uses it, regardless of case. It
+// returns a failed result for invalid labels.
+func (h *CategoryCommandHandler) checkLabel(label string, excludeID int) (string, *CommandResult, error) {
+	label = strings.TrimSpace(label)
+	code, problem := CodeValidation, ""
+	switch {
+	case label == "":
+		problem = "label is required"
+	case len(label) > categoryLabelMaxLength:
+		problem = fmt.Sprintf("label must be at most %d characters", categoryLabelMaxLength)
+	case strings.IndexFunc(label, unicode.IsControl) >= 0:
+		// Also keeps the separator of queries.CategoryLabelsSQL out of labels
+		problem = "label must not contain control characters"
+	default:
+		var taken bool
+		err := h.db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM categories WHERE LOWER(label) = LOWER(?) AND id <> ?)",
+			label, excludeID,
+		).Scan(&taken)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to check label: %w", err)
+		}
+		if taken {
+			code, problem = CodeConflict, fmt.Sprintf("category %q already exists", label)
+		}
+	}
+
+	if problem != "" {
+		return "", failure(code, "label", problem), nil
+	}
+	return label, nil, nil
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"forum/server/utils"
+)
+
+// Registration validation errors. Their messages are shown to the user
+// as-is, so keep them short and actionable.
+var (
+	ErrEmailRequired       = errors.New("email is required")
+	ErrEmailInvalid        = errors.New("email address is not valid")
+	ErrUsernameRequired    = errors.New("username is required")
+	ErrUsernameTooShort    = errors.New("username must be at least 3 characters")
+	ErrUsernameTooLong     = errors.New("username must be at most 50 characters")
+	ErrUsernameInvalidChar = errors.New("username may only contain letters, digits, underscores and hyphens")
+	ErrUsernameReserved    = errors.New("username is reserved")
+	ErrPasswordRequired    = errors.New("password is required")
+	ErrPasswordTooShort    = errors.New("password must be at least 6 characters")
+)
+
+// ErrorCode classifies why a command was rejected, so callers can pick a
+// response without parsing the message
+type ErrorCode string
+
+const (
+	CodeValidation   ErrorCode = "validation"   // the input is invalid
+	CodeUnauthorized ErrorCode = "unauthorized" // wrong credentials
+	CodeForbidden    ErrorCode = "forbidden"    // the user may not do this
+	CodeNotFound     ErrorCode = "not_found"    // the target does not exist
+	CodeConflict     ErrorCode = "conflict"     // clashes with existing data
+)
+
+// ValidationError is an invalid input value. Field names the offending
+// command field by its JSON name.
+type ValidationError struct {
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// invalid returns a ValidationError for field with a formatted message
+func invalid(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// failure builds the result of a rejected command
+func failure(code ErrorCode, field, message string) *CommandResult {
+	return &CommandResult{
+		Success: false,
+		Error:   message,
+		Code:    code,
+		Field:   field,
+	}
+}
+
+// validationFailure turns an error from a validate method into a failed
+// result, keeping the field of a ValidationError
+func validationFailure(err error) *CommandResult {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return failure(CodeValidation, invalid.Field, invalid.Error())
+	}
+	return failure(CodeValidation, "", err.Error())
+}
+
+// APIError describes a failed result for utils.WriteJSONError. The
+// offending field, if any, carries the message under Fields.
+func (r *CommandResult) APIError() utils.APIError {
+	apiErr := utils.APIError{Code: string(r.Code), Message: r.Error}
+	if r.Field != "" {
+		apiErr.Fields = map[string]string{r.Field: r.Error}
+	}
+	return apiErr
+}
+
+// InvalidInput describes an error from an exported validator such as
+// ValidateLength or CleanContent for utils.WriteJSONError, as a
+// validation failure on the field of a ValidationError
+func InvalidInput(err error) utils.APIError {
+	return validationFailure(err).APIError()
+}
+
+// HTTPStatus returns the status code for responding with the result: 200
+// on success, otherwise the one matching its Code
+func (r *CommandResult) HTTPStatus() int {
+	if r.Success {
+		return http.StatusOK
+	}
+	switch r.Code {
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
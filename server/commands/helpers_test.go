@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"database/sql"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+// newTestPostHandler returns a post command handler over a test database
+// with the default content settings
+func newTestPostHandler(t *testing.T) (*PostCommandHandler, *sql.DB) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	return NewPostCommandHandler(db, nil, config.LoadConfig().Content, testutil.NopPublisher{}), db
+}
@@ -6,6 +6,63 @@ type CreatePostCommand struct {
 	Title       string   `json:"title"`
 	Content     string   `json:"content"`
 	CategoryIDs []int    `json:"category_ids"`
+	Draft       bool     `json:"draft"` // save without publishing
+	// ReturnDetail adds the new post as a queries.PostDetail under "post"
+	ReturnDetail bool `json:"return_detail"`
+}
+
+// PublishPostCommand represents a command to publish a draft post
+type PublishPostCommand struct {
+	UserID int `json:"user_id"`
+	PostID int `json:"post_id"`
+}
+
+// DeletePostCommand represents a command to soft-delete a post.
+// Authors can delete their own posts, admins can delete any post.
+type DeletePostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// RestorePostCommand represents an admin command to undo a post deletion
+type RestorePostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// LockPostCommand represents a command to close a post to new comments.
+// Authors can lock their own posts, admins can lock any post.
+type LockPostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// UnlockPostCommand represents a command to reopen a locked post
+type UnlockPostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// PinPostCommand represents an admin command to keep a post at the top of
+// the listings. Pins are listed by ascending Order; 0 places a newly pinned
+// post after the existing pins and leaves an already pinned one where it is.
+type PinPostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Order  int    `json:"pin_order"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// UnpinPostCommand represents an admin command to return a post to its
+// place among the others
+type UnpinPostCommand struct {
+	UserID int    `json:"user_id"`
+	PostID int    `json:"post_id"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
 }
 
 // CreateCommentCommand represents a command to add a comment
@@ -13,6 +70,48 @@ type CreateCommentCommand struct {
 	UserID  int    `json:"user_id"`
 	PostID  int    `json:"post_id"`
 	Content string `json:"content"`
+	// ReturnDetail adds the new comment as a queries.CommentDetail under "comment"
+	ReturnDetail bool `json:"return_detail"`
+}
+
+// DeleteCommentCommand represents a command to soft-delete a comment.
+// Authors can delete their own comments, admins can delete any comment.
+type DeleteCommentCommand struct {
+	UserID    int    `json:"user_id"`
+	CommentID int    `json:"comment_id"`
+	Reason    string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// RestoreCommentCommand represents an admin command to undo a comment deletion
+type RestoreCommentCommand struct {
+	UserID    int    `json:"user_id"`
+	CommentID int    `json:"comment_id"`
+	Reason    string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// CreateCategoryCommand represents an admin command to add a category
+type CreateCategoryCommand struct {
+	UserID int    `json:"user_id"`
+	Label  string `json:"label"`
+	Reason string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// RenameCategoryCommand represents an admin command to change a category label
+type RenameCategoryCommand struct {
+	UserID     int    `json:"user_id"`
+	CategoryID int    `json:"category_id"`
+	Label      string `json:"label"`
+	Reason     string `json:"reason"` // kept in the audit log when an admin acts
+}
+
+// DeleteCategoryCommand represents an admin command to remove a category.
+// A category still used by posts is only deleted, together with its links
+// to those posts, when Force is set.
+type DeleteCategoryCommand struct {
+	UserID     int    `json:"user_id"`
+	CategoryID int    `json:"category_id"`
+	Force      bool   `json:"force"`
+	Reason     string `json:"reason"` // kept in the audit log when an admin acts
 }
 
 // ReactToPostCommand represents a command to like/dislike a post
@@ -29,6 +128,12 @@ type ReactToCommentCommand struct {
 	Reaction  string `json:"reaction"` // "like" or "dislike"
 }
 
+// MarkNotificationReadCommand marks one of the user's notifications as read
+type MarkNotificationReadCommand struct {
+	UserID         int `json:"user_id"`
+	NotificationID int `json:"notification_id"`
+}
+
 // RegisterUserCommand represents a command to register a new user
 type RegisterUserCommand struct {
 	Email    string `json:"email"`
@@ -40,11 +145,23 @@ type RegisterUserCommand struct {
 type LoginCommand struct {
 	EmailOrUsername string `json:"email_or_username"`
 	Password        string `json:"password"`
+	RememberMe      bool   `json:"remember_me"` // long fixed session instead of a sliding one
+}
+
+// DeleteAccountCommand deletes the user's own account. Password must be
+// the account's current password.
+type DeleteAccountCommand struct {
+	UserID   int    `json:"user_id"`
+	Password string `json:"password"`
 }
 
-// CommandResult represents the result of a command execution
+// CommandResult represents the result of a command execution. A rejected
+// command has Success false, a message in Error, a Code saying why and,
+// for invalid input, the Field at fault.
 type CommandResult struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    ErrorCode   `json:"code,omitempty"`
+	Field   string      `json:"field,omitempty"`
 }
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// NotificationCommandHandler handles write operations on a user's
+// notifications. Notifications themselves are created as a side effect of
+// comments and reactions (see models.NotifyPostComment and friends).
+type NotificationCommandHandler struct {
+	db *sql.DB
+}
+
+// NewNotificationCommandHandler creates a new command handler
+func NewNotificationCommandHandler(db *sql.DB) *NotificationCommandHandler {
+	return &NotificationCommandHandler{db: db}
+}
+
+// Handle processes MarkNotificationReadCommand. Marking an already read
+// notification again succeeds and keeps the original read time; someone
+// else's notification is reported as not found.
+func (h *NotificationCommandHandler) MarkNotificationRead(cmd MarkNotificationReadCommand) (*CommandResult, error) {
+	if cmd.UserID <= 0 || cmd.NotificationID <= 0 {
+		return failure(CodeValidation, "notification_id", "invalid notification"), nil
+	}
+
+	result, err := h.db.Exec(
+		"UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP) WHERE id = ? AND user_id = ?",
+		cmd.NotificationID, cmd.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return failure(CodeNotFound, "", "notification not found"), nil
+	}
+
+	return &CommandResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"notification_id": cmd.NotificationID,
+		},
+	}, nil
+}
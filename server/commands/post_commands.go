@@ -1,31 +1,49 @@
 package commands
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"forum/server/config"
 	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/realtime"
+	"forum/server/utils"
 )
 
 // PostCommandHandler handles all write operations for posts
 type PostCommandHandler struct {
-	db *sql.DB
+	db         *sql.DB
+	wordFilter *utils.WordFilter
+	content    config.ContentConfig
+	events     realtime.Publisher
+	audit      *AuditLogger
 }
 
-// NewPostCommandHandler creates a new command handler
-func NewPostCommandHandler(db *sql.DB) *PostCommandHandler {
-	return &PostCommandHandler{db: db}
+// NewPostCommandHandler creates a new command handler.
+// wordFilter may be nil to disable banned-word filtering, and events may be
+// nil to disable live updates for new comments and reactions.
+func NewPostCommandHandler(db *sql.DB, wordFilter *utils.WordFilter, content config.ContentConfig, events realtime.Publisher) *PostCommandHandler {
+	return &PostCommandHandler{db: db, wordFilter: wordFilter, content: content, events: events, audit: NewAuditLogger(db)}
+}
+
+// readBack loads what a command has written, so a client can render it
+// without a second request
+func (h *PostCommandHandler) readBack() *queries.PostQueryService {
+	return queries.NewPostQueryService(h.db, h.content)
 }
 
 // Handle processes CreatePostCommand
 func (h *PostCommandHandler) CreatePost(cmd CreatePostCommand) (*CommandResult, error) {
 	// Validation
-	if err := h.validateCreatePost(cmd); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+	if err := h.validateCreatePost(&cmd); err != nil {
+		return validationFailure(err), nil
 	}
 
 	// Start transaction
@@ -35,10 +53,15 @@ func (h *PostCommandHandler) CreatePost(cmd CreatePostCommand) (*CommandResult,
 	}
 	defer tx.Rollback()
 
-	// Create post
+	// Create post; drafts get their publish timestamp in PublishPost
+	status := "published"
+	if cmd.Draft {
+		status = "draft"
+	}
 	result, err := tx.Exec(
-		"INSERT INTO posts (user_id, title, content) VALUES (?, ?, ?)",
-		cmd.UserID, cmd.Title, cmd.Content,
+		`INSERT INTO posts (user_id, title, content, status, published_at)
+		VALUES (?, ?, ?, ?, CASE WHEN ? = 'published' THEN CURRENT_TIMESTAMP END)`,
+		cmd.UserID, cmd.Title, cmd.Content, status, status,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert post: %w", err)
@@ -49,14 +72,14 @@ func (h *PostCommandHandler) CreatePost(cmd CreatePostCommand) (*CommandResult,
 		return nil, fmt.Errorf("failed to get post ID: %w", err)
 	}
 
-	// Link categories
+	// Link categories. One deleted since validateCreatePost checked it
+	// fails the command, and the deferred rollback drops the post too.
 	for _, categoryID := range cmd.CategoryIDs {
-		_, err := tx.Exec(
-			"INSERT INTO post_category (post_id, category_id) VALUES (?, ?)",
-			postID, categoryID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to link category %d: %w", categoryID, err)
+		if err := models.LinkPostCategory(tx, postID, categoryID); err != nil {
+			if errors.Is(err, models.ErrCategoryNotFound) {
+				return failure(CodeValidation, "category_ids", fmt.Sprintf("category %d does not exist", categoryID)), nil
+			}
+			return nil, err
 		}
 	}
 
@@ -65,245 +88,773 @@ func (h *PostCommandHandler) CreatePost(cmd CreatePostCommand) (*CommandResult,
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	data := map[string]interface{}{
+		"post_id": postID,
+		"status":  status,
+	}
+	// The post is stored; if it cannot be read back the client still
+	// gets its ID
+	if cmd.ReturnDetail {
+		post, err := h.readBack().GetPostByID(context.Background(), int(postID), cmd.UserID, queries.DefaultCommentSort)
+		if err != nil {
+			log.Println("Error loading the created post:", err)
+		} else {
+			data["post"] = post
+		}
+	}
+
+	return &CommandResult{
+		Success: true,
+		Data:    data,
+	}, nil
+}
+
+// Handle processes PublishPostCommand
+func (h *PostCommandHandler) PublishPost(cmd PublishPostCommand) (*CommandResult, error) {
+	result, err := h.db.Exec(
+		`UPDATE posts SET status = 'published', published_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND status = 'draft' AND deleted_at IS NULL`,
+		cmd.PostID, cmd.UserID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish post: %w", err)
+	}
+
+	return h.affectedResult(result, "draft not found", map[string]interface{}{
+		"post_id": cmd.PostID,
+	})
+}
+
+// Handle processes DeletePostCommand. The row is kept with deleted_at set,
+// which hides the post and its comments from every read query.
+func (h *PostCommandHandler) DeletePost(cmd DeletePostCommand) (*CommandResult, error) {
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.execAudited(isAdmin,
+		AuditEntry{ActorID: cmd.UserID, Action: AuditPostDelete, TargetType: "post", TargetID: int64(cmd.PostID), Reason: cmd.Reason},
+		`UPDATE posts SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL AND (user_id = ? OR ?)`,
+		cmd.PostID, cmd.UserID, isAdmin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete post: %w", err)
+	}
+
+	return h.affectedResult(result, "post not found", map[string]interface{}{
+		"post_id": cmd.PostID,
+	})
+}
+
+// Handle processes RestorePostCommand (admins only)
+func (h *PostCommandHandler) RestorePost(cmd RestorePostCommand) (*CommandResult, error) {
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return failure(CodeForbidden, "", "only admins can restore posts"), nil
+	}
+
+	result, err := h.audit.Exec(
+		AuditEntry{ActorID: cmd.UserID, Action: AuditPostRestore, TargetType: "post", TargetID: int64(cmd.PostID), Reason: cmd.Reason},
+		"UPDATE posts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL",
+		cmd.PostID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore post: %w", err)
+	}
+
+	return h.affectedResult(result, "deleted post not found", map[string]interface{}{
+		"post_id": cmd.PostID,
+	})
+}
+
+// Handle processes LockPostCommand. Existing comments stay visible and the
+// post can still be reacted to; only new comments are refused.
+func (h *PostCommandHandler) LockPost(cmd LockPostCommand) (*CommandResult, error) {
+	return h.setLocked(cmd.UserID, cmd.PostID, true, cmd.Reason)
+}
+
+// Handle processes UnlockPostCommand
+func (h *PostCommandHandler) UnlockPost(cmd UnlockPostCommand) (*CommandResult, error) {
+	return h.setLocked(cmd.UserID, cmd.PostID, false, cmd.Reason)
+}
+
+// setLocked opens or closes a post to comments for its author or an admin
+func (h *PostCommandHandler) setLocked(userID, postID int, locked bool, reason string) (*CommandResult, error) {
+	if err := checkReason(&reason); err != nil {
+		return validationFailure(err), nil
+	}
+	isAdmin, err := h.isAdmin(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := AuditPostUnlock
+	if locked {
+		action = AuditPostLock
+	}
+	result, err := h.execAudited(isAdmin,
+		AuditEntry{ActorID: userID, Action: action, TargetType: "post", TargetID: int64(postID), Reason: reason},
+		`UPDATE posts SET locked = ?
+		WHERE id = ? AND deleted_at IS NULL AND (user_id = ? OR ?)`,
+		locked, postID, userID, isAdmin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock post: %w", err)
+	}
+
+	return h.affectedResult(result, "post not found", map[string]interface{}{
+		"post_id": postID,
+		"locked":  locked,
+	})
+}
+
+// Handle processes PinPostCommand (admins only). Only published posts can
+// be pinned, and at most content.MaxPinnedPosts at once; re-pinning a post
+// just moves it.
+func (h *PostCommandHandler) PinPost(cmd PinPostCommand) (*CommandResult, error) {
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return failure(CodeForbidden, "", "only admins can pin posts"), nil
+	}
+	if cmd.Order < 0 {
+		return failure(CodeValidation, "pin_order", "pin order cannot be negative"), nil
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	// The limit is checked in the same statement as the pin, so two admins
+	// pinning at once cannot both take the last slot.
+	result, err := h.audit.Exec(
+		AuditEntry{ActorID: cmd.UserID, Action: AuditPostPin, TargetType: "post", TargetID: int64(cmd.PostID), Reason: cmd.Reason},
+		`UPDATE posts SET pinned = 1, pin_order = CASE
+			WHEN ? > 0 THEN ?
+			WHEN pinned = 1 THEN pin_order
+			ELSE (SELECT COALESCE(MAX(pin_order), 0) + 1 FROM posts WHERE pinned = 1)
+		END
+		WHERE id = ? AND status = 'published' AND deleted_at IS NULL
+		AND (? <= 0 OR pinned = 1
+			OR (SELECT COUNT(*) FROM posts WHERE pinned = 1 AND deleted_at IS NULL) < ?)`,
+		cmd.Order, cmd.Order, cmd.PostID, h.content.MaxPinnedPosts, h.content.MaxPinnedPosts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin post: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		var exists bool
+		err := h.db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL)",
+			cmd.PostID,
+		).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up post: %w", err)
+		}
+		if !exists {
+			return failure(CodeNotFound, "", "post not found"), nil
+		}
+		return failure(CodeConflict, "", fmt.Sprintf("at most %d posts can be pinned", h.content.MaxPinnedPosts)), nil
+	}
+
 	return &CommandResult{
 		Success: true,
 		Data: map[string]interface{}{
-			"post_id": postID,
+			"post_id": cmd.PostID,
+			"pinned":  true,
 		},
 	}, nil
 }
 
+// Handle processes UnpinPostCommand (admins only)
+func (h *PostCommandHandler) UnpinPost(cmd UnpinPostCommand) (*CommandResult, error) {
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return failure(CodeForbidden, "", "only admins can unpin posts"), nil
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	result, err := h.audit.Exec(
+		AuditEntry{ActorID: cmd.UserID, Action: AuditPostUnpin, TargetType: "post", TargetID: int64(cmd.PostID), Reason: cmd.Reason},
+		"UPDATE posts SET pinned = 0, pin_order = 0 WHERE id = ? AND pinned = 1",
+		cmd.PostID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpin post: %w", err)
+	}
+
+	return h.affectedResult(result, "pinned post not found", map[string]interface{}{
+		"post_id": cmd.PostID,
+		"pinned":  false,
+	})
+}
+
 // Handle processes CreateCommentCommand
 func (h *PostCommandHandler) CreateComment(cmd CreateCommentCommand) (*CommandResult, error) {
 	// Validation
-	if err := h.validateCreateComment(cmd); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+	if err := h.validateCreateComment(&cmd); err != nil {
+		return validationFailure(err), nil
 	}
 
-	// Verify post exists
-	var postExists bool
-	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = ?)", cmd.PostID).Scan(&postExists)
+	// Verify post exists and is open for comments
+	locked, err := models.IsPostLocked(h.db, cmd.PostID)
+	if errors.Is(err, models.ErrPostNotFound) {
+		return failure(CodeNotFound, "post_id", "post not found"), nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to check post existence: %w", err)
+		return nil, err
 	}
-	if !postExists {
-		return &CommandResult{
-			Success: false,
-			Error:   "post not found",
-		}, nil
+	if locked {
+		return failure(CodeForbidden, "post_id", "comments are closed on this post"), nil
 	}
 
-	// Insert comment
-	result, err := h.db.Exec(
-		"INSERT INTO comments (user_id, post_id, content) VALUES (?, ?, ?)",
-		cmd.UserID, cmd.PostID, cmd.Content,
-	)
+	repeated, err := models.IsRepeatComment(h.db, cmd.UserID, cmd.PostID, cmd.Content, h.content.DuplicateCommentWindow)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert comment: %w", err)
+		return nil, err
+	}
+	if repeated {
+		return failure(CodeConflict, "content", "you just posted that"), nil
 	}
 
-	commentID, err := result.LastInsertId()
+	// Insert comment
+	commentID, err := models.InsertComment(h.db, cmd.UserID, cmd.PostID, cmd.Content, h.content.MaxCommentsPerPost)
+	if errors.Is(err, models.ErrCommentLimit) {
+		return failure(CodeForbidden, "post_id", fmt.Sprintf("this post has reached the limit of %d comments", h.content.MaxCommentsPerPost)), nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comment ID: %w", err)
+		return nil, err
+	}
+
+	// The comment is stored; a failed notification is only logged
+	if err := models.NotifyPostComment(h.db, cmd.UserID, cmd.PostID, commentID); err != nil {
+		log.Println("Notification error:", err)
+	}
+	h.publishComment(cmd.PostID, commentID)
+
+	data := map[string]interface{}{
+		"comment_id": commentID,
+	}
+	if cmd.ReturnDetail {
+		comment, err := h.readBack().GetCommentByID(context.Background(), int(commentID), cmd.UserID)
+		if err != nil {
+			log.Println("Error loading the created comment:", err)
+		} else {
+			data["comment"] = comment
+		}
 	}
 
 	return &CommandResult{
 		Success: true,
-		Data: map[string]interface{}{
-			"comment_id": commentID,
-		},
+		Data:    data,
 	}, nil
 }
 
+// Handle processes DeleteCommentCommand
+func (h *PostCommandHandler) DeleteComment(cmd DeleteCommentCommand) (*CommandResult, error) {
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.execAudited(isAdmin,
+		AuditEntry{ActorID: cmd.UserID, Action: AuditCommentDelete, TargetType: "comment", TargetID: int64(cmd.CommentID), Reason: cmd.Reason},
+		`UPDATE comments SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL AND (user_id = ? OR ?)`,
+		cmd.CommentID, cmd.UserID, isAdmin,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	return h.affectedResult(result, "comment not found", map[string]interface{}{
+		"comment_id": cmd.CommentID,
+	})
+}
+
+// Handle processes RestoreCommentCommand (admins only)
+func (h *PostCommandHandler) RestoreComment(cmd RestoreCommentCommand) (*CommandResult, error) {
+	isAdmin, err := h.isAdmin(cmd.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return failure(CodeForbidden, "", "only admins can restore comments"), nil
+	}
+	if err := checkReason(&cmd.Reason); err != nil {
+		return validationFailure(err), nil
+	}
+
+	result, err := h.audit.Exec(
+		AuditEntry{ActorID: cmd.UserID, Action: AuditCommentRestore, TargetType: "comment", TargetID: int64(cmd.CommentID), Reason: cmd.Reason},
+		"UPDATE comments SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL",
+		cmd.CommentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore comment: %w", err)
+	}
+
+	return h.affectedResult(result, "deleted comment not found", map[string]interface{}{
+		"comment_id": cmd.CommentID,
+	})
+}
+
 // Handle processes ReactToPostCommand
 func (h *PostCommandHandler) ReactToPost(cmd ReactToPostCommand) (*CommandResult, error) {
 	// Validation
 	if err := h.validateReaction(cmd.Reaction); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return validationFailure(err), nil
+	}
+
+	return h.toggleReaction(reactionTarget{"post_reactions", "post_id", "posts", "post", reactablePostSQL, models.NotifyPostReaction}, cmd.UserID, cmd.PostID, cmd.Reaction)
+}
+
+// Handle processes ReactToCommentCommand
+func (h *PostCommandHandler) ReactToComment(cmd ReactToCommentCommand) (*CommandResult, error) {
+	// Validation
+	if err := h.validateReaction(cmd.Reaction); err != nil {
+		return validationFailure(err), nil
 	}
 
-	// Check if reaction already exists
-	var existingReaction sql.NullString
-	err := h.db.QueryRow(
-		"SELECT reaction FROM post_reactions WHERE user_id = ? AND post_id = ?",
-		cmd.UserID, cmd.PostID,
-	).Scan(&existingReaction)
+	return h.toggleReaction(reactionTarget{"comment_reactions", "comment_id", "comments", "comment", reactableCommentSQL, models.NotifyCommentReaction}, cmd.UserID, cmd.CommentID, cmd.Reaction)
+}
+
+// reactionTarget names the tables behind a reactable item. The values are
+// fixed in code and never come from user input.
+type reactionTarget struct {
+	table  string // reactions table, e.g. post_reactions
+	column string // its foreign key column, e.g. post_id
+	parent string // the reacted-to table, e.g. posts
+	noun   string // used in error messages
+	exists string // selects whether the target, by ID, can be reacted to
+	notify func(db *sql.DB, actorID, id int, reaction string) error
+}
 
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to check existing reaction: %w", err)
+// Queries for reactionTarget.exists: published, undeleted posts and
+// undeleted comments on undeleted posts
+const (
+	reactablePostSQL = `SELECT EXISTS(
+		SELECT 1 FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL)`
+	reactableCommentSQL = `SELECT EXISTS(
+		SELECT 1 FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND c.deleted_at IS NULL AND p.deleted_at IS NULL)`
+)
+
+// toggleReaction adds, switches or removes a user's reaction and returns the
+// new counts. Clicking the same reaction twice removes it.
+//
+// The whole toggle runs in one transaction whose first statement is a write,
+// so SQLite takes the write lock up front: concurrent toggles by the same
+// user queue behind each other (up to the driver's busy timeout) instead of
+// both reading "no reaction" and both adding one.
+func (h *PostCommandHandler) toggleReaction(t reactionTarget, userID, targetID int, reaction string) (*CommandResult, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
+
+	data := map[string]interface{}{}
 
 	// If same reaction, remove it (toggle off)
-	if existingReaction.Valid && existingReaction.String == cmd.Reaction {
-		_, err := h.db.Exec(
-			"DELETE FROM post_reactions WHERE user_id = ? AND post_id = ?",
-			cmd.UserID, cmd.PostID,
-		)
+	result, err := tx.Exec(
+		"DELETE FROM "+t.table+" WHERE user_id = ? AND "+t.column+" = ? AND reaction = ?",
+		userID, targetID, reaction,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check removed reaction: %w", err)
+	}
+
+	// Checked after the first write so the transaction holds the write
+	// lock; the rollback undoes the delete
+	var found bool
+	if err := tx.QueryRow(t.exists, targetID).Scan(&found); err != nil {
+		return nil, fmt.Errorf("failed to check %s: %w", t.noun, err)
+	}
+	if !found {
+		return failure(CodeNotFound, t.column, t.noun+" not found"), nil
+	}
+
+	if removed > 0 {
+		data["action"] = "removed"
+	} else {
+		if h.content.SelfReactions == config.SelfReactionsForbid {
+			own, err := isAuthor(tx, t.parent, targetID, userID)
+			if err != nil {
+				return nil, err
+			}
+			if own {
+				return failure(CodeForbidden, "", "you cannot react to your own "+t.noun), nil
+			}
+		}
+
+		// Upsert reaction (insert or switch like <-> dislike)
+		_, err = tx.Exec(`
+			INSERT INTO `+t.table+` (user_id, `+t.column+`, reaction)
+			VALUES (?, ?, ?)
+			ON CONFLICT(user_id, `+t.column+`) DO UPDATE SET reaction = ?
+		`, userID, targetID, reaction, reaction)
 		if err != nil {
-			return nil, fmt.Errorf("failed to remove reaction: %w", err)
+			return nil, fmt.Errorf("failed to upsert reaction: %w", err)
 		}
-		return &CommandResult{
-			Success: true,
-			Data: map[string]interface{}{
-				"action": "removed",
-			},
-		}, nil
+		data["action"] = "added"
+		data["reaction"] = reaction
 	}
 
-	// Upsert reaction (insert or update)
-	_, err = h.db.Exec(`
-		INSERT INTO post_reactions (user_id, post_id, reaction)
-		VALUES (?, ?, ?)
-		ON CONFLICT(user_id, post_id) DO UPDATE SET reaction = ?
-	`, cmd.UserID, cmd.PostID, cmd.Reaction, cmd.Reaction)
+	// Count inside the transaction so the numbers match this change
+	if err := reactionCounts(tx, t, targetID, h.content.ExcludeSelfReactions(), data); err != nil {
+		return nil, err
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to upsert reaction: %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit reaction: %w", err)
+	}
+
+	// Tell the owner about new and switched reactions. This happens after
+	// the commit and never fails the toggle.
+	if data["action"] == "added" {
+		if err := t.notify(h.db, userID, targetID, reaction); err != nil {
+			log.Println("Notification error:", err)
+		}
 	}
+	h.publishReaction(t, targetID, data)
 
 	return &CommandResult{
 		Success: true,
-		Data: map[string]interface{}{
-			"action":   "added",
-			"reaction": cmd.Reaction,
-		},
+		Data:    data,
 	}, nil
 }
 
-// Handle processes ReactToCommentCommand
-func (h *PostCommandHandler) ReactToComment(cmd ReactToCommentCommand) (*CommandResult, error) {
-	// Validation
-	if err := h.validateReaction(cmd.Reaction); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+// publishComment sends a new comment to the live readers of its post.
+// Like notifications, this never fails the command.
+func (h *PostCommandHandler) publishComment(postID int, commentID int64) {
+	if h.events == nil {
+		return
+	}
+
+	comment := realtime.CommentData{ID: commentID}
+	err := h.db.QueryRow(`
+		SELECT c.user_id, u.username, c.content, strftime('%m/%d/%Y %I:%M %p', c.created_at),
+			(SELECT COUNT(*) FROM comments WHERE post_id = c.post_id AND deleted_at IS NULL)
+		FROM comments c
+		INNER JOIN users u ON c.user_id = u.id
+		WHERE c.id = ?`, commentID,
+	).Scan(&comment.AuthorID, &comment.AuthorUsername, &comment.Content, &comment.CreatedAt, &comment.CommentCount)
+	if err != nil {
+		log.Println("Live update error:", err)
+		return
 	}
 
-	// Check if reaction already exists
-	var existingReaction sql.NullString
-	err := h.db.QueryRow(
-		"SELECT reaction FROM comment_reactions WHERE user_id = ? AND comment_id = ?",
-		cmd.UserID, cmd.CommentID,
-	).Scan(&existingReaction)
+	h.events.Publish(realtime.Event{Type: realtime.EventComment, PostID: postID, Data: comment})
+}
 
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to check existing reaction: %w", err)
+// publishReaction sends the new counts of a post or comment to the live
+// readers of the post
+func (h *PostCommandHandler) publishReaction(t reactionTarget, targetID int, data map[string]interface{}) {
+	if h.events == nil {
+		return
 	}
 
-	// If same reaction, remove it (toggle off)
-	if existingReaction.Valid && existingReaction.String == cmd.Reaction {
-		_, err := h.db.Exec(
-			"DELETE FROM comment_reactions WHERE user_id = ? AND comment_id = ?",
-			cmd.UserID, cmd.CommentID,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to remove reaction: %w", err)
+	event := realtime.Event{Type: realtime.EventPostReaction, PostID: targetID}
+	if t.parent == "comments" {
+		event.Type = realtime.EventCommentReaction
+		if err := h.db.QueryRow("SELECT post_id FROM comments WHERE id = ?", targetID).Scan(&event.PostID); err != nil {
+			log.Println("Live update error:", err)
+			return
 		}
-		return &CommandResult{
-			Success: true,
-			Data: map[string]interface{}{
-				"action": "removed",
-			},
-		}, nil
 	}
+	event.Data = realtime.ReactionData{
+		ID:           targetID,
+		LikeCount:    data["like_count"].(int),
+		DislikeCount: data["dislike_count"].(int),
+	}
+
+	h.events.Publish(event)
+}
+
+// reactionCounts stores the current like and dislike counts of one post or
+// comment in data, leaving out the author's own reaction when excludeSelf is
+// set.
+func reactionCounts(tx *sql.Tx, t reactionTarget, id int, excludeSelf bool, data map[string]interface{}) error {
+	notSelf := ""
+	if excludeSelf {
+		notSelf = " AND r.user_id <> t.user_id"
+	}
+
+	var likes, dislikes int
+	err := tx.QueryRow(`
+		SELECT
+			COUNT(CASE WHEN r.reaction = 'like'`+notSelf+` THEN 1 END),
+			COUNT(CASE WHEN r.reaction = 'dislike'`+notSelf+` THEN 1 END)
+		FROM `+t.table+` r
+		INNER JOIN `+t.parent+` t ON t.id = r.`+t.column+`
+		WHERE r.`+t.column+` = ?
+	`, id).Scan(&likes, &dislikes)
+	if err != nil {
+		return fmt.Errorf("failed to count reactions: %w", err)
+	}
+	data["like_count"] = likes
+	data["dislike_count"] = dislikes
+	return nil
+}
+
+// isAuthor reports whether userID wrote the row with the given id in table
+// (posts or comments). A missing row is not an error here.
+func isAuthor(tx *sql.Tx, table string, id, userID int) (bool, error) {
+	var authorID int
+	err := tx.QueryRow("SELECT user_id FROM "+table+" WHERE id = ?", id).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up author: %w", err)
+	}
+	return authorID == userID, nil
+}
+
+// execAudited runs a single-statement action, recording entry in the audit
+// log with it when the actor used admin rights
+func (h *PostCommandHandler) execAudited(asAdmin bool, entry AuditEntry, query string, args ...interface{}) (sql.Result, error) {
+	if asAdmin {
+		return h.audit.Exec(entry, query, args...)
+	}
+	return h.db.Exec(query, args...)
+}
+
+// isAdmin reports whether the user has the admin role
+func (h *PostCommandHandler) isAdmin(userID int) (bool, error) {
+	return userIsAdmin(h.db, userID)
+}
 
-	// Upsert reaction
-	_, err = h.db.Exec(`
-		INSERT INTO comment_reactions (user_id, comment_id, reaction)
-		VALUES (?, ?, ?)
-		ON CONFLICT(user_id, comment_id) DO UPDATE SET reaction = ?
-	`, cmd.UserID, cmd.CommentID, cmd.Reaction, cmd.Reaction)
+// userIsAdmin reports whether the user has the admin role; unknown users
+// are not admins
+func userIsAdmin(db *sql.DB, userID int) (bool, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check user role: %w", err)
+	}
+	return role == "admin", nil
+}
 
+// affectedResult turns the outcome of a single-row UPDATE into a CommandResult,
+// failing with notFound when no row matched.
+func (h *PostCommandHandler) affectedResult(result sql.Result, notFound string, data map[string]interface{}) (*CommandResult, error) {
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to upsert reaction: %w", err)
+		return nil, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return failure(CodeNotFound, "", notFound), nil
 	}
 
 	return &CommandResult{
 		Success: true,
-		Data: map[string]interface{}{
-			"action":   "added",
-			"reaction": cmd.Reaction,
-		},
+		Data:    data,
 	}, nil
 }
 
 // Validation methods
 
-func (h *PostCommandHandler) validateCreatePost(cmd CreatePostCommand) error {
+func (h *PostCommandHandler) validateCreatePost(cmd *CreatePostCommand) error {
 	if cmd.UserID <= 0 {
-		return fmt.Errorf("invalid user ID")
+		return invalid("user_id", "invalid user ID")
+	}
+	if err := CleanContent("title", "title", &cmd.Title, h.content.InvisibleChars); err != nil {
+		return err
 	}
-	
+	if err := CleanContent("content", "content", &cmd.Content, h.content.InvisibleChars); err != nil {
+		return err
+	}
+
 	title := strings.TrimSpace(cmd.Title)
 	if title == "" {
-		return fmt.Errorf("title is required")
+		return invalid("title", "title is required")
 	}
-	if len(title) < 3 {
-		return fmt.Errorf("title must be at least 3 characters")
-	}
-	if len(title) > 200 {
-		return fmt.Errorf("title must be less than 200 characters")
+	if err := ValidateLength("title", "title", title, h.content.TitleMinLength, h.content.TitleMaxLength); err != nil {
+		return err
 	}
 
 	content := strings.TrimSpace(cmd.Content)
 	if content == "" {
-		return fmt.Errorf("content is required")
+		return invalid("content", "content is required")
 	}
-	if len(content) < 10 {
-		return fmt.Errorf("content must be at least 10 characters")
+	if err := ValidateLength("content", "content", content, h.content.PostMinLength, h.content.PostMaxLength); err != nil {
+		return err
 	}
 
-	if len(cmd.CategoryIDs) == 0 {
-		return fmt.Errorf("at least one category is required")
+	if err := h.filterBannedWords(&cmd.Title, "title", "title"); err != nil {
+		return err
+	}
+	if err := h.filterBannedWords(&cmd.Content, "content", "content"); err != nil {
+		return err
 	}
 
-	// Verify categories exist
-	for _, catID := range cmd.CategoryIDs {
-		var exists bool
-		err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", catID).Scan(&exists)
-		if err != nil {
-			return fmt.Errorf("failed to verify category %d: %w", catID, err)
-		}
-		if !exists {
-			return fmt.Errorf("category %d does not exist", catID)
+	cmd.CategoryIDs = DedupeCategoryIDs(cmd.CategoryIDs)
+	if err := ValidateCategoryIDs(cmd.CategoryIDs, h.content.MaxCategoriesPerPost); err != nil {
+		return err
+	}
+
+	// Verify categories exist, naming every unknown one
+	missing, err := models.MissingCategories(h.db, cmd.CategoryIDs)
+	if err != nil {
+		return fmt.Errorf("failed to verify categories: %w", err)
+	}
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return invalid("category_ids", "category %d does not exist", missing[0])
+	default:
+		return invalid("category_ids", "categories %s do not exist", joinIDs(missing))
+	}
+}
+
+// joinIDs formats ids as a comma separated list
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ValidateCategoryIDs checks that a post has between one and max categories.
+// A max of zero or less means no limit. Run DedupeCategoryIDs first so a
+// repeated category is not counted twice.
+func ValidateCategoryIDs(ids []int, max int) error {
+	if len(ids) == 0 {
+		return invalid("category_ids", "at least one category is required")
+	}
+	if max > 0 && len(ids) > max {
+		return invalid("category_ids", "a post can have at most %d categories", max)
+	}
+	return nil
+}
+
+// DedupeCategoryIDs drops repeated category IDs, as a buggy multi-select
+// may send, keeping the first occurrence of each in order
+func DedupeCategoryIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
 		}
 	}
+	return unique
+}
+
+// CleanContent rejects text that is not valid UTF-8 and strips the
+// invisible characters from it in place, or rejects it when policy is
+// config.InvisibleCharsReject. It runs before the length checks so they
+// count only characters a reader can see.
+func CleanContent(field, noun string, text *string, policy string) error {
+	cleaned, found, err := utils.CleanText(*text)
+	if err != nil {
+		return invalid(field, "%s is not valid UTF-8", noun)
+	}
+	if found && policy == config.InvisibleCharsReject {
+		return invalid(field, "%s contains invisible or control characters", noun)
+	}
+	*text = cleaned
+	return nil
+}
 
+// ValidateLength checks that text has between min and max characters,
+// counting runes rather than bytes. A max of zero or less means no limit.
+func ValidateLength(field, noun, text string, min, max int) error {
+	n := utf8.RuneCountInString(text)
+	if n < min {
+		return invalid(field, "%s must be at least %d characters", noun, min)
+	}
+	if max > 0 && n > max {
+		return invalid(field, "%s must be at most %d characters", noun, max)
+	}
 	return nil
 }
 
-func (h *PostCommandHandler) validateCreateComment(cmd CreateCommentCommand) error {
+func (h *PostCommandHandler) validateCreateComment(cmd *CreateCommentCommand) error {
 	if cmd.UserID <= 0 {
-		return fmt.Errorf("invalid user ID")
+		return invalid("user_id", "invalid user ID")
 	}
 	if cmd.PostID <= 0 {
-		return fmt.Errorf("invalid post ID")
+		return invalid("post_id", "invalid post ID")
+	}
+	if err := CleanContent("content", "comment", &cmd.Content, h.content.InvisibleChars); err != nil {
+		return err
 	}
 
 	content := strings.TrimSpace(cmd.Content)
 	if content == "" {
-		return fmt.Errorf("content is required")
+		return invalid("content", "content is required")
 	}
-	if len(content) < 2 {
-		return fmt.Errorf("comment must be at least 2 characters")
+	if err := ValidateLength("content", "comment", content, h.content.CommentMinLength, h.content.CommentMaxLength); err != nil {
+		return err
 	}
-	if len(content) > 1000 {
-		return fmt.Errorf("comment must be less than 1000 characters")
+
+	if err := h.filterBannedWords(&cmd.Content, "content", "comment"); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// filterBannedWords applies the handler's word filter, see FilterBannedWords
+func (h *PostCommandHandler) filterBannedWords(text *string, field, noun string) error {
+	return FilterBannedWords(h.wordFilter, text, field, noun)
+}
+
+// FilterBannedWords rejects text containing banned words, or masks them in
+// place when filter is in mask mode. field is the command field and noun
+// how the message refers to the text. A nil filter lets everything through.
+func FilterBannedWords(filter *utils.WordFilter, text *string, field, noun string) error {
+	filtered, found := filter.Apply(*text)
+	if !found {
+		return nil
+	}
+	if filter.Mode() == utils.FilterModeMask {
+		*text = filtered
+		return nil
+	}
+	return invalid(field, "%s contains words that are not allowed", noun)
+}
+
 func (h *PostCommandHandler) validateReaction(reaction string) error {
 	if reaction != "like" && reaction != "dislike" {
-		return fmt.Errorf("reaction must be 'like' or 'dislike'")
+		return invalid("reaction", "reaction must be 'like' or 'dislike'")
 	}
 	return nil
 }
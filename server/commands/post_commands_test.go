@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"forum/server/config"
+)
+
+func TestCreatePostCategoryLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		categoryIDs []int
+		wantError   string // "" when the post is created
+	}{
+		{"no category", nil, "at least one category is required"},
+		{"over the limit", []int{1, 2, 3, 4, 5, 6}, "at most 5 categories"},
+		{"duplicates counted once", []int{1, 2, 2, 1, 3, 4, 5}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, db := newTestPostHandler(t)
+			handler.content.MaxCategoriesPerPost = 5
+
+			result, err := handler.CreatePost(CreatePostCommand{
+				UserID:      1,
+				Title:       "Category limits",
+				Content:     "A post with some categories",
+				CategoryIDs: tt.categoryIDs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantError != "" {
+				if result.Success || !strings.Contains(result.Error, tt.wantError) {
+					t.Fatalf("result = %+v, want error %q", result, tt.wantError)
+				}
+				if result.Field != "category_ids" {
+					t.Errorf("field = %q, want category_ids", result.Field)
+				}
+				return
+			}
+			if !result.Success {
+				t.Fatalf("result = %+v, want success", result)
+			}
+			var links int
+			db.QueryRow(`SELECT COUNT(*) FROM post_category pc JOIN posts p ON p.id = pc.post_id
+				WHERE p.title = 'Category limits'`).Scan(&links)
+			if links != 5 {
+				t.Errorf("%d categories linked, want 5", links)
+			}
+		})
+	}
+}
+
+func TestDedupeCategoryIDsKeepsOrder(t *testing.T) {
+	got := DedupeCategoryIDs([]int{3, 1, 3, 2, 1})
+	want := []int{3, 1, 2}
+	if len(got) != len(want) {
+		t.Fatalf("DedupeCategoryIDs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("DedupeCategoryIDs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCreatePostInvisibleCharacters(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		title     string
+		wantError string // "" when the post is created
+		wantTitle string
+	}{
+		{"stripped", config.InvisibleCharsStrip, "Zero\u200bwidth\u202e title", "", "Zerowidth title"},
+		{"rejected", config.InvisibleCharsReject, "Zero\u200bwidth title", "invisible or control characters", ""},
+		{"counted after stripping", config.InvisibleCharsStrip, "a\u200b\u200b\u200b\u200b", "title", ""},
+		{"invalid UTF-8", config.InvisibleCharsStrip, "bad \xff title", "not valid UTF-8", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, db := newTestPostHandler(t)
+			handler.content.InvisibleChars = tt.policy
+
+			result, err := handler.CreatePost(CreatePostCommand{
+				UserID:      1,
+				Title:       tt.title,
+				Content:     "Some content for the post",
+				CategoryIDs: []int{1},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantError != "" {
+				if result.Success || result.Field != "title" || !strings.Contains(result.Error, tt.wantError) {
+					t.Fatalf("result = %+v, want a title error containing %q", result, tt.wantError)
+				}
+				return
+			}
+			if !result.Success {
+				t.Fatalf("result = %+v, want success", result)
+			}
+			var title string
+			db.QueryRow("SELECT title FROM posts ORDER BY id DESC LIMIT 1").Scan(&title)
+			if title != tt.wantTitle {
+				t.Errorf("stored title = %q, want %q", title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestCreatePostWithTheSameCategoryTwice(t *testing.T) {
+	handler, db := newTestPostHandler(t)
+	result, err := handler.CreatePost(CreatePostCommand{
+		UserID:      1,
+		Title:       "Same category twice",
+		Content:     "Submitted by a buggy multi-select",
+		CategoryIDs: []int{2, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("result = %+v, want success", result)
+	}
+
+	var categories []int
+	rows, err := db.Query(`SELECT pc.category_id FROM post_category pc JOIN posts p ON p.id = pc.post_id
+		WHERE p.title = 'Same category twice'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		categories = append(categories, id)
+	}
+	if len(categories) != 1 || categories[0] != 2 {
+		t.Errorf("linked categories = %v, want [2]", categories)
+	}
+}
+
+// deleteCategoryOnInsert removes categoryID as soon as a post titled
+// title is inserted, i.e. after validation but before the links
+func deleteCategoryOnInsert(t *testing.T, db *sql.DB, title string, categoryID int) {
+	t.Helper()
+	_, err := db.Exec(fmt.Sprintf(`CREATE TRIGGER vanishing_category AFTER INSERT ON posts
+		WHEN NEW.title = '%s'
+		BEGIN DELETE FROM categories WHERE id = %d; END`, title, categoryID))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatePostCategoryDeletedBeforeLinking(t *testing.T) {
+	handler, db := newTestPostHandler(t)
+	deleteCategoryOnInsert(t, db, "Vanishing category", 3)
+
+	result, err := handler.CreatePost(CreatePostCommand{
+		UserID:      1,
+		Title:       "Vanishing category",
+		Content:     "Category 3 is gone by the time it is linked",
+		CategoryIDs: []int{1, 3},
+	})
+	if err != nil {
+		t.Fatalf("CreatePost returned a raw error: %v", err)
+	}
+	if result.Success || result.Field != "category_ids" || !strings.Contains(result.Error, "category 3 does not exist") {
+		t.Fatalf("result = %+v, want a category_ids error for category 3", result)
+	}
+
+	var posts int
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE title = 'Vanishing category'").Scan(&posts)
+	if posts != 0 {
+		t.Errorf("%d posts stored, want the insert rolled back", posts)
+	}
+}
+
+func TestCreateCommentLimit(t *testing.T) {
+	handler, _ := newTestPostHandler(t)
+	// Seeded post 1 has two comments
+	handler.content.MaxCommentsPerPost = 3
+
+	comment := func(content string) *CommandResult {
+		t.Helper()
+		result, err := handler.CreateComment(CreateCommentCommand{UserID: 4, PostID: 1, Content: content})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	if result := comment("The third comment"); !result.Success {
+		t.Fatalf("result = %+v, want success", result)
+	}
+	result := comment("The fourth comment")
+	if result.Success || result.Code != CodeForbidden || !strings.Contains(result.Error, "limit of 3 comments") {
+		t.Fatalf("result = %+v, want a forbidden error naming the limit", result)
+	}
+}
+
+func TestCreatePostNamesEveryMissingCategory(t *testing.T) {
+	tests := []struct {
+		name        string
+		categoryIDs []int
+		wantError   string
+	}{
+		{"one missing", []int{1, 999}, "category 999 does not exist"},
+		{"several missing", []int{999, 1, 998, 2}, "categories 999, 998 do not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, db := newTestPostHandler(t)
+			result, err := handler.CreatePost(CreatePostCommand{
+				UserID:      1,
+				Title:       "Missing categories",
+				Content:     "Some of these categories do not exist",
+				CategoryIDs: tt.categoryIDs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Success || result.Field != "category_ids" || result.Error != tt.wantError {
+				t.Fatalf("result = %+v, want category_ids error %q", result, tt.wantError)
+			}
+			var posts int
+			db.QueryRow("SELECT COUNT(*) FROM posts WHERE title = 'Missing categories'").Scan(&posts)
+			if posts != 0 {
+				t.Errorf("%d posts stored, want none", posts)
+			}
+		})
+	}
+}
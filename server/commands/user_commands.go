@@ -3,47 +3,78 @@ package commands
 import (
 	"database/sql"
 	"fmt"
+	"net/mail"
+	"regexp"
 	"strings"
 	"time"
 
+	"forum/server/clock"
+	"forum/server/config"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
 // UserCommandHandler handles all write operations for users
 type UserCommandHandler struct {
-	db *sql.DB
+	db      *sql.DB
+	content config.ContentConfig
+	session config.SessionConfig
+	clock   clock.Clock
+}
+
+// NewUserCommandHandler creates a new command handler. content decides
+// what DeleteAccount does with the posts and comments of the account,
+// session how long the sessions created by Login last, and c when they
+// start.
+func NewUserCommandHandler(db *sql.DB, content config.ContentConfig, session config.SessionConfig, c clock.Clock) *UserCommandHandler {
+	return &UserCommandHandler{db: db, content: content, session: session, clock: c}
 }
 
-// NewUserCommandHandler creates a new command handler
-func NewUserCommandHandler(db *sql.DB) *UserCommandHandler {
-	return &UserCommandHandler{db: db}
+// dummyPasswordHash is a bcrypt hash (default cost) of a password nobody
+// has; see DummyPasswordCheck
+const dummyPasswordHash = "$2a$10$PzwPdTubb/30XmhNWi.iEer6SxZQ9PPaShi.iBV6WcZfNZHKRc3Nu"
+
+// DummyPasswordCheck spends the time of a real bcrypt comparison and throws
+// the result away. Paths that turn a login or registration down without
+// hashing anything call it, so the response time does not tell whether an
+// account exists.
+func DummyPasswordCheck(password string) {
+	bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
 }
 
-// RegisterUser processes RegisterUserCommand
+// RegisterUser processes RegisterUserCommand. Registering an email that
+// already has an account looks like a success (without a user_id) so the
+// form cannot be used to find out who is signed up; a taken username is
+// reported, usernames are public anyway.
 func (h *UserCommandHandler) RegisterUser(cmd RegisterUserCommand) (*CommandResult, error) {
+	cmd.Email = NormalizeEmail(cmd.Email)
+	cmd.Username = NormalizeUsername(cmd.Username)
+
 	// Validation
 	if err := h.validateRegister(cmd); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return validationFailure(err), nil
 	}
 
 	// Check if email/username already exists
-	var exists bool
+	var emailTaken, usernameTaken bool
 	err := h.db.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM users WHERE email = ? OR username = ?)",
+		`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER(?)),
+			EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER(?))`,
 		cmd.Email, cmd.Username,
-	).Scan(&exists)
+	).Scan(&emailTaken, &usernameTaken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check user existence: %w", err)
 	}
-	if exists {
+	if emailTaken {
+		DummyPasswordCheck(cmd.Password)
 		return &CommandResult{
-			Success: false,
-			Error:   "email or username already exists",
+			Success: true,
+			Data:    map[string]interface{}{"username": cmd.Username},
 		}, nil
 	}
+	if usernameTaken {
+		return failure(CodeConflict, "username", "username is not available"), nil
+	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cmd.Password), bcrypt.DefaultCost)
@@ -78,26 +109,22 @@ func (h *UserCommandHandler) RegisterUser(cmd RegisterUserCommand) (*CommandResu
 func (h *UserCommandHandler) Login(cmd LoginCommand) (*CommandResult, error) {
 	// Validation
 	if err := h.validateLogin(cmd); err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return validationFailure(err), nil
 	}
 
 	// Find user by email or username
 	var userID int
 	var email, username, password string
 	err := h.db.QueryRow(
-		"SELECT id, email, username, password FROM users WHERE email = ? OR username = ?",
-		cmd.EmailOrUsername, cmd.EmailOrUsername,
+		"SELECT id, email, username, password FROM users WHERE LOWER(email) = LOWER(?) OR LOWER(username) = LOWER(?)",
+		NormalizeEmail(cmd.EmailOrUsername), NormalizeUsername(cmd.EmailOrUsername),
 	).Scan(&userID, &email, &username, &password)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return &CommandResult{
-				Success: false,
-				Error:   "invalid credentials",
-			}, nil
+			// As slow as a wrong password, so unknown names do not stand out
+			DummyPasswordCheck(cmd.Password)
+			return failure(CodeUnauthorized, "", "invalid credentials"), nil
 		}
 		return nil, fmt.Errorf("failed to query user: %w", err)
 	}
@@ -105,14 +132,16 @@ func (h *UserCommandHandler) Login(cmd LoginCommand) (*CommandResult, error) {
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(password), []byte(cmd.Password))
 	if err != nil {
-		return &CommandResult{
-			Success: false,
-			Error:   "invalid credentials",
-		}, nil
+		return failure(CodeUnauthorized, "", "invalid credentials"), nil
 	}
 
-	// Create session
-	sessionID, err := h.createSession(userID)
+	// Create session; "remember me" trades the sliding idle timeout for a
+	// long fixed lifetime
+	ttl := h.session.IdleTimeout
+	if cmd.RememberMe {
+		ttl = h.session.RememberMe
+	}
+	sessionID, expiresAt, err := h.createSession(userID, ttl, cmd.RememberMe)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -120,9 +149,11 @@ func (h *UserCommandHandler) Login(cmd LoginCommand) (*CommandResult, error) {
 	return &CommandResult{
 		Success: true,
 		Data: map[string]interface{}{
-			"user_id":    userID,
-			"username":   username,
-			"session_id": sessionID,
+			"user_id":     userID,
+			"username":    username,
+			"session_id":  sessionID,
+			"expires_at":  expiresAt,
+			"remember_me": cmd.RememberMe,
 		},
 	}, nil
 }
@@ -142,73 +173,307 @@ func (h *UserCommandHandler) Logout(userID int) (*CommandResult, error) {
 	}, nil
 }
 
-// createSession generates a new session for the user
-func (h *UserCommandHandler) createSession(userID int) (string, error) {
-	sessionID := generateSessionID()
-	expiresAt := time.Now().Add(24 * time.Hour) // 24 hour session
+// deletedUsername is the placeholder user that posts and comments of
+// deleted accounts are credited to. Nobody can register it (brackets are
+// not allowed in usernames or emails) or log in as it (its password is not
+// a bcrypt hash).
+const deletedUsername = "[deleted]"
+
+// DeleteAccount processes DeleteAccountCommand. After checking the
+// password it removes, in one transaction, the user's sessions, reactions,
+// notifications and drafts, then anonymizes or deletes their posts and
+// comments according to the AccountDeletion policy, and finally the user.
+// Data["files"] lists the upload files (avatar, images of deleted posts)
+// that are no longer used, for the caller to remove.
+func (h *UserCommandHandler) DeleteAccount(cmd DeleteAccountCommand) (*CommandResult, error) {
+	if cmd.Password == "" {
+		return failure(CodeValidation, "password", "password is required"), nil
+	}
+
+	var hash string
+	var avatarPath sql.NullString
+	err := h.db.QueryRow("SELECT password, avatar_path FROM users WHERE id = ?", cmd.UserID).Scan(&hash, &avatarPath)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return failure(CodeNotFound, "", "account not found"), nil
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(cmd.Password)) != nil {
+		return failure(CodeUnauthorized, "password", "password is incorrect"), nil
+	}
+
+	policy := h.content.AccountDeletion
+	if policy != config.AccountDeletionDelete {
+		policy = config.AccountDeletionAnonymize
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM post_reactions WHERE user_id = ?",
+		"DELETE FROM comment_reactions WHERE user_id = ?",
+		"DELETE FROM notifications WHERE user_id = ?",
+		"DELETE FROM notifications WHERE actor_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, cmd.UserID); err != nil {
+			return nil, fmt.Errorf("failed to delete account data: %w", err)
+		}
+	}
+
+	var files []string
+	if policy == config.AccountDeletionDelete {
+		if err := purgeComments(tx, "user_id = ?", cmd.UserID); err != nil {
+			return nil, err
+		}
+		if files, err = purgePosts(tx, "user_id = ?", cmd.UserID); err != nil {
+			return nil, err
+		}
+	} else {
+		// Drafts were never public, so there is nothing to keep
+		if files, err = purgePosts(tx, "user_id = ? AND status = 'draft'", cmd.UserID); err != nil {
+			return nil, err
+		}
+		placeholderID, err := deletedUserID(tx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE posts SET user_id = ? WHERE user_id = ?", placeholderID, cmd.UserID); err != nil {
+			return nil, fmt.Errorf("failed to anonymize posts: %w", err)
+		}
+		if _, err := tx.Exec("UPDATE comments SET user_id = ? WHERE user_id = ?", placeholderID, cmd.UserID); err != nil {
+			return nil, fmt.Errorf("failed to anonymize comments: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", cmd.UserID); err != nil {
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if avatarPath.String != "" {
+		files = append(files, avatarPath.String)
+	}
+	return &CommandResult{
+		Success: true,
+		Data: map[string]interface{}{
+			"user_id": cmd.UserID,
+			"policy":  policy,
+			"files":   files,
+		},
+	}, nil
+}
+
+// deletedUserID returns the id of the deletedUsername placeholder,
+// creating it on first use
+func deletedUserID(tx *sql.Tx) (int, error) {
+	_, err := tx.Exec(
+		"INSERT INTO users (email, username, password) VALUES (?, ?, '!') ON CONFLICT DO NOTHING",
+		deletedUsername, deletedUsername,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create placeholder user: %w", err)
+	}
+	var id int
+	if err := tx.QueryRow("SELECT id FROM users WHERE username = ?", deletedUsername).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to find placeholder user: %w", err)
+	}
+	return id, nil
+}
+
+// purgeComments hard-deletes the comments matching where, with their
+// reactions and notifications
+func purgeComments(tx *sql.Tx, where string, args ...interface{}) error {
+	ids := "SELECT id FROM comments WHERE " + where
+	for _, stmt := range []string{
+		"DELETE FROM comment_reactions WHERE comment_id IN (" + ids + ")",
+		"DELETE FROM notifications WHERE comment_id IN (" + ids + ")",
+		"DELETE FROM comments WHERE " + where,
+	} {
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return fmt.Errorf("failed to delete comments: %w", err)
+		}
+	}
+	return nil
+}
+
+// purgePosts hard-deletes the posts matching where with everything attached
+// to them, including other users' comments, and returns the file names of
+// their images
+func purgePosts(tx *sql.Tx, where string, args ...interface{}) ([]string, error) {
+	ids := "SELECT id FROM posts WHERE " + where
+	if err := purgeComments(tx, "post_id IN ("+ids+")", args...); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT path FROM post_images WHERE post_id IN ("+ids+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post images: %w", err)
+	}
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan post image: %w", err)
+		}
+		files = append(files, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read post images: %w", err)
+	}
+
+	// post_category has no ON DELETE CASCADE, so drop the links first
+	for _, stmt := range []string{
+		"DELETE FROM post_reactions WHERE post_id IN (" + ids + ")",
+		"DELETE FROM post_category WHERE post_id IN (" + ids + ")",
+		"DELETE FROM post_images WHERE post_id IN (" + ids + ")",
+		"DELETE FROM notifications WHERE post_id IN (" + ids + ")",
+		"DELETE FROM posts WHERE " + where,
+	} {
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			return nil, fmt.Errorf("failed to delete posts: %w", err)
+		}
+	}
+	return files, nil
+}
+
+// createSession generates a new session for the user that expires ttl
+// after the handler's clock. The ID comes from crypto/rand, as the web
+// sign-in's does, so it cannot be guessed from the time. remember marks it as a "remember me" session, which does not slide.
+func (h *UserCommandHandler) createSession(userID int, ttl time.Duration, remember bool) (string, time.Time, error) {
+	sessionID, err := config.GenerateSessionID()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	now := h.clock.Now()
+	expiresAt := now.Add(ttl)
 
 	// Delete old session if exists
-	_, err := h.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	_, err = h.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
 	if err != nil {
-		return "", fmt.Errorf("failed to delete old session: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to delete old session: %w", err)
 	}
 
 	// Insert new session
 	_, err = h.db.Exec(
-		"INSERT INTO sessions (user_id, session_id, expires_at) VALUES (?, ?, ?)",
-		userID, sessionID, expiresAt,
+		"INSERT INTO sessions (user_id, session_id, expires_at, created_at, remember) VALUES (?, ?, ?, ?, ?)",
+		userID, sessionID, expiresAt, now, remember,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to insert session: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to insert session: %w", err)
 	}
 
-	return sessionID, nil
+	return sessionID, expiresAt, nil
 }
 
 // Validation methods
 
 func (h *UserCommandHandler) validateRegister(cmd RegisterUserCommand) error {
-	email := strings.TrimSpace(cmd.Email)
+	if err := ValidateEmail(cmd.Email); err != nil {
+		return &ValidationError{Field: "email", Err: err}
+	}
+	if err := ValidateUsername(cmd.Username); err != nil {
+		return &ValidationError{Field: "username", Err: err}
+	}
+	if err := ValidatePassword(cmd.Password); err != nil {
+		return &ValidationError{Field: "password", Err: err}
+	}
+	return nil
+}
+
+const (
+	usernameMinLength = 3
+	usernameMaxLength = 50
+	passwordMinLength = 6
+)
+
+// usernamePattern is the set of characters allowed in a username
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedUsernames would be confusing next to the site's own routes and
+// roles, so nobody may register them (compared case-insensitively)
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true,
+	"api": true, "assets": true, "health": true,
+	"login": true, "logout": true, "signin": true, "signup": true, "register": true,
+	"post": true, "user": true, "category": true,
+	"mycreatedposts": true, "mylikedposts": true,
+}
+
+// NormalizeEmail trims and lowercases an email address. Emails are stored
+// in this form so that User@X.com and user@x.com are the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeUsername trims a username. The original case is kept for
+// display; uniqueness and lookups compare usernames case-insensitively.
+func NormalizeUsername(username string) string {
+	return strings.TrimSpace(username)
+}
+
+// ValidateEmail checks that email is a single bare address such as
+// "jane@example.com"; display names ("Jane <jane@example.com>") are rejected
+func ValidateEmail(email string) error {
+	email = strings.TrimSpace(email)
 	if email == "" {
-		return fmt.Errorf("email is required")
+		return ErrEmailRequired
 	}
-	if !strings.Contains(email, "@") || !strings.Contains(email, ".") {
-		return fmt.Errorf("invalid email format")
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return ErrEmailInvalid
 	}
+	return nil
+}
 
-	username := strings.TrimSpace(cmd.Username)
+// ValidateUsername checks the length and characters of a username and
+// that it is not reserved
+func ValidateUsername(username string) error {
+	username = strings.TrimSpace(username)
 	if username == "" {
-		return fmt.Errorf("username is required")
+		return ErrUsernameRequired
 	}
-	if len(username) < 3 {
-		return fmt.Errorf("username must be at least 3 characters")
+	if len(username) < usernameMinLength {
+		return ErrUsernameTooShort
 	}
-	if len(username) > 50 {
-		return fmt.Errorf("username must be less than 50 characters")
+	if len(username) > usernameMaxLength {
+		return ErrUsernameTooLong
 	}
-
-	if cmd.Password == "" {
-		return fmt.Errorf("password is required")
+	if !usernamePattern.MatchString(username) {
+		return ErrUsernameInvalidChar
 	}
-	if len(cmd.Password) < 6 {
-		return fmt.Errorf("password must be at least 6 characters")
+	if reservedUsernames[strings.ToLower(username)] {
+		return ErrUsernameReserved
 	}
+	return nil
+}
 
+// ValidatePassword checks the minimum password requirements
+func ValidatePassword(password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if len(password) < passwordMinLength {
+		return ErrPasswordTooShort
+	}
 	return nil
 }
 
 func (h *UserCommandHandler) validateLogin(cmd LoginCommand) error {
 	if strings.TrimSpace(cmd.EmailOrUsername) == "" {
-		return fmt.Errorf("email or username is required")
+		return invalid("email_or_username", "email or username is required")
 	}
 	if cmd.Password == "" {
-		return fmt.Errorf("password is required")
+		return invalid("password", "password is required")
 	}
 	return nil
 }
-
-// generateSessionID creates a unique session identifier
-func generateSessionID() string {
-	// Simple session ID generation (in production, use crypto/rand)
-	return fmt.Sprintf("session_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
-}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func newTestUserHandler(t *testing.T) *UserCommandHandler {
+	t.Helper()
+	cfg := config.LoadConfig()
+	return NewUserCommandHandler(testutil.NewDB(t), cfg.Content, cfg.Session, clock.Real)
+}
+
+func TestRegisterUserIgnoresUsernameCase(t *testing.T) {
+	handler := newTestUserHandler(t)
+
+	result, err := handler.RegisterUser(RegisterUserCommand{Email: "bob2@example.com", Username: "Bobby", Password: "password123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("registering Bobby: %+v", result)
+	}
+
+	result, err = handler.RegisterUser(RegisterUserCommand{Email: "other@example.com", Username: "  bobby ", Password: "password123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success || result.Code != CodeConflict {
+		t.Errorf("registering bobby after Bobby: %+v, want a conflict", result)
+	}
+}
+
+func TestRegisterUserNormalizesEmail(t *testing.T) {
+	handler := newTestUserHandler(t)
+
+	if _, err := handler.RegisterUser(RegisterUserCommand{Email: " Frank@Example.COM ", Username: "frank", Password: "password123"}); err != nil {
+		t.Fatal(err)
+	}
+	var email string
+	if err := handler.db.QueryRow("SELECT email FROM users WHERE username = 'frank'").Scan(&email); err != nil {
+		t.Fatal(err)
+	}
+	if email != "frank@example.com" {
+		t.Errorf("stored email = %q, want frank@example.com", email)
+	}
+
+	// The same address in another case must not create a second account
+	if _, err := handler.RegisterUser(RegisterUserCommand{Email: "FRANK@example.com", Username: "frank2", Password: "password123"}); err != nil {
+		t.Fatal(err)
+	}
+	var accounts int
+	handler.db.QueryRow("SELECT COUNT(*) FROM users WHERE LOWER(email) = 'frank@example.com'").Scan(&accounts)
+	if accounts != 1 {
+		t.Errorf("%d accounts for frank@example.com, want 1", accounts)
+	}
+}
+
+func TestUsersUniqueIndexIgnoresCase(t *testing.T) {
+	handler := newTestUserHandler(t)
+
+	// Seeded user alice; the index catches what the checks might race past
+	_, err := handler.db.Exec("INSERT INTO users (email, username, password) VALUES ('x@example.com', 'ALICE', 'x')")
+	if err == nil {
+		t.Error("inserted ALICE next to alice")
+	}
+}
+
+func TestLoginIgnoresCase(t *testing.T) {
+	handler := newTestUserHandler(t)
+	if _, err := handler.RegisterUser(RegisterUserCommand{Email: "grace@example.com", Username: "Grace", Password: "password123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"grace", " GRACE ", "Grace@Example.com"} {
+		result, err := handler.Login(LoginCommand{EmailOrUsername: name, Password: "password123"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Success {
+			t.Errorf("login as %q: %+v", name, result)
+		}
+	}
+}
+
+func TestRegisterUserHidesTakenEmails(t *testing.T) {
+	handler := newTestUserHandler(t)
+
+	// alice@example.com is seeded; the answer must look like a new account
+	result, err := handler.RegisterUser(RegisterUserCommand{Email: "alice@example.com", Username: "newalice", Password: "password123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Error != "" {
+		t.Errorf("registering a taken email: %+v, want a plain success", result)
+	}
+	var exists bool
+	handler.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = 'newalice')").Scan(&exists)
+	if exists {
+		t.Error("an account was created for a taken email")
+	}
+}
+
+func TestLoginTimingDoesNotRevealUnknownUsers(t *testing.T) {
+	handler := newTestUserHandler(t)
+
+	// Best of a few runs, so a slow scheduler does not decide the outcome
+	fastest := func(name string) time.Duration {
+		best := time.Duration(1<<63 - 1)
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			result, err := handler.Login(LoginCommand{EmailOrUsername: name, Password: "wrong-password"})
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Success || result.Error != "invalid credentials" {
+				t.Fatalf("login as %s: %+v, want invalid credentials", name, result)
+			}
+			if elapsed < best {
+				best = elapsed
+			}
+		}
+		return best
+	}
+
+	known := fastest("alice")
+	unknown := fastest("nobody-here")
+	// Both run one bcrypt comparison; without the dummy check the unknown
+	// user would answer in a fraction of a millisecond
+	if unknown < known/2 {
+		t.Errorf("unknown user answered in %v, a wrong password in %v", unknown, known)
+	}
+}
+
+func TestCreateSessionIDsAreRandom(t *testing.T) {
+	cfg := config.LoadConfig()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// The clock does not move, so both sessions are created in the same instant
+	handler := NewUserCommandHandler(testutil.NewDB(t), cfg.Content, cfg.Session, clock.NewFake(start))
+
+	first, firstExpiry, err := handler.createSession(1, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondExpiry, err := handler.createSession(2, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Fatalf("two sessions got the same ID %q", first)
+	}
+	for _, id := range []string{first, second} {
+		if len(id) != 32 || strings.Trim(id, "0123456789abcdef") != "" {
+			t.Errorf("session ID %q is not 32 random hex digits", id)
+		}
+		if strings.Contains(id, strconv.FormatInt(start.Unix(), 10)) {
+			t.Errorf("session ID %q contains the creation time", id)
+		}
+	}
+	if want := start.Add(time.Hour); !firstExpiry.Equal(want) || !secondExpiry.Equal(want) {
+		t.Errorf("expiries = %v and %v, want %v from the handler's clock", firstExpiry, secondExpiry, want)
+	}
+}
@@ -1,17 +1,28 @@
 package config
 
 import (
+	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Cache    CacheConfig
-	App      AppConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Cache       CacheConfig
+	Session     SessionConfig
+	Log         LogConfig
+	Moderation  ModerationConfig
+	Content     ContentConfig
+	Upload      UploadConfig
+	Metrics     MetricsConfig
+	RateLimit   RateLimitConfig
+	Web         WebConfig
+	Maintenance MaintenanceConfig
+	App         AppConfig
 }
 
 type ServerConfig struct {
@@ -19,31 +30,212 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	TLSCertFile  string
+	TLSKeyFile   string
+	RedirectPort int // plain HTTP port redirecting to HTTPS; 0 disables it
+	// AllowedHosts lists the Host headers served, comma separated; empty or "*" serves any
+	AllowedHosts string
+	// LiveSubscribersPerPost caps WebSocket readers of a single post; 0 means no limit
+	LiveSubscribersPerPost int
+}
+
+// TLSEnabled reports whether both a certificate and a key are configured
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
 }
 
 type DatabaseConfig struct {
+	Driver          string // database/sql driver name, e.g. sqlite3 or postgres
+	DSN             string // driver-specific connection string; defaults to Path for sqlite3
 	Path            string
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+	QueryTimeout    time.Duration // cancels a request's database work; 0 disables it
+	// BusyTimeout is how long a SQLite write waits for the write lock held
+	// by another connection before failing with "database is locked"
+	BusyTimeout time.Duration
 }
 
 type CacheConfig struct {
 	TemplateTTL time.Duration
 	SessionTTL  time.Duration
 	PostTTL     time.Duration
+	CategoryTTL time.Duration
+	CountTTL    time.Duration
+	StatsTTL    time.Duration // admin statistics, which are costly to aggregate
+	WarmOnStart bool          // load the anonymous post list and categories before serving
+}
+
+type SessionConfig struct {
+	IdleTimeout  time.Duration // inactivity after which a session expires
+	MaxLifetime  time.Duration // hard cap, however active the user is
+	CookieSecure bool          // send the cookie over HTTPS only
+	// RememberMe is the fixed lifetime of a "remember me" session, which
+	// gets a persistent cookie; other sessions slide and end with the browser
+	RememberMe time.Duration
+}
+
+type LogConfig struct {
+	Level      string // debug, info, warn or error
+	Output     string // stdout, file or both
+	FilePath   string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+type ModerationConfig struct {
+	BannedWords     string // comma separated list
+	BannedWordsFile string // optional file with one word per line
+	FilterMode      string // reject or mask
+}
+
+type ContentConfig struct {
+	MaxCategoriesPerPost int
+	MaxPinnedPosts       int    // how many posts admins may pin at once; 0 means no limit
+	MaxCommentsPerPost   int    // comments a post takes before refusing more; 0 means no limit
+	HomepagePostLimit    int    // most posts the homepage and the full post list reach; 0 means no limit
+	SelfReactions        string // exclude, forbid or allow
+	AccountDeletion      string // anonymize or delete
+	ReactorsVisibility   string // who may list the users reacting to a post: author or everyone
+	// DuplicateCommentWindow is how long the same comment cannot be posted
+	// twice in a row on a post; 0 turns the check off
+	DuplicateCommentWindow time.Duration
+	InvisibleChars         string // strip or reject
+	// Length bounds in characters, enforced on the server whatever the form
+	// allows; a max of 0 means no limit
+	TitleMinLength   int
+	TitleMaxLength   int
+	PostMinLength    int
+	PostMaxLength    int
+	CommentMinLength int
+	CommentMaxLength int
+}
+
+type UploadConfig struct {
+	Dir           string // where uploaded images are stored, served under UploadURLPrefix
+	MaxImageSize  int64  // bytes per post image
+	MaxAvatarSize int64  // bytes per avatar
+}
+
+// Rate limit modes: what happens to a request over the limit
+const (
+	RateLimitEnforce = "enforce" // rejected with 429
+	RateLimitMonitor = "monitor" // logged and let through, to size a new limit
+)
+
+// RateLimitConfig holds the mode and token bucket of each rate limit
+// policy. A policy allows Burst requests back to back, then one more every
+// Refill; the defaults are 100, 5 and 10 requests a minute.
+type RateLimitConfig struct {
+	PublicMode   string // enforce or monitor
+	PublicBurst  int
+	PublicRefill time.Duration
+	LoginMode    string
+	LoginBurst   int
+	LoginRefill  time.Duration
+	CreateMode   string
+	CreateBurst  int
+	CreateRefill time.Duration
+}
+
+// WebConfig says where the front end lives on disk. The directories
+// default to web/templates and web/assets under BASE_PATH.
+type WebConfig struct {
+	TemplatesDir string // page templates, with the shared ones in partials/
+	AssetsDir    string // served under /assets/
+	// SPAFallback answers GET requests for unknown paths outside /api/ with
+	// SPAIndex, so a client-side router can take over; off shows the 404 page
+	SPAFallback bool
+	SPAIndex    string
+}
+
+// MaintenanceConfig controls the maintenance page. The site is in
+// maintenance while Enabled is set or File exists, so it can be switched
+// on and off during a deploy without a restart.
+type MaintenanceConfig struct {
+	Enabled    bool
+	File       string        // checked about once a second; "" turns the file check off
+	AllowIPs   string        // comma separated IPs or CIDR ranges that bypass it, like admins
+	RetryAfter time.Duration // sent as Retry-After on the 503
+}
+
+type MetricsConfig struct {
+	Enabled bool // serve Prometheus metrics on /metrics
+	// Port gives /metrics a listener of its own, e.g. one only reachable
+	// from the internal network; 0 serves it on the main port
+	Port int
+}
+
+// UploadURLPrefix is the URL path uploaded files are served from
+const UploadURLPrefix = "/uploads/"
+
+// UploadURL returns the public URL of a file stored in the upload directory
+func UploadURL(name string) string {
+	return UploadURLPrefix + name
+}
+
+// AvatarURL returns the URL of a user's avatar: the uploaded file at
+// avatarPath, or the generated default for userID when none is set
+func AvatarURL(userID int, avatarPath string) string {
+	if avatarPath != "" {
+		return UploadURL(avatarPath)
+	}
+	return "/avatar/" + strconv.Itoa(userID)
+}
+
+// Self-reaction policies: how likes/dislikes on your own content are treated
+const (
+	SelfReactionsExclude = "exclude" // allowed, but left out of the counts
+	SelfReactionsForbid  = "forbid"  // rejected by the reaction commands
+	SelfReactionsAllow   = "allow"   // counted like any other reaction
+)
+
+// Account deletion policies: what happens to the posts and comments of a
+// deleted account. Drafts, reactions and notifications are always removed.
+const (
+	AccountDeletionAnonymize = "anonymize" // kept, credited to the [deleted] user
+	AccountDeletionDelete    = "delete"    // removed, with the replies to the posts
+)
+
+// Reactor list visibility: who may see which users liked or disliked a post
+const (
+	ReactorsVisibleToAuthor   = "author"   // the post's author and admins
+	ReactorsVisibleToEveryone = "everyone" // anyone who can see the post
+)
+
+// Invisible character policies: what happens to zero-width and control
+// characters in new posts and comments
+const (
+	InvisibleCharsStrip  = "strip"  // removed before the content is stored
+	InvisibleCharsReject = "reject" // the post or comment is refused
+)
+
+// ExcludeSelfReactions reports whether counts should ignore reactions by
+// the author. Reactions left before switching to forbid are ignored too.
+func (c ContentConfig) ExcludeSelfReactions() bool {
+	return c.SelfReactions != SelfReactionsAllow
 }
 
 type AppConfig struct {
 	BasePath    string
 	Environment string
 	IsProduction bool
+	EnvFile     string // optional KEY=value file loaded before the env lookups
 }
 
 // LoadConfig loads configuration from environment variables with fallbacks
 func LoadConfig() *Config {
+	// Real environment variables always take precedence over the file
+	envFile := getEnv("ENV_FILE", ".env")
+	if err := LoadEnvFile(envFile); err != nil {
+		log.Println("Warning: could not load env file:", err)
+	}
+
 	env := getEnv("ENV", "development")
 	isProd := env == "production"
+	basePath := getEnv("BASE_PATH", "")
+	assetsDir := getEnv("WEB_ASSETS_DIR", basePath+"web/assets")
 	
 	cfg := &Config{
 		Server: ServerConfig{
@@ -51,22 +243,106 @@ func LoadConfig() *Config {
 			ReadTimeout:  getEnvDuration("READ_TIMEOUT", 15*time.Second),
 			WriteTimeout: getEnvDuration("WRITE_TIMEOUT", 15*time.Second),
 			IdleTimeout:  getEnvDuration("IDLE_TIMEOUT", 60*time.Second),
+			TLSCertFile:  getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:   getEnv("TLS_KEY_FILE", ""),
+			RedirectPort: getEnvInt("HTTP_REDIRECT_PORT", 0),
+			AllowedHosts: getEnv("ALLOWED_HOSTS", ""),
+			LiveSubscribersPerPost: getEnvInt("LIVE_SUBSCRIBERS_PER_POST", 100),
 		},
 		Database: DatabaseConfig{
+			Driver:          getEnv("DB_DRIVER", "sqlite3"),
+			DSN:             getEnv("DB_DSN", ""),
 			Path:            getEnv("DB_PATH", "server/database/database.db"),
 			MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
 			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+			QueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 10*time.Second),
+			BusyTimeout:     getEnvDuration("DB_BUSY_TIMEOUT", 5*time.Second),
 		},
 		Cache: CacheConfig{
 			TemplateTTL: getEnvDuration("CACHE_TEMPLATE_TTL", 1*time.Hour),
 			SessionTTL:  getEnvDuration("CACHE_SESSION_TTL", 10*time.Minute),
 			PostTTL:     getEnvDuration("CACHE_POST_TTL", 5*time.Minute),
+			CategoryTTL: getEnvDuration("CACHE_CATEGORY_TTL", 1*time.Hour),
+			CountTTL:    getEnvDuration("CACHE_COUNT_TTL", 30*time.Second),
+			StatsTTL:    getEnvDuration("CACHE_STATS_TTL", 1*time.Minute),
+			WarmOnStart: getEnvBool("CACHE_WARM_ON_START", true),
+		},
+		Session: SessionConfig{
+			IdleTimeout: getEnvDuration("SESSION_IDLE_TIMEOUT", 24*time.Hour),
+			MaxLifetime: getEnvDuration("SESSION_MAX_LIFETIME", 7*24*time.Hour),
+			RememberMe:  getEnvDuration("SESSION_REMEMBER_ME_LIFETIME", 30*24*time.Hour),
+			// Defaults to on with in-process TLS; set it to true as well when
+			// running behind an HTTPS reverse proxy
+			CookieSecure: getEnvBool("SESSION_COOKIE_SECURE",
+				getEnv("TLS_CERT_FILE", "") != "" && getEnv("TLS_KEY_FILE", "") != ""),
+		},
+		Log: LogConfig{
+			Level:      getEnv("LOG_LEVEL", "info"),
+			Output:     getEnv("LOG_OUTPUT", "stdout"),
+			FilePath:   getEnv("LOG_FILE", "logs/forum.log"),
+			MaxSizeMB:  getEnvInt("LOG_MAX_SIZE_MB", 10),
+			MaxBackups: getEnvInt("LOG_MAX_BACKUPS", 5),
+		},
+		Moderation: ModerationConfig{
+			BannedWords:     getEnv("BANNED_WORDS", ""),
+			BannedWordsFile: getEnv("BANNED_WORDS_FILE", ""),
+			FilterMode:      getEnv("BANNED_WORDS_MODE", "reject"),
+		},
+		Content: ContentConfig{
+			MaxCategoriesPerPost: getEnvInt("MAX_CATEGORIES_PER_POST", 5),
+			MaxPinnedPosts:       getEnvInt("MAX_PINNED_POSTS", 3),
+			MaxCommentsPerPost:   getEnvInt("MAX_COMMENTS_PER_POST", 0),
+			HomepagePostLimit:    getEnvInt("HOMEPAGE_POST_LIMIT", 100),
+			SelfReactions:        getEnv("SELF_REACTIONS", SelfReactionsExclude),
+			AccountDeletion:      getEnv("ACCOUNT_DELETION", AccountDeletionAnonymize),
+			ReactorsVisibility:   getEnv("REACTORS_VISIBILITY", ReactorsVisibleToAuthor),
+			DuplicateCommentWindow: getEnvDuration("DUPLICATE_COMMENT_WINDOW", 30*time.Second),
+			InvisibleChars:         getEnv("INVISIBLE_CHARS", InvisibleCharsStrip),
+			TitleMinLength:         getEnvInt("TITLE_MIN_LENGTH", 3),
+			TitleMaxLength:         getEnvInt("TITLE_MAX_LENGTH", 200),
+			PostMinLength:          getEnvInt("POST_MIN_LENGTH", 10),
+			PostMaxLength:          getEnvInt("POST_MAX_LENGTH", 3000),
+			CommentMinLength:       getEnvInt("COMMENT_MIN_LENGTH", 2),
+			CommentMaxLength:       getEnvInt("COMMENT_MAX_LENGTH", 1000),
+		},
+		Upload: UploadConfig{
+			Dir:           getEnv("UPLOAD_DIR", "server/database/uploads"),
+			MaxImageSize:  int64(getEnvInt("UPLOAD_MAX_IMAGE_SIZE", 5<<20)),
+			MaxAvatarSize: int64(getEnvInt("UPLOAD_MAX_AVATAR_SIZE", 1<<20)),
+		},
+		Metrics: MetricsConfig{
+			Enabled: getEnvBool("METRICS_ENABLED", false),
+			Port:    getEnvInt("METRICS_PORT", 0),
+		},
+		RateLimit: RateLimitConfig{
+			PublicMode:   getEnv("RATE_LIMIT_PUBLIC_MODE", RateLimitEnforce),
+			PublicBurst:  getEnvInt("RATE_LIMIT_PUBLIC_BURST", 100),
+			PublicRefill: getEnvDuration("RATE_LIMIT_PUBLIC_REFILL", 600*time.Millisecond),
+			LoginMode:    getEnv("RATE_LIMIT_LOGIN_MODE", RateLimitEnforce),
+			LoginBurst:   getEnvInt("RATE_LIMIT_LOGIN_BURST", 5),
+			LoginRefill:  getEnvDuration("RATE_LIMIT_LOGIN_REFILL", 12*time.Second),
+			CreateMode:   getEnv("RATE_LIMIT_CREATE_MODE", RateLimitEnforce),
+			CreateBurst:  getEnvInt("RATE_LIMIT_CREATE_BURST", 10),
+			CreateRefill: getEnvDuration("RATE_LIMIT_CREATE_REFILL", 6*time.Second),
+		},
+		Web: WebConfig{
+			TemplatesDir: getEnv("WEB_TEMPLATES_DIR", basePath+"web/templates"),
+			AssetsDir:    assetsDir,
+			SPAFallback:  getEnvBool("WEB_SPA_FALLBACK", false),
+			SPAIndex:     getEnv("WEB_SPA_INDEX", filepath.Join(assetsDir, "index.html")),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:    getEnvBool("MAINTENANCE_MODE", false),
+			File:       getEnv("MAINTENANCE_FILE", ""),
+			AllowIPs:   getEnv("MAINTENANCE_ALLOW_IPS", ""),
+			RetryAfter: getEnvDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
 		},
 		App: AppConfig{
-			BasePath:     getEnv("BASE_PATH", ""),
+			BasePath:     basePath,
 			Environment:  env,
 			IsProduction: isProd,
+			EnvFile:      envFile,
 		},
 	}
 	
@@ -91,6 +367,15 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -3,13 +3,19 @@ package config
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 func Connect() (*sql.DB, error) {
 	cfg := LoadConfig()
-	
-	dbPath := cfg.App.BasePath + cfg.Database.Path
-	db, err := sql.Open("sqlite3", dbPath)
+	if _, err := DialectFor(cfg.Database.Driver); err != nil {
+		return nil, err
+	}
+
+	// The driver itself must be registered with a blank import in main
+	db, err := sql.Open(cfg.Database.Driver, cfg.Database.DataSourceName(cfg.App.BasePath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -26,3 +32,48 @@ func Connect() (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// DataSourceName returns DB_DSN when set. Otherwise SQLite falls back to
+// the database file at basePath + DB_PATH, as it always has.
+//
+// SQLite settings are per connection, so they go in the DSN, which the
+// driver applies to every connection the pool opens. A DSN that sets one
+// itself keeps its value. SQLite DSNs get:
+//   - _foreign_keys=on: foreign keys are only enforced when asked
+//   - _journal_mode=WAL: readers are not blocked while a write is running
+//   - _busy_timeout: DB_BUSY_TIMEOUT in milliseconds
+//
+// WAL still allows a single writer. Of the up to DB_MAX_OPEN_CONNS
+// connections, those writing at the same moment queue for the lock and
+// each gives up after the busy timeout, so a large pool under a write
+// spike needs a timeout long enough for the queue to drain.
+func (c DatabaseConfig) DataSourceName(basePath string) string {
+	dsn := c.DSN
+	if dsn == "" {
+		dsn = basePath + c.Path
+	}
+	if dialect, _ := DialectFor(c.Driver); dialect == DialectSQLite {
+		dsn = withDSNParam(dsn, "_foreign_keys", "on", "_fk")
+		dsn = withDSNParam(dsn, "_journal_mode", "WAL", "_journal")
+		dsn = withDSNParam(dsn, "_busy_timeout", strconv.FormatInt(c.BusyTimeout.Milliseconds(), 10), "_timeout")
+	}
+	return dsn
+}
+
+// withDSNParam adds key=value to the query string of a SQLite DSN unless
+// the key, or one of its aliases, is already there
+func withDSNParam(dsn, key, value string, aliases ...string) string {
+	base, query, _ := strings.Cut(dsn, "?")
+	if query != "" {
+		params, err := url.ParseQuery(query)
+		if err == nil {
+			for _, name := range append([]string{key}, aliases...) {
+				if params.Has(name) {
+					return dsn
+				}
+			}
+		}
+		return base + "?" + query + "&" + key + "=" + value
+	}
+	return base + "?" + key + "=" + value
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// useTestDatabase points DB_PATH at a fresh file in a temp directory
+func useTestDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "forum.db")
+	t.Setenv("ENV", "development")
+	t.Setenv("ENV_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	t.Setenv("DB_DSN", "")
+	t.Setenv("DB_PATH", path)
+	return path
+}
+
+func TestConnectAllowsConcurrentWriters(t *testing.T) {
+	useTestDatabase(t)
+	db, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Fatalf("journal_mode = %q, want wal", mode)
+	}
+	if _, err := db.Exec(`CREATE TABLE writes (writer INTEGER, n INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < perWriter; n++ {
+				if _, err := db.Exec(`INSERT INTO writes (writer, n) VALUES (?, ?)`, w, n); err != nil {
+					errs <- fmt.Errorf("writer %d, insert %d: %w", w, n, err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM writes`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != writers*perWriter {
+		t.Fatalf("stored %d rows, want %d", count, writers*perWriter)
+	}
+}
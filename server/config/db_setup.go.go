@@ -2,9 +2,11 @@ package config
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -28,39 +30,192 @@ func CreateTables(db *sql.DB) error {
 	return nil
 }
 
-// CreateFakeData generates and inserts fake data into the database
-func CreateDemoData(db *sql.DB) error {
+// ErrSeedInProduction is returned by CreateDemoData when ENV=production
+// and the caller did not force it
+var ErrSeedInProduction = errors.New("refusing to insert demo data into a production database (ENV=production); use --seed=force to override")
+
+// SeedResult counts the demo rows of one INSERT in seed.sql
+type SeedResult struct {
+	Table    string
+	Inserted int64
+	Skipped  int64 // already present
+}
+
+// CreateDemoData creates the schema if needed and inserts the demo data
+// from seed.sql in one transaction. Seeding is idempotent: rows that are
+// already there are skipped and reported as such. It refuses to run in
+// production unless force is set.
+func CreateDemoData(db *sql.DB, force bool) ([]SeedResult, error) {
+	if LoadConfig().App.IsProduction && !force {
+		return nil, ErrSeedInProduction
+	}
+
 	// create database schema before creating demo data
 	if err := CreateTables(db); err != nil {
-		return err
+		return nil, err
 	}
 
 	// read file that contains all queries  to create demo data
 	content, err := os.ReadFile(BasePath + "server/database/sql/seed.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read seed.sql file: %v", err)
+		return nil, fmt.Errorf("failed to read seed.sql file: %v", err)
 	}
 
-	queries := strings.TrimSpace(string(content))
-
-	// execute all queries to create demo data in the database
-	_, err = db.Exec(queries)
+	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("failed to isert demo data %q: %v\n", queries, err)
-		return err
+		return nil, fmt.Errorf("failed to start seeding: %w", err)
+	}
+	defer tx.Rollback()
+
+	var results []SeedResult
+	for _, stmt := range splitStatements(string(content)) {
+		result, err := tx.Exec(stmt)
+		if err != nil {
+			log.Printf("failed to insert demo data %q: %v\n", stmt, err)
+			return nil, err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count demo rows: %w", err)
+		}
+
+		seed := SeedResult{Table: insertTable(stmt), Inserted: inserted}
+		if rows := int64(countValueRows(stmt)); rows > inserted {
+			seed.Skipped = rows - inserted
+		}
+		results = append(results, seed)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save demo data: %w", err)
 	}
 
 	log.Println("Demo data created successfully")
-	return nil
+	return results, nil
+}
+
+// splitStatements splits a SQL script on the semicolons that end its
+// statements, ignoring semicolons in string literals and -- comments
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inLiteral, inComment := false, false
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case inComment:
+			if c == '\n' {
+				inComment = false
+				current.WriteByte(c)
+			}
+			continue
+		case !inLiteral && c == '-' && i+1 < len(script) && script[i+1] == '-':
+			inComment = true
+			continue
+		case c == '\'':
+			inLiteral = !inLiteral
+		case c == ';' && !inLiteral:
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+var insertTablePattern = regexp.MustCompile(`(?i)^INSERT\s+(?:OR\s+\w+\s+)?INTO\s+(\w+)`)
+
+// insertTable returns the table an INSERT statement writes to
+func insertTable(stmt string) string {
+	if m := insertTablePattern.FindStringSubmatch(stmt); m != nil {
+		return m[1]
+	}
+	return "?"
+}
+
+// countValueRows counts the row tuples in the VALUES list of an INSERT:
+// the top-level parentheses that follow VALUES or a comma
+func countValueRows(stmt string) int {
+	upper := strings.ToUpper(stmt)
+	start := strings.Index(upper, "VALUES")
+	if start < 0 {
+		return 0
+	}
+
+	rows, depth := 0, 0
+	inLiteral := false
+	expectRow := true // right after VALUES or a top-level comma
+	for _, c := range stmt[start+len("VALUES"):] {
+		switch {
+		case c == '\'':
+			inLiteral = !inLiteral
+		case inLiteral:
+		case c == '(':
+			if depth == 0 && expectRow {
+				rows++
+			}
+			depth++
+			expectRow = false
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			expectRow = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			if depth == 0 {
+				expectRow = false
+			}
+		}
+	}
+	return rows
 }
 
-// Drop all tables in the database.
+// ErrDropInProduction is returned by Drop when ENV=production. There is no
+// override: a production database is never dropped from the command line.
+var ErrDropInProduction = errors.New("refusing to drop the database with ENV=production")
+
+// DatabaseFile returns the SQLite file the application uses, or an error
+// when DB_DSN points somewhere else
+func DatabaseFile() (string, error) {
+	cfg := LoadConfig()
+	if cfg.Database.DSN != "" {
+		return "", fmt.Errorf("database is configured through DB_DSN; only the DB_PATH file can be dropped")
+	}
+	return BasePath + cfg.Database.Path, nil
+}
+
+// Drop deletes the database file, and with it every table. The -wal and
+// -shm files WAL mode keeps next to it go too: left behind, SQLite would
+// replay the old log into the next database created at the same path. It
+// refuses to run in production; asking the operator for confirmation is up
+// to the caller.
 func Drop() error {
-	err := os.Remove(BasePath + "server/database/database.db")
+	if LoadConfig().App.IsProduction {
+		return ErrDropInProduction
+	}
+	path, err := DatabaseFile()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
 	if err != nil {
 		log.Printf("failed to drop tables: %v\n", err)
 		return err
 	}
+	for _, sidecar := range []string{path + "-wal", path + "-shm"} {
+		if err := os.Remove(sidecar); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove %s: %v\n", sidecar, err)
+			return err
+		}
+	}
 
 	log.Println("Database schema dropped successfully")
 	return nil
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDropRemovesWALFiles(t *testing.T) {
+	path := useTestDatabase(t)
+	db, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE leftovers (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	// Closing the pool would checkpoint and delete the WAL, so keep it
+	// open the way a crashed server would leave it
+	defer db.Close()
+	for _, sidecar := range []string{path + "-wal", path + "-shm"} {
+		if _, err := os.Stat(sidecar); err != nil {
+			t.Fatalf("expected %s before dropping: %v", sidecar, err)
+		}
+	}
+
+	if err := Drop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{path, path + "-wal", path + "-shm"} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Drop (stat error %v)", file, err)
+		}
+	}
+}
+
+func TestDropWithoutWALFiles(t *testing.T) {
+	path := useTestDatabase(t)
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Drop(); err != nil {
+		t.Fatalf("Drop() = %v, want nil when there is no -wal or -shm file", err)
+	}
+}
+
+func TestDropRefusesProduction(t *testing.T) {
+	path := useTestDatabase(t)
+	t.Setenv("ENV", "production")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Drop(); !errors.Is(err, ErrDropInProduction) {
+		t.Fatalf("Drop() = %v, want ErrDropInProduction", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database was removed in production: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect hides the SQL differences between the supported databases.
+//
+// The queries are written for SQLite today. Those that need a dialect
+// variant before Postgres can be used are:
+//   - GROUP_CONCAT in the category labels of posts (queries/category_labels.go),
+//     which Postgres spells STRING_AGG, see GroupConcat
+//   - strftime() date formatting in models/post.go and models/comment.go,
+//     which Postgres spells to_char()
+//   - "?" placeholders everywhere, which Postgres writes as $1, $2..., see Rebind
+//   - INSERT OR IGNORE and PRAGMA table_info in migrations/migrator.go
+//   - AUTOINCREMENT and the PRAGMA in the migration files
+//
+// INSERT ... ON CONFLICT (...) DO UPDATE, used for reactions and sessions,
+// has the same syntax in both databases and needs no variant.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// DialectFor maps a database/sql driver name to its SQL dialect
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "sqlite3", "sqlite":
+		return DialectSQLite, nil
+	case "postgres", "pgx":
+		return DialectPostgres, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// GroupConcat aggregates expr into a single sep-separated string
+func (d Dialect) GroupConcat(expr, sep string) string {
+	quoted := "'" + strings.ReplaceAll(sep, "'", "''") + "'"
+	if d == DialectPostgres {
+		return "STRING_AGG(" + expr + ", " + quoted + ")"
+	}
+	return "GROUP_CONCAT(" + expr + ", " + quoted + ")"
+}
+
+// Rebind rewrites "?" placeholders into the dialect's style. Question
+// marks inside single-quoted literals are left untouched.
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var out strings.Builder
+	inLiteral, n := false, 0
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inLiteral = !inLiteral
+		case r == '?' && !inLiteral:
+			n++
+			out.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
@@ -3,8 +3,15 @@ package config
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"net/http"
+	"time"
+
+	"forum/server/clock"
 )
 
+// SessionCookieName is the cookie holding the session ID
+const SessionCookieName = "session_id"
+
 func GenerateSessionID() (string, error) {
 	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
@@ -12,3 +19,39 @@ func GenerateSessionID() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// SetSessionCookie writes the session cookie. Every place that sets or
+// refreshes the session goes through here so the attributes stay consistent:
+// not readable from JavaScript, not sent on cross-site POSTs, and HTTPS-only
+// when configured. A zero expires makes a browser-session cookie, dropped
+// when the browser closes. Max-Age is counted from c, the clock the
+// session's expiry was set by, so cookie and session end together.
+func SetSessionCookie(w http.ResponseWriter, cfg SessionConfig, c clock.Clock, sessionID string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if !expires.IsZero() {
+		cookie.Expires = expires
+		cookie.MaxAge = int(expires.Sub(c.Now()).Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
+
+// ClearSessionCookie tells the browser to drop the session cookie
+func ClearSessionCookie(w http.ResponseWriter, cfg SessionConfig) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   cfg.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+)
+
+func TestSetSessionCookieCountsMaxAgeFromTheClock(t *testing.T) {
+	// Far from the real time, so time.Now would give a very different Max-Age
+	now := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	expires := now.Now().Add(30 * 24 * time.Hour)
+
+	w := httptest.NewRecorder()
+	SetSessionCookie(w, SessionConfig{CookieSecure: true}, now, "session", expires)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("%d cookies set, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.MaxAge != 30*24*60*60 {
+		t.Errorf("Max-Age = %d, want %d", cookie.MaxAge, 30*24*60*60)
+	}
+	if !cookie.Expires.Equal(expires) {
+		t.Errorf("Expires = %v, want %v", cookie.Expires, expires)
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Errorf("cookie attributes = %+v", cookie)
+	}
+}
+
+func TestSetSessionCookieForTheBrowserSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetSessionCookie(w, SessionConfig{}, clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)), "session", time.Time{})
+
+	cookie := w.Result().Cookies()[0]
+	if cookie.MaxAge != 0 || !cookie.Expires.IsZero() {
+		t.Errorf("Max-Age = %d, Expires = %v, want a browser-session cookie", cookie.MaxAge, cookie.Expires)
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/server/commands"
+	"forum/server/queries"
+	"forum/server/utils"
+)
+
+// auditLogPageSize is how many entries one page of /admin/audit lists
+const auditLogPageSize = 50
+
+// AuditLog answers /admin/audit with one page (?page=N) of the audit log,
+// newest first, as a plain JSON array of AuditLogEntry (admin only). The
+// total and the links to other pages are in the pagination headers.
+func AuditLog(w http.ResponseWriter, r *http.Request, postQueries *queries.CachedPostQueryService) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	page := 1
+	if param := r.URL.Query().Get("page"); param != "" {
+		var err error
+		if page, err = strconv.Atoi(param); err != nil || page < 1 {
+			writeInvalid(w, &commands.ValidationError{Field: "page", Err: errors.New("page must be a positive number")})
+			return
+		}
+	}
+
+	entries, err := postQueries.GetAuditLog(r.Context(), auditLogPageSize, (page-1)*auditLogPageSize)
+	if err != nil {
+		log.Println("Error fetching the audit log:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	total, err := postQueries.CountAuditLog(r.Context())
+	if err != nil {
+		log.Println("Error counting audit log entries:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	setPageHeaders(w, r, queries.NewPageMeta(total, page, auditLogPageSize), "page")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
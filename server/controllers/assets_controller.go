@@ -1,26 +1,102 @@
 package controllers
 
 import (
+	"database/sql"
+	"io/fs"
+	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"forum/server/clock"
 	"forum/server/config"
 	"forum/server/utils"
 )
 
-// ServeStaticFiles returns a handler function for serving static files
-func ServeStaticFiles(w http.ResponseWriter, r *http.Request) {
-	// Get clean file path and prevent directory traversal
-	filePath := filepath.Clean(config.BasePath + "web/assets" + strings.TrimPrefix(r.URL.Path, "/assets"))
+// assetsCacheControl lets browsers reuse assets for an hour. File names are
+// not fingerprinted, so this stays short enough for deploys to show up.
+const assetsCacheControl = "public, max-age=3600"
 
-	// block access to dirictories
-	if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+// assetsFS restricts an http.Dir to regular files: directories behave as
+// if they did not exist, so http.FileServer never lists them
+type assetsFS struct {
+	root http.Dir
+}
+
+func (a assetsFS) Open(name string) (http.File, error) {
+	f, err := a.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+	return f, nil
+}
+
+// ServeStaticFiles serves /assets/ from dir (config WebConfig.AssetsDir).
+// http.Dir keeps every lookup inside that directory; requests containing
+// ".." are refused outright, and directories and missing files get the 404
+// page.
+func ServeStaticFiles(w http.ResponseWriter, r *http.Request, dir string) {
+	name := strings.TrimPrefix(r.URL.Path, "/assets")
+	files := assetsFS{root: http.Dir(dir)}
+
+	if containsDotDot(name) {
+		utils.RenderError(nil, w, r, http.StatusNotFound, false, "")
+		return
+	}
+	f, err := files.Open(name)
+	if err != nil {
 		utils.RenderError(nil, w, r, http.StatusNotFound, false, "")
 		return
 	}
+	f.Close()
+
+	w.Header().Set("Cache-Control", assetsCacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.StripPrefix("/assets", http.FileServer(files)).ServeHTTP(w, r)
+}
 
-	// Serve the file
-	http.ServeFile(w, r, filePath)
+// SPAFallback is the catch-all route when WEB_SPA_FALLBACK is on: GET and
+// HEAD requests for pages the server does not know get web.SPAIndex, and
+// the client-side router decides what to show. API paths, JSON clients and
+// other methods still get the usual 404.
+func SPAFallback(w http.ResponseWriter, r *http.Request, db *sql.DB, web config.WebConfig, c clock.Clock) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+		strings.HasPrefix(r.URL.Path, "/api/") || utils.WantsJSON(r) {
+		NotFound(w, r, db, c)
+		return
+	}
+
+	f, err := os.Open(web.SPAIndex)
+	if err != nil {
+		log.Println("Error opening the SPA index:", err)
+		NotFound(w, r, db, c)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		NotFound(w, r, db, c)
+		return
+	}
+
+	// Revalidate every time so a deploy is picked up straight away
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "index.html", info.ModTime(), f)
+}
+
+// containsDotDot reports whether any element of the slash separated path
+// is "..". Backslashes count as separators too, for Windows.
+func containsDotDot(p string) bool {
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"forum/server/utils"
+)
+
+// newAssetsDir returns an assets directory holding css/app.css, next to a
+// file that must stay out of reach
+func newAssetsDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "assets")
+	if err := os.MkdirAll(filepath.Join(dir, "css"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "css", "app.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestServeStaticFiles(t *testing.T) {
+	utils.SetTemplatesDir("../../web/templates")
+	dir := newAssetsDir(t)
+
+	w := httptest.NewRecorder()
+	ServeStaticFiles(w, httptest.NewRequest(http.MethodGet, "/assets/css/app.css", nil), dir)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+		t.Errorf("Content-Type = %q, want text/css", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != assetsCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, assetsCacheControl)
+	}
+	if w.Body.String() != "body{}" {
+		t.Errorf("body = %q", w.Body.String())
+	}
+}
+
+func TestServeStaticFilesNotFound(t *testing.T) {
+	utils.SetTemplatesDir("../../web/templates")
+	dir := newAssetsDir(t)
+
+	for _, path := range []string{
+		"/assets/missing.css",
+		"/assets/css/",          // a directory is never listed
+		"/assets/../secret.txt", // escapes the assets directory
+		"/assets/css/../../secret.txt",
+	} {
+		w := httptest.NewRecorder()
+		// Set the path directly: a browser would clean the dots away
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = path
+		ServeStaticFiles(w, r, dir)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+		if body := w.Body.String(); strings.Contains(body, "secret") || strings.Contains(body, `href="app.css"`) {
+			t.Errorf("%s: body leaks a file or a directory listing", path)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/server/clock"
+	"forum/server/commands"
+	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/utils"
+)
+
+// categoriesPageSize is how many categories one page of /categories lists
+const categoriesPageSize = 30
+
+// categoriesPage is the template data of /categories. PrevPage and
+// NextPage are zero when there is no such page.
+type categoriesPage struct {
+	Categories []queries.CategorySummary
+	Page       queries.PageMeta
+	Sort       queries.CategorySort
+	PrevPage   int
+	NextPage   int
+}
+
+// IndexCategories renders /categories: the categories with their post
+// counts, one page (?page=N) at a time, by name or, with ?sort=posts, the
+// most active first. The sidebar still lists every category.
+func IndexCategories(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, c clock.Clock) {
+	_, username, valid := models.ValidSession(r, db, c)
+
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(db, w, r, http.MethodGet)
+		return
+	}
+
+	sort, err := queries.ParseCategorySort(r.URL.Query().Get("sort"), queries.DefaultCategorySort)
+	if err != nil {
+		utils.RenderError(db, w, r, http.StatusBadRequest, valid, username)
+		return
+	}
+	page := 1
+	if param := r.URL.Query().Get("page"); param != "" {
+		if page, err = strconv.Atoi(param); err != nil || page < 1 {
+			utils.RenderError(db, w, r, http.StatusBadRequest, valid, username)
+			return
+		}
+	}
+
+	total, err := postQueries.CountCategories(r.Context())
+	if err != nil {
+		log.Println("Error counting categories:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+		return
+	}
+	meta := queries.NewPageMeta(total, page, categoriesPageSize)
+	if page > meta.TotalPages {
+		utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
+		return
+	}
+
+	categories, err := postQueries.GetCategoriesPage(r.Context(), sort, categoriesPageSize, (page-1)*categoriesPageSize)
+	if err != nil {
+		log.Println("Error fetching categories:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+		return
+	}
+
+	data := categoriesPage{Categories: categories, Page: meta, Sort: sort}
+	if page > 1 {
+		data.PrevPage = page - 1
+	}
+	if page < meta.TotalPages {
+		data.NextPage = page + 1
+	}
+	setPageHeaders(w, r, meta, "page")
+
+	if err := utils.RenderTemplate(db, w, r, "categories", http.StatusOK, data, valid, username); err != nil {
+		log.Println("Error rendering template:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+	}
+}
+
+// CreateCategory adds a category (admin only, form field "label")
+func CreateCategory(w http.ResponseWriter, r *http.Request, categories *commands.CategoryCommandHandler, postQueries *queries.CachedPostQueryService) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	result, err := categories.CreateCategory(commands.CreateCategoryCommand{
+		UserID: user.ID,
+		Label:  r.FormValue("label"),
+		Reason: r.FormValue("reason"),
+	})
+	writeCategoryResult(w, result, err, postQueries)
+}
+
+// RenameCategory changes a category label (admin only, form fields "id"
+// and "label")
+func RenameCategory(w http.ResponseWriter, r *http.Request, categories *commands.CategoryCommandHandler, postQueries *queries.CachedPostQueryService) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	categoryID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid category ID")
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	result, err := categories.RenameCategory(commands.RenameCategoryCommand{
+		UserID:     user.ID,
+		CategoryID: categoryID,
+		Label:      r.FormValue("label"),
+		Reason:     r.FormValue("reason"),
+	})
+	writeCategoryResult(w, result, err, postQueries)
+}
+
+// DeleteCategory removes a category (admin only, form field "id"). Pass
+// force=true to also unlink it from the posts that still use it.
+func DeleteCategory(w http.ResponseWriter, r *http.Request, categories *commands.CategoryCommandHandler, postQueries *queries.CachedPostQueryService) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	categoryID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid category ID")
+		return
+	}
+	force, _ := strconv.ParseBool(r.FormValue("force"))
+
+	user, _ := utils.UserFromContext(r.Context())
+	result, err := categories.DeleteCategory(commands.DeleteCategoryCommand{
+		UserID:     user.ID,
+		CategoryID: categoryID,
+		Force:      force,
+		Reason:     r.FormValue("reason"),
+	})
+	writeCategoryResult(w, result, err, postQueries)
+}
+
+// AdminStats answers with the statistics community managers look at (admin
+// only): for now, the engagement of every category, most engaged first.
+// The figures may be up to CACHE_STATS_TTL old.
+func AdminStats(w http.ResponseWriter, r *http.Request, postQueries *queries.CachedPostQueryService) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	engagement, err := postQueries.GetCategoryEngagement(r.Context())
+	if err != nil {
+		log.Println("Error fetching category engagement:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"categories": engagement,
+	})
+}
+
+// writeCategoryResult sends a category command result as JSON and drops
+// cached category data after a successful change
+func writeCategoryResult(w http.ResponseWriter, result *commands.CommandResult, err error, postQueries *queries.CachedPostQueryService) {
+	if err != nil {
+		log.Println("Error updating categories:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if result.Success {
+		postQueries.InvalidateCategoryCache()
+		utils.InvalidateNavCategories()
+	}
+	writeResult(w, result)
+}
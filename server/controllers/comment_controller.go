@@ -3,91 +3,153 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"forum/server/commands"
+	"forum/server/config"
 	"forum/server/models"
+	"forum/server/realtime"
+	"forum/server/utils"
 )
 
-func CreateComment(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	// Validate session
-	userID, username, valid := models.ValidSession(r, db)
-	if !valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+func CreateComment(w http.ResponseWriter, r *http.Request, db *sql.DB, events realtime.Publisher, content config.ContentConfig, wordFilter *utils.WordFilter) {
+	// RequireAuth has already checked the session
+	user, _ := utils.UserFromContext(r.Context())
+	userID, username := user.ID, user.Username
 
 	// Validate method
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		utils.JSONError(w, http.StatusBadRequest, "invalid form")
 		return
 	}
 
-	content := html.EscapeString(strings.TrimSpace(r.FormValue("comment")))
+	comment := r.FormValue("comment")
+	if err := commands.CleanContent("comment", "comment", &comment, content.InvisibleChars); err != nil {
+		writeInvalid(w, err)
+		return
+	}
+	comment = html.EscapeString(strings.TrimSpace(comment))
 	postIDStr := r.FormValue("postid")
 	postID, err := strconv.Atoi(postIDStr)
-	if err != nil || content == "" {
-		w.WriteHeader(http.StatusBadRequest)
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+	if comment == "" {
+		writeInvalid(w, &commands.ValidationError{Field: "comment", Err: errors.New("comment is required")})
+		return
+	}
+	if err := commands.ValidateLength("comment", "comment", html.UnescapeString(comment), content.CommentMinLength, content.CommentMaxLength); err != nil {
+		writeInvalid(w, err)
+		return
+	}
+	if err := filterEscaped(wordFilter, &comment, "comment", "comment"); err != nil {
+		writeInvalid(w, err)
+		return
+	}
+
+	// Locked posts keep their comments but take no new ones
+	locked, err := models.IsPostLocked(db, postID)
+	if errors.Is(err, models.ErrPostNotFound) {
+		utils.JSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	if err != nil {
+		log.Println("Error checking the post lock:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if locked {
+		utils.JSONError(w, http.StatusForbidden, "Comments are closed on this post")
+		return
+	}
+
+	// Catch the same comment sent twice, e.g. by a double click
+	repeated, err := models.IsRepeatComment(db, userID, postID, comment, content.DuplicateCommentWindow)
+	if err != nil {
+		log.Println("Error checking for a repeated comment:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if repeated {
+		utils.JSONError(w, http.StatusConflict, "You just posted that")
 		return
 	}
 
 	// Store the comment using the models package
-	commentID, err := models.StoreComment(db, userID, postID, content)
+	commentID, err := models.StoreComment(db, userID, postID, comment, content.MaxCommentsPerPost)
+	if errors.Is(err, models.ErrCommentLimit) {
+		utils.JSONError(w, http.StatusForbidden, fmt.Sprintf("This post has reached the limit of %d comments", content.MaxCommentsPerPost))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		utils.JSONError(w, http.StatusInternalServerError, "")
 		return
 	}
 
 	// Fetch additional details using the models package
 	commentsCount, err := models.CountCommentsByPostID(db, postID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		utils.JSONError(w, http.StatusInternalServerError, "")
 		return
 	}
 
 	commentTime, err := models.FetchCommentTimeByID(db, commentID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		utils.JSONError(w, http.StatusInternalServerError, "")
 		return
 	}
 
+	// Push the comment to everyone reading the post
+	events.Publish(realtime.Event{
+		Type:   realtime.EventComment,
+		PostID: postID,
+		Data: realtime.CommentData{
+			ID:             commentID,
+			AuthorID:       userID,
+			AuthorUsername: username,
+			Content:        comment,
+			CreatedAt:      commentTime,
+			CommentCount:   commentsCount,
+		},
+	})
+
 	// Return the new comment details as JSON
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"ID":            commentID,
 		"username":      username,
 		"created_at":    commentTime,
-		"content":       content,
+		"content":       comment,
 		"likes":         0,
 		"dislikes":      0,
 		"commentscount": commentsCount,
 	})
 }
 
-func ReactToComment(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func ReactToComment(w http.ResponseWriter, r *http.Request, db *sql.DB, events realtime.Publisher, content config.ContentConfig) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 
-	var user_id int
-	var valid bool
-
-	if user_id, _, valid = models.ValidSession(r, db); !valid {
-		w.WriteHeader(401)
-		return
-	}
+	user, _ := utils.UserFromContext(r.Context())
+	user_id := user.ID
 
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(400)
+		utils.JSONError(w, 400, "invalid form")
 		return
 	}
 
@@ -95,14 +157,34 @@ func ReactToComment(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	id := r.FormValue("comment_id")
 	comment_id, err := strconv.Atoi(id)
 	if err != nil {
-		w.WriteHeader(400)
+		utils.JSONError(w, 400, "invalid comment ID")
+		return
+	}
+	likeCount, dislikeCount, err := models.ReactToComment(db, content, user_id, comment_id, userReaction)
+	if errors.Is(err, models.ErrCommentNotFound) {
+		utils.JSONError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+	if errors.Is(err, models.ErrSelfReaction) {
+		utils.JSONError(w, http.StatusForbidden, "you cannot react to your own comment")
 		return
 	}
-	likeCount, dislikeCount, err := models.ReactToComment(db, user_id, comment_id, userReaction)
 	if err != nil {
-		w.WriteHeader(500)
+		utils.JSONError(w, 500, "")
 		return
 	}
+
+	// Live updates are best effort
+	if postID, err := models.FetchCommentPostID(db, comment_id); err != nil {
+		log.Println("Live update error:", err)
+	} else {
+		events.Publish(realtime.Event{
+			Type:   realtime.EventCommentReaction,
+			PostID: postID,
+			Data:   realtime.ReactionData{ID: comment_id, LikeCount: likeCount, DislikeCount: dislikeCount},
+		})
+	}
+
 	// Return the new count as JSON
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"commentlikesCount": likeCount, "commentdislikesCount": dislikeCount})
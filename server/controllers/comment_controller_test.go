@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/testutil"
+	"forum/server/utils"
+)
+
+func TestCreateCommentOnDraftIsNotFound(t *testing.T) {
+	db := testutil.NewDB(t)
+	limits := config.LoadConfig().Content
+	draftID, err := models.StorePost(db, 1, "A draft", "Not published yet", true, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	CreateComment(w, postForm("/post/addcommentREQ", utils.CurrentUser{ID: 2, Username: "bob"}, url.Values{
+		"postid":  {strconv.FormatInt(draftID, 10)},
+		"comment": {"Sneaky comment"},
+	}), db, testutil.NopPublisher{}, limits, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNotFound, w.Body.String())
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = ?", draftID).Scan(&count)
+	if count != 0 {
+		t.Errorf("the comment was stored")
+	}
+}
+
+func TestCreateCommentLimit(t *testing.T) {
+	db := testutil.NewDB(t)
+	limits := config.LoadConfig().Content
+	// Seeded post 1 already has two comments
+	limits.MaxCommentsPerPost = 2
+
+	w := httptest.NewRecorder()
+	CreateComment(w, postForm("/post/addcommentREQ", utils.CurrentUser{ID: 4, Username: "diana"}, url.Values{
+		"postid":  {"1"},
+		"comment": {"One comment too many"},
+	}), db, testutil.NopPublisher{}, limits, nil)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "limit of 2 comments") {
+		t.Errorf("body = %q, want the limit in the message", w.Body.String())
+	}
+}
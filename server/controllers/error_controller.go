@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"forum/server/clock"
+	"forum/server/commands"
+	"forum/server/models"
+	"forum/server/utils"
+)
+
+// NotFound is the catch-all for paths no other route matches. It renders
+// the themed 404 page (or JSON for API clients) instead of the plain text
+// default of http.ServeMux.
+func NotFound(w http.ResponseWriter, r *http.Request, db *sql.DB, c clock.Clock) {
+	_, username, valid := models.ValidSession(r, db, c)
+	utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
+}
+
+// writeResult answers with a command result as JSON: the result itself on
+// success, the error envelope with the matching status otherwise
+func writeResult(w http.ResponseWriter, result *commands.CommandResult) {
+	if !result.Success {
+		utils.WriteJSONError(w, result.HTTPStatus(), result.APIError())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeInvalid answers 400 with a validation error from the command layer
+func writeInvalid(w http.ResponseWriter, err error) {
+	utils.WriteJSONError(w, http.StatusBadRequest, commands.InvalidInput(err))
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"forum/server/utils"
+)
+
+// postForm builds a form POST made by the logged-in user
+func postForm(path string, user utils.CurrentUser, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(utils.WithUser(r.Context(), user))
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/server/models"
+	"forum/server/realtime"
+)
+
+// livePingInterval keeps idle connections (and proxies in between) alive
+const livePingInterval = 30 * time.Second
+
+// PostUpdates upgrades to a WebSocket and streams new comments and
+// reaction counts of a published post until the client goes away
+func PostUpdates(w http.ResponseWriter, r *http.Request, db *sql.DB, hub *realtime.Hub) {
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || postID <= 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	published, err := models.PostIsPublished(db, postID)
+	if err != nil {
+		log.Println("Error checking post:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !published {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// Subscribe before upgrading so a full post gets a plain HTTP error
+	sub, err := hub.Subscribe(postID)
+	if errors.Is(err, realtime.ErrTooManySubscribers) || errors.Is(err, realtime.ErrHubClosed) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Println("Error subscribing to live updates:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := realtime.Upgrade(w, r)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+
+	// The read loop ends when the client disconnects
+	gone := make(chan struct{})
+	go func() {
+		conn.ReadLoop()
+		close(gone)
+	}()
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, or shutting down
+				return
+			}
+			message, err := json.Marshal(event)
+			if err != nil {
+				log.Println("Error encoding live update:", err)
+				continue
+			}
+			if err := conn.WriteText(message); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"time"
 
+	"forum/server/clock"
+	"forum/server/commands"
 	"forum/server/config"
 	"forum/server/models"
 	"forum/server/utils"
@@ -13,10 +15,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func GetLoginPage(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func GetLoginPage(w http.ResponseWriter, r *http.Request, db *sql.DB, c clock.Clock) {
 	var valid bool
 
-	if _, _, valid = models.ValidSession(r, db); valid {
+	if _, _, valid = models.ValidSession(r, db, c); valid {
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
 	}
@@ -33,16 +35,16 @@ func GetLoginPage(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	}
 }
 
-func Signin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func Signin(w http.ResponseWriter, r *http.Request, db *sql.DB, session config.SessionConfig, c clock.Clock, monitor *utils.LoginMonitor) {
 	var valid bool
 
-	if _, _, valid = models.ValidSession(r, db); valid {
+	if _, _, valid = models.ValidSession(r, db, c); valid {
 		w.WriteHeader(302)
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		w.WriteHeader(405)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 
@@ -51,19 +53,26 @@ func Signin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	username := r.FormValue("username")
+	username := commands.NormalizeUsername(r.FormValue("username"))
 	password := r.FormValue("password")
+	remember := r.FormValue("remember") == "true"
 
-	if len(username) < 4 || len(password) < 6 {
+	ip := utils.ClientIP(r)
+
+	if len(username) < 3 || len(password) < 6 {
+		monitor.Failed(ip, username, "invalid input")
 		w.WriteHeader(400)
 		return
 	}
 
-	// get user information from database
+	// get user information from database. An unknown user gets the same
+	// answer as a wrong password, after as long a wait.
 	user_id, hashedPassword, err := models.GetUserInfo(db, username)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			w.WriteHeader(404)
+			commands.DummyPasswordCheck(password)
+			monitor.Failed(ip, username, "unknown user")
+			w.WriteHeader(401)
 			return
 		}
 		w.WriteHeader(500)
@@ -72,9 +81,11 @@ func Signin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 
 	// Verify the password
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		monitor.Failed(ip, username, "wrong password")
 		w.WriteHeader(401)
 		return
 	}
+	monitor.Succeeded(ip)
 
 	sessionID, err := config.GenerateSessionID()
 	if err != nil {
@@ -82,30 +93,33 @@ func Signin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	err = models.StoreSession(db, user_id, sessionID, time.Now().Add(10*time.Hour))
+	// A remembered session lasts its full lifetime in a persistent cookie;
+	// otherwise the cookie ends with the browser and the session slides
+	now := c.Now()
+	expires, cookieExpires := now.Add(session.IdleTimeout), time.Time{}
+	if remember {
+		expires = now.Add(session.RememberMe)
+		cookieExpires = expires
+	}
+	err = models.StoreSession(db, user_id, sessionID, now, expires, remember)
 	if err != nil {
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
-	// Set session ID as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:    "session_id",
-		Value:   sessionID,
-		Expires: time.Now().Add(10 * time.Hour),
-		Path:    "/",
-	})
+	config.SetSessionCookie(w, session, c, sessionID, cookieExpires)
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-func Logout(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	if userID, _, valid := models.ValidSession(r, db); valid {
+func Logout(w http.ResponseWriter, r *http.Request, db *sql.DB, session config.SessionConfig, c clock.Clock) {
+	if userID, _, valid := models.ValidSession(r, db, c); valid {
 		// Use the new model function
 		err := models.DeleteUserSession(db, userID)
 		if err != nil {
 			http.Error(w, "Error while logging out!", http.StatusInternalServerError)
 			return
 		}
+		config.ClearSessionCookie(w, session)
 
 		w.Header().Set("Content-Type", "text/html")
 		http.Redirect(w, r, "/", http.StatusFound)
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/testutil"
+	"forum/server/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSigninCookieAndSessionShareTheClock(t *testing.T) {
+	db := testutil.NewDB(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE users SET password = ? WHERE username = 'alice'", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+	session := config.SessionConfig{IdleTimeout: time.Hour, RememberMe: 30 * 24 * time.Hour, MaxLifetime: 24 * time.Hour}
+	now := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	form := url.Values{"username": {"alice"}, "password": {"secret123"}, "remember": {"true"}}
+	r := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	Signin(w, r, db, session, now, utils.NewLoginMonitor(utils.NewLogger(&bytes.Buffer{}, "info"), time.Minute))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("%d cookies set, want 1", len(cookies))
+	}
+	want := now.Now().Add(session.RememberMe)
+	if cookies[0].MaxAge != int(session.RememberMe.Seconds()) || !cookies[0].Expires.Equal(want) {
+		t.Errorf("cookie Max-Age = %d, Expires = %v, want %d and %v", cookies[0].MaxAge, cookies[0].Expires, int(session.RememberMe.Seconds()), want)
+	}
+
+	var expiresAt time.Time
+	if err := db.QueryRow("SELECT expires_at FROM sessions WHERE session_id = ?", cookies[0].Value).Scan(&expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if !expiresAt.Equal(want) {
+		t.Errorf("session expires at %v, want %v like the cookie", expiresAt, want)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/server/commands"
+	"forum/server/queries"
+	"forum/server/utils"
+)
+
+// ShowNotifications lists the current user's unread notifications
+func ShowNotifications(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService) {
+	// RequireAuth has already checked the session
+	user, _ := utils.UserFromContext(r.Context())
+
+	if r.Method != http.MethodGet {
+		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, true, user.Username)
+		return
+	}
+
+	notifications, err := postQueries.GetUnreadNotifications(user.ID)
+	if err != nil {
+		log.Println("Error fetching notifications:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, true, user.Username)
+		return
+	}
+
+	if err := utils.RenderTemplate(db, w, r, "notifications", http.StatusOK, notifications, true, user.Username); err != nil {
+		log.Println("Error rendering template:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, true, user.Username)
+	}
+}
+
+// MarkNotificationRead marks one notification as read (form field "id")
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request, notifications *commands.NotificationCommandHandler) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	notificationID, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid notification ID")
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	result, err := notifications.MarkNotificationRead(commands.MarkNotificationReadCommand{
+		UserID:         user.ID,
+		NotificationID: notificationID,
+	})
+	if err != nil {
+		log.Println("Error marking notification read:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	writeResult(w, result)
+}
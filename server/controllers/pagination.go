@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/server/queries"
+)
+
+// paginationHeaders are the headers setPageHeaders writes. Scripts on
+// another origin can only read them when they are listed in
+// Access-Control-Expose-Headers.
+var paginationHeaders = []string{"X-Total-Count", "X-Page", "X-Page-Size", "Link"}
+
+// setPageHeaders describes where a page of a listing sits in response
+// headers, so list bodies can stay plain arrays: X-Total-Count, X-Page and
+// X-Page-Size from meta, and a Link header (RFC 8288, as GitHub sends it)
+// with next, prev, first and last URLs. The URLs repeat the request with
+// the page number in the query parameter param.
+func setPageHeaders(w http.ResponseWriter, r *http.Request, meta queries.PageMeta, param string) {
+	h := w.Header()
+	h.Set("X-Total-Count", strconv.Itoa(meta.TotalItems))
+	h.Set("X-Page", strconv.Itoa(meta.Page))
+	h.Set("X-Page-Size", strconv.Itoa(meta.PageSize))
+
+	var links []string
+	link := func(page int, rel string) {
+		query := r.URL.Query()
+		query.Set(param, strconv.Itoa(page))
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, query.Encode(), rel))
+	}
+	if meta.Page < meta.TotalPages {
+		link(meta.Page+1, "next")
+	}
+	if meta.Page > 1 {
+		link(min(meta.Page-1, meta.TotalPages), "prev")
+	}
+	link(1, "first")
+	link(meta.TotalPages, "last")
+	h.Set("Link", strings.Join(links, ", "))
+
+	h.Add("Access-Control-Expose-Headers", strings.Join(paginationHeaders, ", "))
+}
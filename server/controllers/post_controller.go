@@ -3,24 +3,49 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"html"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"forum/server/clock"
+	"forum/server/commands"
+	"forum/server/config"
 	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/realtime"
 	"forum/server/utils"
 )
 
-func IndexPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+// postsPage is the template data for paginated post listings. Page is left
+// zero when the total is unknown, which hides the "of N" part of the pager;
+// HasMore is always set and enables its "Next" link. Truncated marks the
+// last page of a listing cut short by HOMEPAGE_POST_LIMIT.
+type postsPage struct {
+	Posts     []models.Post
+	Page      queries.PageMeta
+	HasMore   bool
+	Truncated bool
+}
+
+// pageSize matches the LIMIT used by the models listing queries
+const pageSize = models.PostsPageSize
+
+func IndexPosts(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, content config.ContentConfig, c clock.Clock) {
 	var valid bool
 	var username string
-	_, username, valid = models.ValidSession(r, db)
+	_, username, valid = models.ValidSession(r, db, c)
 
-	if r.URL.Path != "/" || r.Method != http.MethodGet {
+	if r.URL.Path != "/" {
 		utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
 		return
 	}
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(db, w, r, http.MethodGet)
+		return
+	}
 	id := r.FormValue("PageID")
 	page, er := strconv.Atoi(id)
 	if er != nil && id != "" {
@@ -31,7 +56,13 @@ func IndexPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	if page < 0 {
 		page = 0
 	}
-	posts, statusCode, err := models.FetchPosts(db, page)
+	// The homepage pages through the newest HOMEPAGE_POST_LIMIT posts only
+	limit := content.HomepagePostLimit
+	if limit > 0 && page >= limit {
+		utils.RenderError(db, w, r, 404, valid, username)
+		return
+	}
+	posts, hasMore, statusCode, err := models.FetchPosts(r.Context(), db, page, content.ExcludeSelfReactions())
 	if err != nil {
 		log.Println("Error fetching posts:", err)
 		utils.RenderError(db, w, r, statusCode, valid, username)
@@ -42,17 +73,75 @@ func IndexPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	if err := utils.RenderTemplate(db, w, r, "home", statusCode, posts, valid, username); err != nil {
+	data := postsPage{Posts: posts, HasMore: hasMore}
+	if limit > 0 && page+len(posts) >= limit {
+		// FetchPosts reads one post past the page, so a page that ends
+		// at the limit still sees whether older posts were left out
+		data.Truncated = hasMore || len(posts) > limit-page
+		data.Posts = posts[:limit-page]
+		data.HasMore = false
+	}
+	if total, err := postQueries.CountPosts(r.Context()); err != nil {
+		log.Println("Error counting posts:", err)
+	} else {
+		if limit > 0 && total > limit {
+			total = limit
+		}
+		data.Page = queries.NewPageMeta(total, page/pageSize+1, pageSize)
+		setPageHeaders(w, r, data.Page, "PageID")
+	}
+
+	if err := utils.RenderTemplate(db, w, r, "home", statusCode, data, valid, username); err != nil {
 		log.Println("Error rendering template:", err)
 		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 }
 
-func IndexPostsByCategory(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+// apiPostsPageSize is how many posts one page of /api/posts lists
+const apiPostsPageSize = 20
+
+// ListPosts answers /api/posts with one page (?page=N) of published posts,
+// newest first, as a plain JSON array of PostListItem. The total and the
+// links to other pages are in the pagination headers, see setPageHeaders.
+func ListPosts(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, c clock.Clock) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	page := 1
+	if param := r.URL.Query().Get("page"); param != "" {
+		var err error
+		if page, err = strconv.Atoi(param); err != nil || page < 1 {
+			writeInvalid(w, &commands.ValidationError{Field: "page", Err: errors.New("page must be a positive number")})
+			return
+		}
+	}
+
+	viewerID, _, _ := models.ValidSession(r, db, c)
+	posts, err := postQueries.GetPostsPage(r.Context(), viewerID, apiPostsPageSize, (page-1)*apiPostsPageSize)
+	if err != nil {
+		log.Println("Error fetching posts:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	total, err := postQueries.CountPosts(r.Context())
+	if err != nil {
+		log.Println("Error counting posts:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	setPageHeaders(w, r, queries.NewPageMeta(total, page, apiPostsPageSize), "page")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
+
+func IndexPostsByCategory(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, content config.ContentConfig, c clock.Clock) {
 	var valid bool
 	var username string
-	_, username, valid = models.ValidSession(r, db)
+	_, username, valid = models.ValidSession(r, db, c)
 
 	if r.Method != http.MethodGet {
 		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, valid, username)
@@ -69,7 +158,36 @@ func IndexPostsByCategory(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		utils.RenderError(db, w, r, 404, valid, username)
 		return
 	}
-	
+
+	renderCategoryPosts(w, r, db, postQueries, content, id, valid, username)
+}
+
+// IndexPostsByCategorySlug serves /c/{slug}, the readable alias of
+// /category/{id}
+func IndexPostsByCategorySlug(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, content config.ContentConfig, c clock.Clock) {
+	_, username, valid := models.ValidSession(r, db, c)
+
+	if r.Method != http.MethodGet {
+		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, valid, username)
+		return
+	}
+
+	id, err := postQueries.GetCategoryIDBySlug(r.PathValue("slug"))
+	if err != nil {
+		if errors.Is(err, queries.ErrCategoryNotFound) {
+			utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
+			return
+		}
+		log.Println("Error looking up category:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+		return
+	}
+
+	renderCategoryPosts(w, r, db, postQueries, content, id, valid, username)
+}
+
+// renderCategoryPosts renders one page of the posts in an existing category
+func renderCategoryPosts(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, content config.ContentConfig, id int, valid bool, username string) {
 	pid := r.FormValue("PageID")
 	page, _ := strconv.Atoi(pid)
 	page = (page - 1) * 10
@@ -77,7 +195,7 @@ func IndexPostsByCategory(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		page = 0
 	}
 
-	posts, statusCode, err := models.FetchPostsByCategory(db, id, page)
+	posts, hasMore, statusCode, err := models.FetchPostsByCategory(db, id, page, content.ExcludeSelfReactions())
 	if err != nil {
 		log.Println("Error fetching posts:", err)
 		utils.RenderError(db, w, r, statusCode, valid, username)
@@ -89,17 +207,26 @@ func IndexPostsByCategory(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	if err := utils.RenderTemplate(db, w, r, "home", statusCode, posts, valid, username); err != nil {
+	data := postsPage{Posts: posts, HasMore: hasMore}
+	if total, err := postQueries.CountPostsByCategory(id); err != nil {
+		log.Println("Error counting posts:", err)
+	} else {
+		data.Page = queries.NewPageMeta(total, page/pageSize+1, pageSize)
+		setPageHeaders(w, r, data.Page, "PageID")
+	}
+
+	if err := utils.RenderTemplate(db, w, r, "home", statusCode, data, valid, username); err != nil {
 		log.Println("Error rendering template:", err)
 		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 }
 
-func ShowPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func ShowPost(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig, c clock.Clock) {
 	var valid bool
 	var username string
-	_, username, valid = models.ValidSession(r, db)
+	var user_id int
+	user_id, username, valid = models.ValidSession(r, db, c)
 
 	if r.Method != http.MethodGet {
 		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, valid, username)
@@ -110,10 +237,20 @@ func ShowPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		utils.RenderError(db, w, r, http.StatusBadRequest, valid, username)
 		return
 	}
-	post, statusCode, err := models.FetchPost(db, postID)
+	// The post page has always listed the newest comments first
+	sort, err := queries.ParseCommentSort(r.URL.Query().Get("sort"), queries.CommentSortNewest)
 	if err != nil {
+		utils.RenderError(db, w, r, http.StatusBadRequest, valid, username)
+		return
+	}
+	post, statusCode, err := models.FetchPost(r.Context(), db, postID, user_id, sort, content.ExcludeSelfReactions())
+	if err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
+			return
+		}
 		log.Println("Error fetching posts from the database:", err)
-		utils.RenderError(db, w, r, statusCode, valid, username)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 
@@ -124,38 +261,29 @@ func ShowPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	}
 }
 
-func GetPostCreationForm(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var valid bool
-	var username string
-
-	if _, username, valid = models.ValidSession(r, db); !valid {
-		http.Redirect(w, r, "/login", http.StatusFound)
-		return
-	}
+func GetPostCreationForm(w http.ResponseWriter, r *http.Request, db *sql.DB, limits config.ContentConfig) {
+	// RequireAuth has already checked the session
+	user, _ := utils.UserFromContext(r.Context())
+	username, valid := user.Username, true
 
 	if r.Method != http.MethodGet {
 		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, valid, username)
 		return
 	}
 
-	if err := utils.RenderTemplate(db, w, r, "post-form", http.StatusOK, nil, valid, username); err != nil {
+	if err := utils.RenderTemplate(db, w, r, "post-form", http.StatusOK, limits, valid, username); err != nil {
 		log.Println("Error rendering template:", err)
 		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 }
 
-func CreatePost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var user_id int
-	var valid bool
-
-	if user_id, _, valid = models.ValidSession(r, db); !valid {
-		w.WriteHeader(401)
-		return
-	}
+func CreatePost(w http.ResponseWriter, r *http.Request, db *sql.DB, limits config.ContentConfig, wordFilter *utils.WordFilter) {
+	user, _ := utils.UserFromContext(r.Context())
+	user_id := user.ID
 
 	if r.Method != http.MethodPost {
-		w.WriteHeader(405)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 
@@ -167,13 +295,20 @@ func CreatePost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	title := r.FormValue("title")
 	content := r.FormValue("content")
 	catids := r.Form["categories"]
+	draft := r.FormValue("draft") == "true"
 
+	// The form joins the selected IDs into one field; a missing or empty
+	// one is a post without categories
+	if len(catids) == 0 || strings.TrimSpace(catids[0]) == "" {
+		http.Error(w, commands.ValidateCategoryIDs(nil, limits.MaxCategoriesPerPost).Error(), 400)
+		return
+	}
 	catids = strings.Split(catids[0], ",")
 
 	// Sanitization now handled by middleware - no need for manual html.EscapeString
 
-	if catids == nil || strings.TrimSpace(title) == "" || strings.TrimSpace(content) == "" {
-		w.WriteHeader(400)
+	if err := checkPostText(&title, &content, limits, wordFilter); err != nil {
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
@@ -186,6 +321,12 @@ func CreatePost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		}
 		catidsInt = append(catidsInt, id)
 	}
+	catidsInt = commands.DedupeCategoryIDs(catidsInt)
+
+	if err := commands.ValidateCategoryIDs(catidsInt, limits.MaxCategoriesPerPost); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
 	err := models.CheckCategories(db, catidsInt)
 	if err != nil {
@@ -193,34 +334,202 @@ func CreatePost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	pid, err := models.StorePost(db, user_id, title, content)
+	// The post and its category links are stored together, so a category
+	// deleted since CheckCategories fails the whole post
+	if _, err := models.StorePost(db, user_id, title, content, draft, catidsInt); err != nil {
+		if errors.Is(err, models.ErrCategoryNotFound) {
+			http.Error(w, "one of the categories no longer exists", 400)
+			return
+		}
+		log.Println("Error storing post:", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(200)
+}
+
+// checkPostText cleans the title and content of a post form in place,
+// checks them against the configured limits and applies the banned-word
+// filter, as CreatePost and PreviewPost both need to
+func checkPostText(title, content *string, limits config.ContentConfig, wordFilter *utils.WordFilter) error {
+	if err := commands.CleanContent("title", "title", title, limits.InvisibleChars); err != nil {
+		return err
+	}
+	if err := commands.CleanContent("content", "content", content, limits.InvisibleChars); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*title) == "" || strings.TrimSpace(*content) == "" {
+		return errors.New("title and content are required")
+	}
+
+	// The middleware has escaped the fields, so measure what the user typed
+	if err := commands.ValidateLength("title", "title", html.UnescapeString(strings.TrimSpace(*title)), limits.TitleMinLength, limits.TitleMaxLength); err != nil {
+		return err
+	}
+	if err := commands.ValidateLength("content", "content", html.UnescapeString(strings.TrimSpace(*content)), limits.PostMinLength, limits.PostMaxLength); err != nil {
+		return err
+	}
+
+	if err := filterEscaped(wordFilter, title, "title", "title"); err != nil {
+		return err
+	}
+	return filterEscaped(wordFilter, content, "content", "content")
+}
+
+// filterEscaped runs the banned-word filter over a form field the Sanitize
+// middleware has escaped: the words are matched in what the user typed,
+// and masked text is escaped again
+func filterEscaped(wordFilter *utils.WordFilter, text *string, field, noun string) error {
+	unescaped := html.UnescapeString(*text)
+	masked := unescaped
+	if err := commands.FilterBannedWords(wordFilter, &masked, field, noun); err != nil {
+		return err
+	}
+	if masked != unescaped {
+		*text = html.EscapeString(masked)
+	}
+	return nil
+}
+
+// PreviewPost renders a post form the way the post page will show it,
+// without saving anything: the title as plain text and the content as
+// sanitized HTML from the Markdown renderer. Invalid input gets the same
+// 400 messages as CreatePost.
+func PreviewPost(w http.ResponseWriter, r *http.Request, limits config.ContentConfig, wordFilter *utils.WordFilter) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+
+	title := r.FormValue("title")
+	content := r.FormValue("content")
+	if err := checkPostText(&title, &content, limits, wordFilter); err != nil {
+		writeInvalid(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"title": html.UnescapeString(strings.TrimSpace(title)),
+		"html":  utils.RenderMarkdown(content),
+	})
+}
+
+func PublishPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	user, _ := utils.UserFromContext(r.Context())
+	user_id := user.ID
+
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
+		return
+	}
+
+	post_id, err := strconv.Atoi(r.FormValue("postid"))
 	if err != nil {
 		w.WriteHeader(400)
 		return
 	}
 
-	for i := 0; i < len(catidsInt); i++ {
-
-		_, err = models.StorePostCategory(db, pid, catidsInt[i])
-		if err != nil {
-			w.WriteHeader(400)
+	if err := models.PublishPost(db, user_id, post_id); err != nil {
+		if errors.Is(err, models.ErrPostNotFound) {
+			w.WriteHeader(404)
 			return
 		}
+		log.Println("Error publishing post:", err)
+		w.WriteHeader(500)
+		return
 	}
 
-	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(200)
 }
 
-func MyCreatedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var valid bool
-	var username string
-	var user_id int
-	if user_id, username, valid = models.ValidSession(r, db); !valid {
-		http.Redirect(w, r, "/login", http.StatusFound)
+// SetPostLock closes (locked) or reopens a post to new comments for its
+// author or an admin (form field "postid", and "reason" for the audit log),
+// answering with the command result
+func SetPostLock(w http.ResponseWriter, r *http.Request, posts *commands.PostCommandHandler, postQueries *queries.CachedPostQueryService, locked bool) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("postid"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
 		return
 	}
 
+	user, _ := utils.UserFromContext(r.Context())
+	var result *commands.CommandResult
+	if locked {
+		result, err = posts.LockPost(commands.LockPostCommand{UserID: user.ID, PostID: postID, Reason: r.FormValue("reason")})
+	} else {
+		result, err = posts.UnlockPost(commands.UnlockPostCommand{UserID: user.ID, PostID: postID, Reason: r.FormValue("reason")})
+	}
+	if err != nil {
+		log.Println("Error locking post:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if result.Success {
+		postQueries.InvalidatePostCache()
+	}
+	writeResult(w, result)
+}
+
+// SetPostPin pins (pinned) or unpins a post for an admin (form fields
+// "postid", "reason" for the audit log and, when pinning, an optional
+// "pin_order"), answering with the command result
+func SetPostPin(w http.ResponseWriter, r *http.Request, posts *commands.PostCommandHandler, postQueries *queries.CachedPostQueryService, pinned bool) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.FormValue("postid"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	var result *commands.CommandResult
+	if pinned {
+		order := 0
+		if raw := r.FormValue("pin_order"); raw != "" {
+			order, err = strconv.Atoi(raw)
+			if err != nil {
+				writeInvalid(w, &commands.ValidationError{Field: "pin_order", Err: errors.New("pin order must be a number")})
+				return
+			}
+		}
+		result, err = posts.PinPost(commands.PinPostCommand{UserID: user.ID, PostID: postID, Order: order, Reason: r.FormValue("reason")})
+	} else {
+		result, err = posts.UnpinPost(commands.UnpinPostCommand{UserID: user.ID, PostID: postID, Reason: r.FormValue("reason")})
+	}
+	if err != nil {
+		log.Println("Error pinning post:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if result.Success {
+		postQueries.InvalidatePostCache()
+	}
+	writeResult(w, result)
+}
+
+func MyCreatedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig) {
+	// RequireAuth has already checked the session
+	user, _ := utils.UserFromContext(r.Context())
+	user_id, username, valid := user.ID, user.Username, true
+
 	if r.Method != http.MethodGet {
 		utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
 		return
@@ -235,7 +544,7 @@ func MyCreatedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	if page < 0 {
 		page = 0
 	}
-	posts, statusCode, err := models.FetchCreatedPostsByUser(db, user_id, page)
+	posts, hasMore, statusCode, err := models.FetchCreatedPostsByUser(db, user_id, page, content.ExcludeSelfReactions())
 	if err != nil {
 		log.Println("Error fetching posts:", err)
 		utils.RenderError(db, w, r, statusCode, valid, username)
@@ -246,21 +555,30 @@ func MyCreatedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	if err := utils.RenderTemplate(db, w, r, "home", statusCode, posts, valid, username); err != nil {
+	// The summary is a nice-to-have; render the list even if it fails
+	summary, err := queries.NewPostQueryService(db, content).GetUserSummary(user_id, 0)
+	if err != nil {
+		log.Println("Error fetching user summary:", err)
+	}
+
+	data := struct {
+		Posts   []models.Post
+		Page    queries.PageMeta
+		HasMore bool
+		Summary *queries.UserPostsSummary
+	}{Posts: posts, HasMore: hasMore, Summary: summary}
+
+	if err := utils.RenderTemplate(db, w, r, "my-posts", statusCode, data, valid, username); err != nil {
 		log.Println("Error rendering template:", err)
 		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 }
 
-func MyLikedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var valid bool
-	var username string
-	var user_id int
-	if user_id, username, valid = models.ValidSession(r, db); !valid {
-		http.Redirect(w, r, "/login", http.StatusFound)
-		return
-	}
+func MyLikedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig) {
+	// RequireAuth has already checked the session
+	user, _ := utils.UserFromContext(r.Context())
+	user_id, username, valid := user.ID, user.Username, true
 
 	if r.Method != http.MethodGet {
 		utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
@@ -276,7 +594,7 @@ func MyLikedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	if page < 0 {
 		page = 0
 	}
-	posts, statusCode, err := models.FetchLikedPostsByUser(db, user_id, page)
+	posts, hasMore, statusCode, err := models.FetchLikedPostsByUser(db, user_id, page, content.ExcludeSelfReactions())
 	if err != nil {
 		log.Println("Error fetching posts:", err)
 		utils.RenderError(db, w, r, statusCode, valid, username)
@@ -287,29 +605,24 @@ func MyLikedPosts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	if err := utils.RenderTemplate(db, w, r, "home", statusCode, posts, valid, username); err != nil {
+	if err := utils.RenderTemplate(db, w, r, "home", statusCode, postsPage{Posts: posts, HasMore: hasMore}, valid, username); err != nil {
 		log.Println("Error rendering template:", err)
 		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
 		return
 	}
 }
 
-func ReactToPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func ReactToPost(w http.ResponseWriter, r *http.Request, db *sql.DB, events realtime.Publisher, content config.ContentConfig) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 
-	var user_id int
-	var valid bool
-
-	if user_id, _, valid = models.ValidSession(r, db); !valid {
-		w.WriteHeader(401)
-		return
-	}
+	user, _ := utils.UserFromContext(r.Context())
+	user_id := user.ID
 
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(400)
+		utils.JSONError(w, 400, "invalid form")
 		return
 	}
 
@@ -317,16 +630,175 @@ func ReactToPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	id := r.FormValue("post_id")
 	post_id, err := strconv.Atoi(id)
 	if err != nil {
-		w.WriteHeader(400)
+		utils.JSONError(w, 400, "invalid post ID")
+		return
+	}
+	likeCount, dislikeCount, err := models.ReactToPost(db, content, user_id, post_id, userReaction)
+	if errors.Is(err, models.ErrPostNotFound) {
+		utils.JSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	if errors.Is(err, models.ErrSelfReaction) {
+		utils.JSONError(w, http.StatusForbidden, "you cannot react to your own post")
 		return
 	}
-	likeCount, dislikeCount, err := models.ReactToPost(db, user_id, post_id, userReaction)
 	if err != nil {
-		w.WriteHeader(500)
+		utils.JSONError(w, 500, "")
 		return
 	}
 
+	events.Publish(realtime.Event{
+		Type:   realtime.EventPostReaction,
+		PostID: post_id,
+		Data:   realtime.ReactionData{ID: post_id, LikeCount: likeCount, DislikeCount: dislikeCount},
+	})
+
 	// Return the new count as JSON
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]int{"likesCount": likeCount, "dislikesCount": dislikeCount})
 }
+
+// commentsPageSize is how many comments one page of /post/{id}/comments lists
+const commentsPageSize = 20
+
+// PostComments lists a post's comments as JSON, a page (?page=N) at a
+// time, in the order given by ?sort= (newest first by default, as on the
+// post page). has_more tells the client whether to offer "load more".
+// ?include=author adds each commenter's role, join date and post count.
+func PostComments(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig, c clock.Clock) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || postID <= 0 {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+	sort, err := queries.ParseCommentSort(r.URL.Query().Get("sort"), queries.CommentSortNewest)
+	if err != nil {
+		writeInvalid(w, &commands.ValidationError{Field: "sort", Err: err})
+		return
+	}
+	page := 1
+	if param := r.URL.Query().Get("page"); param != "" {
+		if page, err = strconv.Atoi(param); err != nil || page < 1 {
+			writeInvalid(w, &commands.ValidationError{Field: "page", Err: errors.New("page must be a positive number")})
+			return
+		}
+	}
+
+	viewerID, _, _ := models.ValidSession(r, db, c)
+	service := queries.NewPostQueryService(db, content)
+	comments, err := service.GetPostComments(r.Context(), postID, viewerID, sort, commentsPageSize, (page-1)*commentsPageSize)
+	if err != nil {
+		if errors.Is(err, queries.ErrPostNotFound) {
+			utils.JSONError(w, http.StatusNotFound, "post not found")
+			return
+		}
+		log.Println("Error fetching post comments:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if r.URL.Query().Get("include") == "author" {
+		if err := service.AddCommentAuthors(r.Context(), comments.Comments); err != nil {
+			log.Println("Error fetching comment authors:", err)
+			utils.JSONError(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"comments": comments.Comments,
+		"has_more": comments.HasMore,
+		"page":     page,
+	})
+}
+
+// reactorsPageSize is how many users one page of /post/{id}/reactions lists
+const reactorsPageSize = 20
+
+// PostReactors lists the users who liked (?reaction=like, the default) or
+// disliked a post as JSON, a page (?page=N) at a time. Unless
+// REACTORS_VISIBILITY is "everyone", only the post's author and admins may
+// see the list.
+func PostReactors(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig, c clock.Clock) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || postID <= 0 {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+	reaction := r.URL.Query().Get("reaction")
+	if reaction == "" {
+		reaction = "like"
+	}
+	if reaction != "like" && reaction != "dislike" {
+		writeInvalid(w, &commands.ValidationError{Field: "reaction", Err: errors.New("reaction must be 'like' or 'dislike'")})
+		return
+	}
+	page := 1
+	if param := r.URL.Query().Get("page"); param != "" {
+		if page, err = strconv.Atoi(param); err != nil || page < 1 {
+			writeInvalid(w, &commands.ValidationError{Field: "page", Err: errors.New("page must be a positive number")})
+			return
+		}
+	}
+
+	postQueries := queries.NewPostQueryService(db, content)
+	if content.ReactorsVisibility != config.ReactorsVisibleToEveryone {
+		viewerID, _, valid := models.ValidSession(r, db, c)
+		if !valid {
+			utils.JSONError(w, http.StatusUnauthorized, "")
+			return
+		}
+		authorID, err := postQueries.GetPostAuthorID(r.Context(), postID)
+		if err != nil {
+			writeReactorsError(w, err)
+			return
+		}
+		if viewerID != authorID {
+			role, err := models.GetUserRole(db, viewerID)
+			if err != nil {
+				log.Println("Error checking user role:", err)
+				utils.JSONError(w, http.StatusInternalServerError, "")
+				return
+			}
+			if role != "admin" {
+				utils.JSONError(w, http.StatusForbidden, "only the author can see who reacted")
+				return
+			}
+		}
+	}
+
+	reactors, total, err := postQueries.GetPostReactors(r.Context(), postID, reaction, reactorsPageSize, (page-1)*reactorsPageSize)
+	if err != nil {
+		writeReactorsError(w, err)
+		return
+	}
+
+	meta := queries.NewPageMeta(total, page, reactorsPageSize)
+	setPageHeaders(w, r, meta, "page")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reaction": reaction,
+		"reactors": reactors,
+		"page":     meta,
+	})
+}
+
+// writeReactorsError answers a failed reactors lookup
+func writeReactorsError(w http.ResponseWriter, err error) {
+	if errors.Is(err, queries.ErrPostNotFound) {
+		utils.JSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	log.Println("Error fetching post reactors:", err)
+	utils.JSONError(w, http.StatusInternalServerError, "")
+}
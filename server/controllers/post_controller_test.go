@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/middleware"
+	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/testutil"
+	"forum/server/utils"
+)
+
+func newWordFilter(t *testing.T, mode string) *utils.WordFilter {
+	t.Helper()
+	filter, err := utils.NewWordFilter([]string{"darn"}, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func TestCreatePostRejectsBannedWords(t *testing.T) {
+	db := testutil.NewDB(t)
+	limits := config.LoadConfig().Content
+	filter := newWordFilter(t, utils.FilterModeReject)
+	handler := middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		CreatePost(w, r, db, limits, filter)
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, postForm("/post/createpost", utils.CurrentUser{ID: 1, Username: "alice"}, url.Values{
+		"title":      {"A fine title"},
+		"content":    {"This is a D A R N long enough post"},
+		"categories": {"1"},
+	}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(w.Body.String(), "not allowed") {
+		t.Errorf("body = %q, want the banned words message", w.Body.String())
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE title = 'A fine title'").Scan(&count)
+	if count != 0 {
+		t.Errorf("the post was stored")
+	}
+}
+
+func TestCreatePostMasksBannedWords(t *testing.T) {
+	db := testutil.NewDB(t)
+	limits := config.LoadConfig().Content
+	filter := newWordFilter(t, utils.FilterModeMask)
+	handler := middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		CreatePost(w, r, db, limits, filter)
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, postForm("/post/createpost", utils.CurrentUser{ID: 1, Username: "alice"}, url.Values{
+		"title":      {"A fine title"},
+		"content":    {"Darn <b>it</b>, this is long enough"},
+		"categories": {"1"},
+	}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var content string
+	if err := db.QueryRow("SELECT content FROM posts WHERE title = 'A fine title'").Scan(&content); err != nil {
+		t.Fatal(err)
+	}
+	if want := "**** &lt;b&gt;it&lt;/b&gt;, this is long enough"; content != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+}
+
+func TestCreateCommentRejectsBannedWords(t *testing.T) {
+	db := testutil.NewDB(t)
+	limits := config.LoadConfig().Content
+	filter := newWordFilter(t, utils.FilterModeReject)
+	handler := middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		CreateComment(w, r, db, testutil.NopPublisher{}, limits, filter)
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, postForm("/post/addcommentREQ", utils.CurrentUser{ID: 2, Username: "bob"}, url.Values{
+		"postid":  {"1"},
+		"comment": {"well d.a.r.n"},
+	}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM comments WHERE content LIKE '%d.a.r.n%'").Scan(&count)
+	if count != 0 {
+		t.Errorf("the comment was stored")
+	}
+}
+
+// storeTestPost stores a published post by alice (user 1) and returns its ID
+func storeTestPost(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	id, err := models.StorePost(db, 1, "Reaction target", "A post to react to", false, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// reactToPost likes or dislikes postID as user and returns the response
+func reactToPost(db *sql.DB, content config.ContentConfig, user utils.CurrentUser, postID int, reaction string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ReactToPost(w, postForm("/post/postreaction", user, url.Values{
+		"post_id":  {strconv.Itoa(postID)},
+		"reaction": {reaction},
+	}), db, testutil.NopPublisher{}, content)
+	return w
+}
+
+func TestReactToPostForbidsSelfReaction(t *testing.T) {
+	db := testutil.NewDB(t)
+	content := config.LoadConfig().Content
+	content.SelfReactions = config.SelfReactionsForbid
+	postID := storeTestPost(t, db)
+
+	w := reactToPost(db, content, utils.CurrentUser{ID: 1, Username: "alice"}, postID, "like")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM post_reactions WHERE post_id = ?", postID).Scan(&count)
+	if count != 0 {
+		t.Errorf("the reaction was stored")
+	}
+
+	w = reactToPost(db, content, utils.CurrentUser{ID: 2, Username: "bob"}, postID, "like")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+}
+
+func TestReactToPostExcludesSelfReactions(t *testing.T) {
+	db := testutil.NewDB(t)
+	content := config.LoadConfig().Content
+	content.SelfReactions = config.SelfReactionsExclude
+	postID := storeTestPost(t, db)
+
+	w := reactToPost(db, content, utils.CurrentUser{ID: 1, Username: "alice"}, postID, "like")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	w = reactToPost(db, content, utils.CurrentUser{ID: 2, Username: "bob"}, postID, "like")
+	var counts map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts["likesCount"] != 1 {
+		t.Errorf("likesCount = %d, want 1", counts["likesCount"])
+	}
+
+	// The page and the query service agree on the count
+	post, _, err := models.FetchPost(context.Background(), db, postID, -1, queries.CommentSortNewest, content.ExcludeSelfReactions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := queries.NewPostQueryService(db, content).GetPostByID(context.Background(), postID, -1, queries.CommentSortNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Post.Likes != 1 || detail.LikeCount != 1 {
+		t.Errorf("likes = %d (models), %d (queries), want 1", post.Post.Likes, detail.LikeCount)
+	}
+
+	content.SelfReactions = config.SelfReactionsAllow
+	posts, _, _, err := models.FetchPosts(context.Background(), db, 0, content.ExcludeSelfReactions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range posts {
+		if p.ID == postID && p.Likes != 2 {
+			t.Errorf("likes with allow = %d, want 2", p.Likes)
+		}
+	}
+}
+
+func TestCreatePostCategoryLists(t *testing.T) {
+	tests := []struct {
+		name       string
+		categories []string // nil leaves the field out of the form
+		wantCode   int
+		wantError  string
+		wantLinks  int
+	}{
+		{"no categories field", nil, http.StatusBadRequest, "at least one category is required", 0},
+		{"empty categories field", []string{""}, http.StatusBadRequest, "at least one category is required", 0},
+		{"duplicates", []string{"2,1,2"}, http.StatusOK, "", 2}, // the form joins the selected IDs
+		{"over the limit", []string{"1,2,3"}, http.StatusBadRequest, "at most 2 categories", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			limits := config.LoadConfig().Content
+			limits.MaxCategoriesPerPost = 2
+
+			form := url.Values{
+				"title":   {"Category lists"},
+				"content": {"This post is long enough"},
+			}
+			if tt.categories != nil {
+				form["categories"] = tt.categories
+			}
+			w := httptest.NewRecorder()
+			CreatePost(w, postForm("/post/createpost", utils.CurrentUser{ID: 1, Username: "alice"}, form), db, limits, nil)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body = %q, want %q in the message", w.Body.String(), tt.wantError)
+			}
+			var links int
+			db.QueryRow(`SELECT COUNT(*) FROM post_category pc JOIN posts p ON p.id = pc.post_id
+				WHERE p.title = 'Category lists'`).Scan(&links)
+			if links != tt.wantLinks {
+				t.Errorf("%d categories linked, want %d", links, tt.wantLinks)
+			}
+		})
+	}
+}
+
+func TestCreatePostCategoryDeletedBeforeLinking(t *testing.T) {
+	db := testutil.NewDB(t)
+	// Category 3 disappears after CheckCategories, as soon as the post row exists
+	if _, err := db.Exec(`CREATE TRIGGER vanishing_category AFTER INSERT ON posts
+		WHEN NEW.title = 'Vanishing category'
+		BEGIN DELETE FROM categories WHERE id = 3; END`); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	CreatePost(w, postForm("/post/createpost", utils.CurrentUser{ID: 1, Username: "alice"}, url.Values{
+		"title":      {"Vanishing category"},
+		"content":    {"Category 3 is gone by the time it is linked"},
+		"categories": {"1,3"},
+	}), db, config.LoadConfig().Content, nil)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var posts int
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE title = 'Vanishing category'").Scan(&posts)
+	if posts != 0 {
+		t.Errorf("%d posts stored, want the insert rolled back", posts)
+	}
+}
+
+func TestIndexPostsStopsAtHomepageLimit(t *testing.T) {
+	utils.SetTemplatesDir("../../web/templates")
+	db := testutil.NewDB(t)
+	// 20 more posts on top of the 5 seeded ones
+	for i := 0; i < 20; i++ {
+		if _, err := models.StorePost(db, 1, fmt.Sprintf("Filler post %d", i), "Some filler content", false, []int{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := config.LoadConfig().Content
+	content.HomepagePostLimit = 15
+	postQueries := queries.NewCachedPostQueryService(context.Background(), db, config.LoadConfig().Cache, content)
+
+	index := func(pageID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		IndexPosts(w, httptest.NewRequest(http.MethodGet, "/?PageID="+pageID, nil), db, postQueries, content, clock.Real)
+		return w
+	}
+	const truncatedNote = `class="posts-truncated"`
+	const nextLink = `pagination('next'`
+
+	first := index("1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("page 1: status = %d", first.Code)
+	}
+	body := first.Body.String()
+	if n := strings.Count(body, `class="post-title"`); n != 10 {
+		t.Errorf("page 1 lists %d posts, want 10", n)
+	}
+	if !strings.Contains(body, nextLink) || strings.Contains(body, truncatedNote) {
+		t.Error("page 1 should link to page 2 and not mention the limit")
+	}
+
+	last := index("2")
+	if last.Code != http.StatusOK {
+		t.Fatalf("page 2: status = %d", last.Code)
+	}
+	body = last.Body.String()
+	if n := strings.Count(body, `class="post-title"`); n != 5 {
+		t.Errorf("page 2 lists %d posts, want 5", n)
+	}
+	if strings.Contains(body, nextLink) {
+		t.Error("page 2 links past the limit")
+	}
+	if !strings.Contains(body, truncatedNote) {
+		t.Error("page 2 does not say older posts were left out")
+	}
+	if got := last.Header().Get("X-Total-Count"); got != "15" {
+		t.Errorf("X-Total-Count = %s, want the capped 15", got)
+	}
+
+	if w := index("3"); w.Code != http.StatusNotFound {
+		t.Errorf("page 3: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	content.HomepagePostLimit = 0
+	if w := index("3"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), truncatedNote) {
+		t.Errorf("page 3 without a limit: status = %d", w.Code)
+	}
+}
+
+func TestIndexPostsLimitMatchingThePostCount(t *testing.T) {
+	utils.SetTemplatesDir("../../web/templates")
+	db := testutil.NewDB(t)
+	content := config.LoadConfig().Content
+	// Exactly the 5 seeded posts: nothing is left out
+	content.HomepagePostLimit = 5
+	postQueries := queries.NewCachedPostQueryService(context.Background(), db, config.LoadConfig().Cache, content)
+
+	w := httptest.NewRecorder()
+	IndexPosts(w, httptest.NewRequest(http.MethodGet, "/", nil), db, postQueries, content, clock.Real)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), `class="posts-truncated"`) {
+		t.Error("the limit note is shown although every post is listed")
+	}
+}
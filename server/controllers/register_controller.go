@@ -2,17 +2,20 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
+	"forum/server/clock"
+	"forum/server/commands"
 	"forum/server/models"
 	"forum/server/utils"
 )
 
-func GetRegisterPage(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func GetRegisterPage(w http.ResponseWriter, r *http.Request, db *sql.DB, c clock.Clock) {
 	var valid bool
-	if _, _, valid = models.ValidSession(r, db); valid {
+	if _, _, valid = models.ValidSession(r, db, c); valid {
 		http.Redirect(w, r, "/", http.StatusFound)
 		return
 	}
@@ -29,15 +32,15 @@ func GetRegisterPage(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	}
 }
 
-func Signup(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func Signup(w http.ResponseWriter, r *http.Request, db *sql.DB, c clock.Clock) {
 	var valid bool
-	if _, _, valid = models.ValidSession(r, db); valid {
+	if _, _, valid = models.ValidSession(r, db, c); valid {
 		w.WriteHeader(302)
 		return
 	}
 
 	if r.Method != http.MethodPost {
-		w.WriteHeader(405)
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
 		return
 	}
 	if err := r.ParseForm(); err != nil {
@@ -45,20 +48,45 @@ func Signup(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	email := r.FormValue("email")
-	username := r.FormValue("username")
+	email := commands.NormalizeEmail(r.FormValue("email"))
+	username := commands.NormalizeUsername(r.FormValue("username"))
 	password := r.FormValue("password")
 	passwordConfirmation := r.FormValue("password-confirmation")
 
-	if len(strings.TrimSpace(username)) < 4 || len(strings.TrimSpace(password)) < 6 || email == "" || password != passwordConfirmation {
-		w.WriteHeader(400)
+	if err := validateSignup(email, username, password, passwordConfirmation); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An email that already has an account gets the normal success answer,
+	// so the form does not reveal who is registered (see RegisterUser)
+	emailTaken, usernameTaken, err := models.RegistrationConflicts(db, email, username)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	if emailTaken {
+		commands.DummyPasswordCheck(password)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		return
+	}
+	if usernameTaken {
+		http.Error(w, "username is not available", http.StatusConflict)
 		return
 	}
 
-	_, err := models.StoreUser(db, email, username, password)
+	_, err = models.StoreUser(db, email, username, password)
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: users.username" {
-			w.WriteHeader(304)
+		// Someone registered the same name or email in the meantime; the
+		// constraint names the column (or the case-insensitive index)
+		if strings.HasPrefix(err.Error(), "UNIQUE constraint failed") {
+			if strings.Contains(err.Error(), "email") {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(200)
+				return
+			}
+			http.Error(w, "username is not available", http.StatusConflict)
 			return
 		}
 
@@ -68,3 +96,21 @@ func Signup(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(200)
 }
+
+// validateSignup applies the same rules as the register command so the
+// user gets a specific reason when the form is rejected
+func validateSignup(email, username, password, passwordConfirmation string) error {
+	if err := commands.ValidateEmail(email); err != nil {
+		return err
+	}
+	if err := commands.ValidateUsername(username); err != nil {
+		return err
+	}
+	if err := commands.ValidatePassword(password); err != nil {
+		return err
+	}
+	if password != passwordConfirmation {
+		return errors.New("passwords do not match")
+	}
+	return nil
+}
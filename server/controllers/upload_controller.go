@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/utils"
+)
+
+// multipartOverhead is room for the form fields and part headers on top of
+// the image itself
+const multipartOverhead = 1 << 20
+
+// UploadPostImage attaches an image to one of the user's posts. It expects
+// a multipart form with "post_id" and the file in "image", and answers with
+// the image URL as JSON.
+func UploadPostImage(w http.ResponseWriter, r *http.Request, db *sql.DB, uploads config.UploadConfig) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+
+	file, ok := imageFromForm(w, r, "image", uploads.MaxImageSize)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	postID, err := strconv.Atoi(r.FormValue("post_id"))
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "invalid post ID")
+		return
+	}
+	allowed, err := models.CanAttachImage(db, user.ID, postID)
+	if err != nil {
+		log.Println("Error checking post owner:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if !allowed {
+		utils.JSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+
+	name, ok := saveUploadedImage(w, file, uploads.Dir, uploads.MaxImageSize)
+	if !ok {
+		return
+	}
+	if _, err := models.StorePostImage(db, postID, name); err != nil {
+		log.Println("Error storing post image:", err)
+		os.Remove(filepath.Join(uploads.Dir, name))
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": config.UploadURL(name)})
+}
+
+// UploadAvatar replaces the current user's avatar with the image in the
+// multipart field "avatar" and answers with its URL as JSON
+func UploadAvatar(w http.ResponseWriter, r *http.Request, db *sql.DB, uploads config.UploadConfig) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(db, w, r, http.MethodPost)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+
+	file, ok := imageFromForm(w, r, "avatar", uploads.MaxAvatarSize)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	name, ok := saveUploadedImage(w, file, uploads.Dir, uploads.MaxAvatarSize)
+	if !ok {
+		return
+	}
+	previous, err := models.SetUserAvatar(db, user.ID, name)
+	if err != nil {
+		log.Println("Error storing avatar:", err)
+		os.Remove(filepath.Join(uploads.Dir, name))
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if previous != "" {
+		if err := os.Remove(filepath.Join(uploads.Dir, previous)); err != nil && !os.IsNotExist(err) {
+			log.Println("Error removing old avatar:", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": config.UploadURL(name)})
+}
+
+// DefaultAvatar serves the generated avatar of users without an upload
+func DefaultAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(utils.Identicon("user-" + strconv.Itoa(userID)))
+}
+
+// imageFromForm parses a multipart upload, capping the body at maxSize
+// plus room for the other fields, and returns the file in field. On
+// failure it has written the response.
+func imageFromForm(w http.ResponseWriter, r *http.Request, field string, maxSize int64) (multipart.File, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.JSONError(w, http.StatusRequestEntityTooLarge, utils.ErrImageTooLarge.Error())
+		} else {
+			utils.JSONError(w, http.StatusBadRequest, "invalid upload")
+		}
+		return nil, false
+	}
+
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		utils.JSONError(w, http.StatusBadRequest, "missing image")
+		return nil, false
+	}
+	return file, true
+}
+
+// saveUploadedImage validates and stores an image in dir, mapping
+// validation failures to client errors. On failure it has written the
+// response.
+func saveUploadedImage(w http.ResponseWriter, file multipart.File, dir string, maxSize int64) (string, bool) {
+	name, err := utils.SaveImage(file, dir, maxSize)
+	switch {
+	case err == nil:
+		return name, true
+	case errors.Is(err, utils.ErrImageTooLarge):
+		utils.JSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, utils.ErrImageType):
+		utils.JSONError(w, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, utils.ErrImageEmpty):
+		utils.JSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Println("Error saving image:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+	}
+	return "", false
+}
+
+// ServeUpload serves a stored upload. Only plain file names with an image
+// extension are accepted, and browsers are told not to sniff the type.
+func ServeUpload(w http.ResponseWriter, r *http.Request, uploads config.UploadConfig) {
+	name := r.PathValue("name")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || !allowedImageExt(filepath.Ext(name)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	path := filepath.Join(uploads.Dir, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, path)
+}
+
+func allowedImageExt(ext string) bool {
+	for _, allowed := range utils.AllowedImageTypes {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
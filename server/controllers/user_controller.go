@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"forum/server/clock"
+	"forum/server/commands"
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/utils"
+)
+
+// profileRecentPosts is how many posts the profile page lists
+const profileRecentPosts = 10
+
+func UserProfile(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig, c clock.Clock) {
+	viewerID, username, valid := models.ValidSession(r, db, c)
+
+	if r.Method != http.MethodGet {
+		utils.RenderError(db, w, r, http.StatusMethodNotAllowed, valid, username)
+		return
+	}
+
+	authorID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || authorID <= 0 {
+		utils.RenderError(db, w, r, http.StatusBadRequest, valid, username)
+		return
+	}
+
+	profile, err := queries.NewPostQueryService(db, content).GetUserProfile(authorID, viewerID, profileRecentPosts)
+	if err != nil {
+		if errors.Is(err, queries.ErrUserNotFound) {
+			utils.RenderError(db, w, r, http.StatusNotFound, valid, username)
+			return
+		}
+		log.Println("Error fetching user profile:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+		return
+	}
+
+	if err := utils.RenderTemplate(db, w, r, "profile", http.StatusOK, profile, valid, username); err != nil {
+		log.Println("Error rendering template:", err)
+		utils.RenderError(db, w, r, http.StatusInternalServerError, valid, username)
+		return
+	}
+}
+
+// CurrentUser answers /api/me, the frontend's "am I logged in" check:
+// the user RequireAuth found for the session cookie, their role and when
+// the session expires (after SlidingSession has extended it)
+func CurrentUser(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	role, err := models.GetUserRole(db, user.ID)
+	if err != nil {
+		log.Println("Error checking user role:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         user.ID,
+		"username":   user.Username,
+		"role":       role,
+		"expires_at": user.ExpiresAt.UTC(),
+	})
+}
+
+// ExportAccount sends everything stored about the logged-in user as a JSON
+// download. Admins may export another account with ?user_id=N.
+func ExportAccount(w http.ResponseWriter, r *http.Request, db *sql.DB, content config.ContentConfig) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(nil, w, r, http.MethodGet)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	userID := user.ID
+	if param := r.URL.Query().Get("user_id"); param != "" {
+		requested, err := strconv.Atoi(param)
+		if err != nil || requested <= 0 {
+			writeInvalid(w, &commands.ValidationError{Field: "user_id", Err: errors.New("invalid user ID")})
+			return
+		}
+		if requested != user.ID {
+			role, err := models.GetUserRole(db, user.ID)
+			if err != nil {
+				log.Println("Error checking user role:", err)
+				utils.JSONError(w, http.StatusInternalServerError, "")
+				return
+			}
+			if role != "admin" {
+				utils.JSONError(w, http.StatusForbidden, "only admins can export other accounts")
+				return
+			}
+		}
+		userID = requested
+	}
+
+	export, err := queries.NewPostQueryService(db, content).ExportUserData(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, queries.ErrUserNotFound) {
+			utils.JSONError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Println("Error exporting user data:", err)
+		utils.JSONError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="forum-export-%d.json"`, userID))
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		log.Println("Error writing user export:", err)
+	}
+}
+
+// DeleteAccount deletes the logged-in user's account (form fields
+// "password" and "confirm", which must repeat the username). What happens
+// to the user's posts and comments depends on ACCOUNT_DELETION.
+func DeleteAccount(w http.ResponseWriter, r *http.Request, db *sql.DB, postQueries *queries.CachedPostQueryService, content config.ContentConfig, uploads config.UploadConfig, session config.SessionConfig, c clock.Clock) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(nil, w, r, http.MethodPost)
+		return
+	}
+
+	user, _ := utils.UserFromContext(r.Context())
+	var result *commands.CommandResult
+	if !strings.EqualFold(strings.TrimSpace(r.FormValue("confirm")), user.Username) {
+		result = &commands.CommandResult{
+			Success: false,
+			Error:   "type your username to confirm",
+			Code:    commands.CodeValidation,
+			Field:   "confirm",
+		}
+	} else {
+		var err error
+		result, err = commands.NewUserCommandHandler(db, content, session, c).DeleteAccount(commands.DeleteAccountCommand{
+			UserID:   user.ID,
+			Password: r.FormValue("password"),
+		})
+		if err != nil {
+			log.Println("Error deleting account:", err)
+			utils.JSONError(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+
+	if result.Success {
+		files, _ := result.Data.(map[string]interface{})["files"].([]string)
+		for _, name := range files {
+			if err := os.Remove(filepath.Join(uploads.Dir, name)); err != nil && !os.IsNotExist(err) {
+				log.Println("Error removing upload of deleted account:", err)
+			}
+		}
+		// Posts, comments and counts may all have changed
+		postQueries.InvalidatePostCache()
+		postQueries.InvalidateCategoryCache()
+		postQueries.InvalidateUserCache(user.ID)
+		config.ClearSessionCookie(w, session)
+		result = &commands.CommandResult{Success: true}
+	}
+	writeResult(w, result)
+}
@@ -7,3 +7,7 @@ DELETE FROM posts;
 DELETE FROM categories;
 DELETE FROM sessions;
 DELETE FROM users;
+
+-- Restart the ids at 1: the up migration refers to its rows by id
+DELETE FROM sqlite_sequence
+WHERE name IN ('users', 'sessions', 'categories', 'posts', 'post_category', 'comments', 'post_reactions', 'comment_reactions');
@@ -0,0 +1,4 @@
+-- Drafts are lost on rollback since they were never meant to be public
+DELETE FROM posts WHERE status = 'draft';
+ALTER TABLE posts DROP COLUMN published_at;
+ALTER TABLE posts DROP COLUMN status;
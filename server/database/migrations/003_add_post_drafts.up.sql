@@ -0,0 +1,6 @@
+-- Posts can be saved as drafts and published later
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published'));
+ALTER TABLE posts ADD COLUMN published_at TIMESTAMP;
+
+-- Existing posts were published when they were created
+UPDATE posts SET published_at = created_at WHERE published_at IS NULL;
@@ -0,0 +1,3 @@
+-- Deleted posts and comments are kept for moderation and audit
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP;
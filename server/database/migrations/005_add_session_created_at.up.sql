@@ -0,0 +1,3 @@
+-- Track when a session started so sliding expiration can cap its lifetime
+ALTER TABLE sessions ADD COLUMN created_at TIMESTAMP;
+UPDATE sessions SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
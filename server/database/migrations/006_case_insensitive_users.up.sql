@@ -0,0 +1,5 @@
+-- Emails are stored lowercased; usernames keep their case for display but
+-- must be unique regardless of it
+UPDATE users SET email = LOWER(TRIM(email)), username = TRIM(username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username));
@@ -0,0 +1,8 @@
+-- URL-friendly category names for /c/{slug}. New and renamed categories get
+-- their slug from the application; existing rows get a simple version
+-- here, with the id appended where two labels would collide.
+ALTER TABLE categories ADD COLUMN slug TEXT;
+UPDATE categories SET slug = LOWER(REPLACE(TRIM(label), ' ', '-'));
+UPDATE categories SET slug = slug || '-' || id
+WHERE id NOT IN (SELECT MIN(id) FROM categories GROUP BY slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
@@ -0,0 +1,17 @@
+-- Engagement notifications: comments on your posts and reactions to your
+-- posts and comments. actor_id is the user who caused the notification.
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id BIGINT NOT NULL,
+    actor_id BIGINT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('comment', 'post_like', 'post_dislike', 'comment_like', 'comment_dislike')),
+    post_id BIGINT NOT NULL,
+    comment_id BIGINT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications (user_id, read_at);
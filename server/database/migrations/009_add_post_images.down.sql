@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_images_post;
+DROP TABLE IF EXISTS post_images;
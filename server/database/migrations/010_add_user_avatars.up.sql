@@ -0,0 +1,3 @@
+-- Uploaded profile pictures. avatar_path is the file name inside the
+-- upload directory; NULL means the generated default avatar is shown.
+ALTER TABLE users ADD COLUMN avatar_path TEXT;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_sessions_expires_at;
+DROP INDEX IF EXISTS idx_sessions_session_id;
+DROP INDEX IF EXISTS idx_posts_status_created;
+DROP INDEX IF EXISTS idx_post_category_category;
+DROP INDEX IF EXISTS idx_comments_post;
+DROP INDEX IF EXISTS idx_comment_reactions_comment;
+DROP INDEX IF EXISTS idx_post_reactions_post;
@@ -0,0 +1,12 @@
+-- Indexes for the lookups the listing and post pages do on every request.
+-- The reaction upserts (ON CONFLICT(user_id, post_id) and
+-- ON CONFLICT(user_id, comment_id)) are already backed by the UNIQUE
+-- constraints of 001, whose automatic indexes lead with user_id; the
+-- indexes below cover the per-post and per-comment side.
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post ON post_reactions (post_id);
+CREATE INDEX IF NOT EXISTS idx_comment_reactions_comment ON comment_reactions (comment_id);
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_post_category_category ON post_category (category_id);
+CREATE INDEX IF NOT EXISTS idx_posts_status_created ON posts (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_session_id ON sessions (session_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
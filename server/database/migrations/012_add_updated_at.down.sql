@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS comments_updated_at_edit;
+DROP TRIGGER IF EXISTS comments_updated_at_insert;
+DROP TRIGGER IF EXISTS posts_updated_at_edit;
+DROP TRIGGER IF EXISTS posts_updated_at_insert;
+ALTER TABLE comments DROP COLUMN updated_at;
+ALTER TABLE posts DROP COLUMN updated_at;
@@ -0,0 +1,28 @@
+-- Last content change of posts and comments, for showing "edited".
+-- updated_at starts out equal to created_at and moves when the title or
+-- content changes; deleting, restoring or publishing does not count.
+ALTER TABLE posts ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN updated_at TIMESTAMP;
+UPDATE posts SET updated_at = created_at WHERE updated_at IS NULL;
+UPDATE comments SET updated_at = created_at WHERE updated_at IS NULL;
+
+CREATE TRIGGER IF NOT EXISTS posts_updated_at_insert AFTER INSERT ON posts
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE posts SET updated_at = NEW.created_at WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS posts_updated_at_edit AFTER UPDATE OF title, content ON posts
+WHEN NEW.title IS NOT OLD.title OR NEW.content IS NOT OLD.content
+BEGIN
+    UPDATE posts SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS comments_updated_at_insert AFTER INSERT ON comments
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE comments SET updated_at = NEW.created_at WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS comments_updated_at_edit AFTER UPDATE OF content ON comments
+WHEN NEW.content IS NOT OLD.content
+BEGIN
+    UPDATE comments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
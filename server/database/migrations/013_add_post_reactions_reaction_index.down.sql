@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post ON post_reactions (post_id);
+DROP INDEX IF EXISTS idx_post_reactions_post_reaction;
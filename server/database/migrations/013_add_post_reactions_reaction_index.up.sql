@@ -0,0 +1,5 @@
+-- The reactors list filters a post's reactions by type and pages through
+-- them newest first. The wider index also serves every post_id lookup the
+-- one from 011 did, so it replaces that.
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_reaction ON post_reactions (post_id, reaction, created_at);
+DROP INDEX IF EXISTS idx_post_reactions_post;
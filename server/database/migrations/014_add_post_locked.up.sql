@@ -0,0 +1,2 @@
+-- Locked posts keep their comments and reactions but take no new comments
+ALTER TABLE posts ADD COLUMN locked BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- "Remember me" sessions last a fixed time instead of sliding
+ALTER TABLE sessions ADD COLUMN remember BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- post_category is declared UNIQUE (post_id, category_id) in 001, but the
+-- guarantee should not depend on how the table was created. Drop any
+-- repeated links, keeping the oldest, then enforce it with a named index
+-- that the ON CONFLICT(post_id, category_id) inserts can rely on.
+DELETE FROM post_category
+WHERE id NOT IN (
+    SELECT MIN(id) FROM post_category GROUP BY post_id, category_id
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_category_post_category ON post_category (post_id, category_id);
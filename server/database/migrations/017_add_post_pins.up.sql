@@ -0,0 +1,5 @@
+-- Pinned posts head the homepage and category listings, lowest pin_order
+-- first. Only a handful are pinned at a time, so the index covers just them.
+ALTER TABLE posts ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN pin_order INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_posts_pinned ON posts (pin_order) WHERE pinned = 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_log_created;
+DROP TABLE IF EXISTS audit_log;
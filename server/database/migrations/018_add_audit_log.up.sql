@@ -0,0 +1,14 @@
+-- Privileged actions taken with admin rights, kept for accountability.
+-- Entries outlive what they point at: target_id has no foreign key, since
+-- categories are deleted outright, and a deleted actor leaves actor_id NULL.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id BIGINT,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at);
@@ -0,0 +1,2 @@
+-- Admins can restore deleted content and moderate the forum
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
@@ -3,6 +3,8 @@ CREATE TABLE IF NOT EXISTS sessions (
     user_id BIGINT UNIQUE NOT NULL,
     session_id TEXT NOT NULL,
     expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    remember BOOLEAN NOT NULL DEFAULT 0,
     FOREIGN KEY (user_id) REFERENCES users(id) on DELETE CASCADE
 );
 CREATE TABLE IF NOT EXISTS users (
@@ -10,8 +12,12 @@ CREATE TABLE IF NOT EXISTS users (
     email TEXT UNIQUE NOT NULL,
     username TEXT UNIQUE NOT NULL,
     password TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    avatar_path TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username));
 CREATE TABLE IF NOT EXISTS post_category (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     post_id BIGINT NOT NULL,
@@ -22,14 +28,23 @@ CREATE TABLE IF NOT EXISTS post_category (
 CREATE TABLE IF NOT EXISTS categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     label TEXT UNIQUE NOT NULL,
+    slug TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
 CREATE TABLE IF NOT EXISTS posts (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id BIGINT NOT NULL,
     title TEXT NOT NULL,
     content TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'published' CHECK (status IN ('draft', 'published')),
+    published_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    locked BOOLEAN NOT NULL DEFAULT 0,
+    pinned BOOLEAN NOT NULL DEFAULT 0,
+    pin_order INTEGER NOT NULL DEFAULT 0,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 CREATE TABLE IF NOT EXISTS comments (
@@ -38,6 +53,8 @@ CREATE TABLE IF NOT EXISTS comments (
     post_id BIGINT NOT NULL,
     content TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    deleted_at TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
 );
@@ -60,4 +77,68 @@ CREATE TABLE IF NOT EXISTS comment_reactions (
     FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
     UNIQUE (user_id, comment_id),
     CHECK (reaction IN ('like', 'dislike'))
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id BIGINT NOT NULL,
+    actor_id BIGINT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('comment', 'post_like', 'post_dislike', 'comment_like', 'comment_dislike')),
+    post_id BIGINT NOT NULL,
+    comment_id BIGINT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_unread ON notifications (user_id, read_at);
+CREATE TABLE IF NOT EXISTS post_images (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id BIGINT NOT NULL,
+    path TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_post_images_post ON post_images (post_id);
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id BIGINT,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_reaction ON post_reactions (post_id, reaction, created_at);
+CREATE INDEX IF NOT EXISTS idx_comment_reactions_comment ON comment_reactions (comment_id);
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_post_category_category ON post_category (category_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_category_post_category ON post_category (post_id, category_id);
+CREATE INDEX IF NOT EXISTS idx_posts_status_created ON posts (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_pinned ON posts (pin_order) WHERE pinned = 1;
+CREATE INDEX IF NOT EXISTS idx_sessions_session_id ON sessions (session_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+CREATE TRIGGER IF NOT EXISTS posts_updated_at_insert AFTER INSERT ON posts
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE posts SET updated_at = NEW.created_at WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS posts_updated_at_edit AFTER UPDATE OF title, content ON posts
+WHEN NEW.title IS NOT OLD.title OR NEW.content IS NOT OLD.content
+BEGIN
+    UPDATE posts SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS comments_updated_at_insert AFTER INSERT ON comments
+WHEN NEW.updated_at IS NULL
+BEGIN
+    UPDATE comments SET updated_at = NEW.created_at WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS comments_updated_at_edit AFTER UPDATE OF content ON comments
+WHEN NEW.content IS NOT OLD.content
+BEGIN
+    UPDATE comments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,11 @@
+//go:build !windows && !linux && !darwin
+
+package health
+
+// CheckDiskSpace is not implemented on this platform
+func CheckDiskSpace() Check {
+	return Check{
+		Status:  "warn",
+		Message: "Disk space check not supported on this platform",
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package health
+
+import (
+	"os"
+	"syscall"
+)
+
+// CheckDiskSpace verifies available disk space
+func CheckDiskSpace() Check {
+	path, err := os.Getwd()
+	if err != nil {
+		return Check{
+			Status:  "warn",
+			Message: "Could not check disk space",
+		}
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return Check{
+			Status:  "warn",
+			Message: "Could not retrieve disk space",
+		}
+	}
+
+	blockSize := uint64(fs.Bsize)
+	return diskCheck(uint64(fs.Bavail)*blockSize, uint64(fs.Blocks)*blockSize)
+}
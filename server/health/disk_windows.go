@@ -0,0 +1,52 @@
+package health
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// CheckDiskSpace verifies available disk space
+func CheckDiskSpace() Check {
+	// On Windows, use GetDiskFreeSpaceEx via syscall
+	path, err := os.Getwd()
+	if err != nil {
+		return Check{
+			Status:  "warn",
+			Message: "Could not check disk space",
+		}
+	}
+
+	// Convert to UTF16 for Windows API
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return Check{
+			Status:  "warn",
+			Message: "Invalid path for disk check",
+		}
+	}
+
+	var freeBytesAvailable uint64
+	var totalBytes uint64
+	var totalFreeBytes uint64
+
+	// Call Windows API
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getDiskFreeSpaceEx := kernel32.NewProc("GetDiskFreeSpaceExW")
+
+	ret, _, _ := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	)
+
+	if ret == 0 {
+		return Check{
+			Status:  "warn",
+			Message: "Could not retrieve disk space",
+		}
+	}
+
+	return diskCheck(freeBytesAvailable, totalBytes)
+}
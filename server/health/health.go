@@ -0,0 +1,255 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"forum/server/migrations"
+)
+
+// Report is the overall health, as served by /health and printed by
+// --healthcheck
+type Report struct {
+	Status    string           `json:"status"`    // "healthy", "degraded", "unhealthy"
+	Timestamp string           `json:"timestamp"` // ISO 8601 format
+	Version   string           `json:"version"`   // App version
+	Uptime    string           `json:"uptime"`    // Server uptime
+	Checks    map[string]Check `json:"checks"`    // Individual health checks
+}
+
+// Check represents a single health check result
+type Check struct {
+	Status  string           `json:"status"`            // "pass", "fail", "warn"
+	Message string           `json:"message,omitempty"` // Additional info
+	Time    string           `json:"time,omitempty"`    // Response time in ms
+	Details map[string]int64 `json:"details,omitempty"` // Raw numbers for dashboards
+}
+
+// CheckNames lists the checks of a Report in the order they run
+var CheckNames = []string{"database", "database_pool", "migrations", "disk", "memory"}
+
+// poolWarnRatio is the share of MaxOpenConns in use at which the pool
+// check starts warning
+const poolWarnRatio = 0.8
+
+var startTime = time.Now()
+
+// Run performs every check against db. A failing check makes the report
+// unhealthy, a warning makes it degraded. The migrations check reads the
+// migration files in migrationsDir.
+func Run(db *sql.DB, migrationsDir string) Report {
+	report := Report{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Version:   Version(),
+		Uptime:    Uptime(),
+		Checks:    make(map[string]Check),
+	}
+
+	report.add("database", CheckDatabase(db))
+	report.add("database_pool", CheckConnectionPool(db))
+	report.add("migrations", CheckMigrations(db, migrationsDir))
+	report.add("disk", CheckDiskSpace())
+	report.add("memory", CheckMemory())
+
+	return report
+}
+
+func (r *Report) add(name string, check Check) {
+	r.Checks[name] = check
+	switch {
+	case check.Status == "fail":
+		r.Status = "unhealthy"
+	case check.Status == "warn" && r.Status == "healthy":
+		r.Status = "degraded"
+	}
+}
+
+// CheckDatabase verifies database connectivity
+func CheckDatabase(db *sql.DB) Check {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return Check{
+			Status:  "fail",
+			Message: fmt.Sprintf("Database unreachable: %v", err),
+		}
+	}
+
+	// Check if we can execute a simple query
+	var result int
+	err := db.QueryRowContext(ctx, "SELECT 1").Scan(&result)
+	if err != nil {
+		return Check{
+			Status:  "fail",
+			Message: fmt.Sprintf("Database query failed: %v", err),
+		}
+	}
+
+	duration := time.Since(start).Milliseconds()
+	message := "Connected"
+	status := "pass"
+
+	// Warn if response is slow
+	if duration > 100 {
+		status = "warn"
+		message = fmt.Sprintf("Connected but slow (%dms)", duration)
+	}
+
+	return Check{
+		Status:  status,
+		Message: message,
+		Time:    fmt.Sprintf("%dms", duration),
+	}
+}
+
+// CheckConnectionPool reports the database/sql pool statistics and warns
+// when the connections in use approach MaxOpenConns, before requests start
+// queueing for a connection
+func CheckConnectionPool(db *sql.DB) Check {
+	stats := db.Stats()
+
+	check := Check{
+		Status: "pass",
+		Message: fmt.Sprintf("%d open (%d in use, %d idle), max %d",
+			stats.OpenConnections, stats.InUse, stats.Idle, stats.MaxOpenConnections),
+		Details: map[string]int64{
+			"max_open":         int64(stats.MaxOpenConnections),
+			"open":             int64(stats.OpenConnections),
+			"in_use":           int64(stats.InUse),
+			"idle":             int64(stats.Idle),
+			"wait_count":       stats.WaitCount,
+			"wait_duration_ms": stats.WaitDuration.Milliseconds(),
+		},
+	}
+
+	// MaxOpenConnections is 0 when the pool is unbounded
+	if stats.MaxOpenConnections > 0 &&
+		float64(stats.InUse) >= poolWarnRatio*float64(stats.MaxOpenConnections) {
+		check.Status = "warn"
+		check.Message = fmt.Sprintf("Pool nearly exhausted: %d of %d connections in use, %d waits so far",
+			stats.InUse, stats.MaxOpenConnections, stats.WaitCount)
+	}
+
+	return check
+}
+
+// CheckMigrations verifies that every migration in migrationsDir has been
+// applied. It only ever warns: the server applies pending migrations on
+// start-up, and databases set up with the legacy --migrate have no
+// migration history at all.
+func CheckMigrations(db *sql.DB, migrationsDir string) Check {
+	migrator := migrations.NewMigrator(db, migrationsDir)
+	pending, err := migrator.GetPendingMigrations()
+	if err != nil {
+		return Check{
+			Status:  "warn",
+			Message: fmt.Sprintf("Could not read migrations: %v", err),
+		}
+	}
+
+	details := map[string]int64{"pending": int64(len(pending))}
+	if len(pending) > 0 {
+		return Check{
+			Status:  "warn",
+			Message: fmt.Sprintf("%d pending, next is %s_%s", len(pending), pending[0].Version, pending[0].Name),
+			Details: details,
+		}
+	}
+	return Check{
+		Status:  "pass",
+		Message: "All migrations applied",
+		Details: details,
+	}
+}
+
+// diskCheck grades free space: fail below 1GB or above 95% used, warn
+// below 5GB or above 85% used
+func diskCheck(freeBytes, totalBytes uint64) Check {
+	usedBytes := totalBytes - freeBytes
+	usedPercent := float64(usedBytes) / float64(totalBytes) * 100
+	availableGB := float64(freeBytes) / (1024 * 1024 * 1024)
+
+	message := fmt.Sprintf("%.2f GB available (%.1f%% used)", availableGB, usedPercent)
+
+	// Fail if less than 1GB or >95% used
+	if availableGB < 1 || usedPercent > 95 {
+		return Check{
+			Status:  "fail",
+			Message: message,
+		}
+	}
+
+	// Warn if less than 5GB or >85% used
+	if availableGB < 5 || usedPercent > 85 {
+		return Check{
+			Status:  "warn",
+			Message: message,
+		}
+	}
+
+	return Check{
+		Status:  "pass",
+		Message: message,
+	}
+}
+
+// CheckMemory verifies memory usage
+func CheckMemory() Check {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	allocMB := float64(m.Alloc) / (1024 * 1024)
+	sysMB := float64(m.Sys) / (1024 * 1024)
+
+	message := fmt.Sprintf("Alloc: %.2f MB, Sys: %.2f MB", allocMB, sysMB)
+
+	// Warn if using more than 500MB
+	if allocMB > 500 {
+		return Check{
+			Status:  "warn",
+			Message: message,
+		}
+	}
+
+	return Check{
+		Status:  "pass",
+		Message: message,
+	}
+}
+
+// Version returns the application version
+func Version() string {
+	// You can read this from a VERSION file or build-time variable
+	version := os.Getenv("APP_VERSION")
+	if version == "" {
+		version = "dev"
+	}
+	return version
+}
+
+// Uptime returns how long the process has been running
+func Uptime() string {
+	duration := time.Since(startTime)
+
+	days := int(duration.Hours() / 24)
+	hours := int(duration.Hours()) % 24
+	minutes := int(duration.Minutes()) % 60
+	seconds := int(duration.Seconds()) % 60
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm %ds", days, hours, minutes, seconds)
+	} else if hours > 0 {
+		return fmt.Sprintf("%dh %dm %ds", hours, minutes, seconds)
+	} else if minutes > 0 {
+		return fmt.Sprintf("%dm %ds", minutes, seconds)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Default holds the application's metrics, served on /metrics when
+// enabled in config
+var Default = NewRegistry()
+
+var (
+	httpRequests = NewCounterVec(Default, "forum_http_requests_total",
+		"HTTP requests handled, by route pattern, method and status code.",
+		"route", "method", "status")
+	httpDuration = NewHistogramVec(Default, "forum_http_request_duration_seconds",
+		"Time spent handling HTTP requests, by route pattern.",
+		DefaultDurationBuckets, "route")
+	rateLimited = NewCounterVec(Default, "forum_rate_limited_total",
+		"Requests rejected by a rate limiter, by limiter.",
+		"limiter")
+	cacheRequests = NewCounterVec(Default, "forum_cache_requests_total",
+		"Query cache lookups, by key group and result (hit or miss).",
+		"cache", "result")
+	dbErrors = NewCounterVec(Default, "forum_db_query_errors_total",
+		"Database errors returned by the query service, by query.",
+		"query")
+)
+
+// unmatchedRoute labels requests no route pattern matched, so stray paths
+// cannot blow up the number of series
+const unmatchedRoute = "unmatched"
+
+// ObserveRequest records one handled HTTP request. route should be the
+// mux pattern, never the raw path.
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	httpRequests.Inc(route, method, strconv.Itoa(status))
+	httpDuration.Observe(duration.Seconds(), route)
+}
+
+// RateLimited records a request rejected by the named limiter
+func RateLimited(limiter string) {
+	rateLimited.Inc(limiter)
+}
+
+// CacheLookup records a query cache hit or miss for a group of keys
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.Inc(cache, result)
+}
+
+// DBError records a database error returned by the named query
+func DBError(query string) {
+	dbErrors.Inc(query)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/server/clock"
+	"forum/server/models"
+	"forum/server/utils"
+)
+
+// RequireAuth rejects requests without a valid session and stores the
+// logged-in user in the request context for the handler. Anonymous page
+// requests are sent to /login; anything else (form posts, fetch calls,
+// JSON clients) gets a 401 in the JSON error envelope so the frontend can
+// react to it. Session expiry is judged by c.
+func RequireAuth(db *sql.DB, c clock.Clock) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			session, err := models.ValidateSession(db, r, c)
+			if err != nil && !errors.Is(err, models.ErrNotAuthenticated) {
+				log.Println("Error validating session:", err)
+				utils.JSONError(w, http.StatusInternalServerError, "")
+				return
+			}
+			if err != nil {
+				if wantsPage(r) {
+					http.Redirect(w, r, "/login", http.StatusFound)
+				} else {
+					utils.JSONError(w, http.StatusUnauthorized, "login required")
+				}
+				return
+			}
+
+			user := utils.CurrentUser{ID: session.ID, Username: session.Username, ExpiresAt: session.ExpiresAt}
+			next(w, r.WithContext(utils.WithUser(r.Context(), user)))
+		}
+	}
+}
+
+// RequireAdmin is RequireAuth for admin-only routes: logged-in users
+// without the admin role get a 403.
+func RequireAdmin(db *sql.DB, c clock.Clock) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return RequireAuth(db, c)(func(w http.ResponseWriter, r *http.Request) {
+			user, _ := utils.UserFromContext(r.Context())
+			role, err := models.GetUserRole(db, user.ID)
+			if err != nil {
+				log.Println("Error checking user role:", err)
+				utils.JSONError(w, http.StatusInternalServerError, "")
+				return
+			}
+			if role != "admin" {
+				utils.JSONError(w, http.StatusForbidden, "admins only")
+				return
+			}
+			next(w, r)
+		})
+	}
+}
+
+// wantsPage reports whether r is a plain browser navigation that should be
+// answered with a redirect rather than a bare status code. /api/ routes
+// are never pages.
+func wantsPage(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	return !utils.WantsJSON(r)
+}
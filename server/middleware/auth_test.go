@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/testutil"
+	"forum/server/utils"
+)
+
+func TestRequireAuthExpiresSessionsByItsClock(t *testing.T) {
+	db := testutil.NewDB(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := clock.NewFake(start)
+	if err := models.StoreSession(db, 1, "alice-session", start, start.Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen utils.CurrentUser
+	handler := RequireAuth(db, now)(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = utils.UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/post/createpost", nil)
+		r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: "alice-session"})
+		handler(w, r)
+		return w.Code
+	}
+
+	now.Advance(time.Hour - time.Second)
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("a second before expiry: status = %d, want %d", code, http.StatusOK)
+	}
+	if seen.ID != 1 || seen.Username != "alice" {
+		t.Errorf("user in context = %+v, want alice", seen)
+	}
+
+	now.Advance(time.Second)
+	if code := request(); code != http.StatusUnauthorized {
+		t.Fatalf("at expiry: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestSlidingSessionExtendsByItsClock(t *testing.T) {
+	db := testutil.NewDB(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := clock.NewFake(start)
+	cfg := config.SessionConfig{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour}
+	if err := models.StoreSession(db, 1, "alice-session", start, start.Add(cfg.IdleTimeout), false); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := SlidingSession(db, cfg, now)(func(w http.ResponseWriter, r *http.Request) {})
+	visit := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: "alice-session"})
+		handler(w, r)
+		return w
+	}
+	expiry := func() time.Time {
+		var expiresAt time.Time
+		if err := db.QueryRow("SELECT expires_at FROM sessions WHERE session_id = 'alice-session'").Scan(&expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		return expiresAt
+	}
+
+	// More than half the idle timeout left: nothing moves
+	now.Advance(20 * time.Minute)
+	if w := visit(); w.Header().Get("Set-Cookie") != "" {
+		t.Error("the cookie was sent again although the session was not extended")
+	}
+	if got := expiry(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("expiry = %v, want it unchanged", got)
+	}
+
+	// Past the halfway mark the session slides to a full idle timeout
+	now.Advance(20 * time.Minute)
+	if w := visit(); w.Header().Get("Set-Cookie") == "" {
+		t.Error("the cookie was not sent again after extending the session")
+	}
+	if got, want := expiry(), now.Now().Add(time.Hour); !got.Equal(want) {
+		t.Errorf("expiry = %v, want %v", got, want)
+	}
+}
+
+func TestRequireAuthWithoutSession(t *testing.T) {
+	db := testutil.NewDB(t)
+	handler := RequireAuth(db, clock.Real)(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the handler ran without a session")
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"page", http.MethodGet, "/myposts", http.StatusFound},
+		{"form post", http.MethodPost, "/post/createpost", http.StatusUnauthorized},
+		{"api", http.MethodGet, "/api/notifications", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusFound && w.Header().Get("Location") != "/login" {
+				t.Errorf("redirected to %q, want /login", w.Header().Get("Location"))
+			}
+		})
+	}
+}
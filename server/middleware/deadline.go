@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Deadline middleware cancels the request context after timeout, so
+// database work started with r.Context() cannot outlive the request.
+// WebSocket upgrades are long-lived by design and are left alone, as is
+// everything when timeout is 0.
+func Deadline(timeout time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if timeout <= 0 {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"forum/server/utils"
+)
+
+// TrustedHosts middleware answers 400 to requests whose Host header is not
+// in hosts, a comma separated list of hostnames, so links built from the
+// Host header cannot point elsewhere. "*.example.com" accepts any
+// subdomain of example.com. The port is ignored. An empty list, or "*",
+// accepts every host, which suits development. /health is exempt so load
+// balancers can probe by IP.
+func TrustedHosts(hosts string) func(http.HandlerFunc) http.HandlerFunc {
+	allowed := parseHostList(hosts)
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if allowed == nil {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" || allowed.contains(r.Host) {
+				next(w, r)
+				return
+			}
+
+			if !wantsPage(r) {
+				utils.WriteJSONError(w, http.StatusBadRequest, utils.APIError{
+					Code:    "invalid_host",
+					Message: "unknown host",
+				})
+				return
+			}
+			http.Error(w, "400 | Bad Request", http.StatusBadRequest)
+		}
+	}
+}
+
+// hostList holds the accepted hostnames; a leading "*." marks a pattern
+// matching subdomains
+type hostList []string
+
+// parseHostList reads list, returning nil when every host is accepted
+func parseHostList(list string) hostList {
+	var hosts hostList
+	for _, entry := range strings.Split(list, ",") {
+		entry = normalizeHost(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return nil
+		case strings.Contains(entry, "*") && (!strings.HasPrefix(entry, "*.") || strings.Count(entry, "*") > 1):
+			log.Printf("Ignoring %q in the allowed hosts: only a leading \"*.\" is supported", entry)
+			continue
+		}
+		hosts = append(hosts, entry)
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	return hosts
+}
+
+// contains reports whether the Host header host matches one of the hosts
+func (l hostList) contains(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = normalizeHost(host)
+	if host == "" {
+		return false
+	}
+	for _, allowed := range l {
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases host and drops the brackets of an IPv6 address
+// and a trailing dot
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
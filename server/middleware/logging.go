@@ -18,8 +18,16 @@ func (rec *responseRecorder) WriteHeader(code int) {
 	rec.ResponseWriter.WriteHeader(code)
 }
 
-// Logging middleware logs HTTP requests with structured logging
-func Logging(logger *utils.Logger) func(http.HandlerFunc) http.HandlerFunc {
+// Unwrap lets http.ResponseController reach the underlying writer, e.g.
+// to hijack the connection for a WebSocket
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Logging middleware logs HTTP requests with structured logging. The
+// optional onDone hooks run after the log line with the final status and
+// duration, e.g. to feed request metrics.
+func Logging(logger *utils.Logger, onDone ...func(r *http.Request, status int, duration time.Duration)) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -36,12 +44,16 @@ func Logging(logger *utils.Logger) func(http.HandlerFunc) http.HandlerFunc {
 			// Log after request is handled
 			duration := time.Since(start)
 			logger.HTTPLog(
+				utils.RequestIDFromContext(r.Context()),
 				r.Method,
 				r.URL.Path,
-				getClientIP(r),
+				utils.ClientIP(r),
 				rec.statusCode,
 				duration,
 			)
+			for _, hook := range onDone {
+				hook(r, rec.statusCode, duration)
+			}
 		}
 	}
 }
@@ -53,6 +65,7 @@ func Recovery(logger *utils.Logger) func(http.HandlerFunc) http.HandlerFunc {
 			defer func() {
 				if err := recover(); err != nil {
 					logger.Error("Panic recovered",
+						"request_id", utils.RequestIDFromContext(r.Context()),
 						"error", err,
 						"path", r.URL.Path,
 						"method", r.Method,
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/server/utils"
+)
+
+func TestRecoveryLogsPanics(t *testing.T) {
+	var out bytes.Buffer
+	logger := utils.NewLogger(&out, "info")
+	handler := Logging(logger)(Recovery(logger)(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/post/1", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	logged := out.String()
+	if !strings.Contains(logged, "Panic recovered") || !strings.Contains(logged, "boom") {
+		t.Errorf("panic not logged: %q", logged)
+	}
+	if !strings.Contains(logged, "HTTP Request") || !strings.Contains(logged, "/post/1") || !strings.Contains(logged, "500") {
+		t.Errorf("request not logged with its 500 status: %q", logged)
+	}
+}
+
+func TestLoggingRecordsStatus(t *testing.T) {
+	var out bytes.Buffer
+	var hookStatus int
+	handler := Logging(utils.NewLogger(&out, "info"), func(r *http.Request, status int, _ time.Duration) {
+		hookStatus = status
+	})(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if hookStatus != http.StatusNotFound {
+		t.Errorf("hook got status %d, want %d", hookStatus, http.StatusNotFound)
+	}
+	if !strings.Contains(out.String(), "/missing") {
+		t.Errorf("request not logged: %q", out.String())
+	}
+}
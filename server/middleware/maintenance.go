@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/utils"
+)
+
+// maintenanceOpenPaths stay reachable during maintenance: static files the
+// maintenance page itself needs, metrics, and the login pages so an admin
+// without a session can still sign in to test
+var maintenanceOpenPaths = []string{"/assets/", "/uploads/", "/avatar/", "/metrics", "/login", "/signin", "/logout"}
+
+// maintenanceFileCheck is how often the maintenance file is looked for
+const maintenanceFileCheck = time.Second
+
+// Maintenance middleware answers every request with 503 and a Retry-After
+// header while the site is in maintenance (cfg.Enabled, or cfg.File
+// exists): browsers get the maintenance page, JSON clients and /health the
+// JSON error envelope, so load balancers drain traffic. Admins and clients
+// from cfg.AllowIPs are let through to test the deploy. The allow list is
+// matched against the connection's address only: X-Forwarded-For and
+// X-Real-IP are set by the client unless a proxy overwrites them.
+//
+// Admin sessions are only looked up while the site is in maintenance, by
+// clock c, and a database error there counts as "not an admin", since the
+// database may be what is being worked on.
+func Maintenance(db *sql.DB, cfg config.MaintenanceConfig, c clock.Clock) func(http.HandlerFunc) http.HandlerFunc {
+	allowed := parseIPAllowList(cfg.AllowIPs)
+	active := maintenanceSwitch(cfg)
+	retryAfter := strconv.Itoa(int(cfg.RetryAfter.Seconds()))
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !active() || maintenanceOpen(r.URL.Path) {
+				next(w, r)
+				return
+			}
+			if ip := remoteIP(r); ip != nil && allowed.contains(ip) {
+				next(w, r)
+				return
+			}
+			if isAdminRequest(r, db, c) {
+				next(w, r)
+				return
+			}
+
+			if cfg.RetryAfter > 0 {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			if r.URL.Path == "/health" || !wantsPage(r) {
+				utils.WriteJSONError(w, http.StatusServiceUnavailable, utils.APIError{
+					Code:    "maintenance",
+					Message: "down for maintenance, back soon",
+				})
+				return
+			}
+			// No database: the sidebar is left out rather than queried
+			if err := utils.RenderTemplate(nil, w, r, "maintenance", http.StatusServiceUnavailable, nil, false, ""); err != nil {
+				log.Println("Error rendering the maintenance page:", err)
+			}
+		}
+	}
+}
+
+// maintenanceSwitch returns a function reporting whether the site is in
+// maintenance. The file is looked for at most once per
+// maintenanceFileCheck, however busy the site is.
+func maintenanceSwitch(cfg config.MaintenanceConfig) func() bool {
+	if cfg.Enabled {
+		return func() bool { return true }
+	}
+	if cfg.File == "" {
+		return func() bool { return false }
+	}
+
+	var mu sync.Mutex
+	var checked time.Time
+	var exists bool
+	return func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(checked) >= maintenanceFileCheck {
+			_, err := os.Stat(cfg.File)
+			exists = err == nil
+			checked = time.Now()
+		}
+		return exists
+	}
+}
+
+// maintenanceOpen reports whether path stays reachable during maintenance
+func maintenanceOpen(path string) bool {
+	for _, open := range maintenanceOpenPaths {
+		if path == open || (strings.HasSuffix(open, "/") && strings.HasPrefix(path, open)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdminRequest reports whether r carries the session of an admin
+func isAdminRequest(r *http.Request, db *sql.DB, c clock.Clock) bool {
+	userID, _, valid := models.ValidSession(r, db, c)
+	if !valid {
+		return false
+	}
+	role, err := models.GetUserRole(db, userID)
+	if err != nil {
+		log.Println("Error checking user role during maintenance:", err)
+		return false
+	}
+	return role == "admin"
+}
+
+// remoteIP returns the IP of the peer the request came from, or nil
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ipAllowList holds the networks of a comma separated list of IPs and CIDR
+// ranges
+type ipAllowList []*net.IPNet
+
+// parseIPAllowList reads list, logging and skipping entries that are
+// neither an IP nor a CIDR range
+func parseIPAllowList(list string) ipAllowList {
+	var allowed ipAllowList
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				allowed = append(allowed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring %q in the maintenance allow list: not an IP or CIDR range", entry)
+			continue
+		}
+		allowed = append(allowed, network)
+	}
+	return allowed
+}
+
+// contains reports whether ip is in one of the networks
+func (l ipAllowList) contains(ip net.IP) bool {
+	for _, network := range l {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+)
+
+func TestMaintenanceAllowList(t *testing.T) {
+	cfg := config.MaintenanceConfig{Enabled: true, AllowIPs: "10.0.0.0/8, 192.0.2.7", RetryAfter: time.Minute}
+	handler := Maintenance(nil, cfg, clock.Real)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		want       int
+	}{
+		{"allowed network", "10.1.2.3:5000", "", "", http.StatusOK},
+		{"allowed address", "192.0.2.7:5000", "", "", http.StatusOK},
+		{"other address", "198.51.100.1:5000", "", "", http.StatusServiceUnavailable},
+		{"forged X-Forwarded-For", "198.51.100.1:5000", "X-Forwarded-For", "10.1.2.3", http.StatusServiceUnavailable},
+		{"forged X-Real-IP", "198.51.100.1:5000", "X-Real-IP", "192.0.2.7", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "60" {
+				t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestMaintenanceLeavesOpenPaths(t *testing.T) {
+	handler := Maintenance(nil, config.MaintenanceConfig{Enabled: true}, clock.Real)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, path := range []string{"/assets/css/style.css", "/login"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+}
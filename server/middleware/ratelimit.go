@@ -1,17 +1,21 @@
 package middleware
 
 import (
-	"net"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
+
+	"forum/server/clock"
+	"forum/server/utils"
 )
 
 // RateLimiter implements token bucket algorithm for rate limiting
 type RateLimiter struct {
 	visitors map[string]*visitor
 	mu       sync.RWMutex
+	clock    clock.Clock
 }
 
 type visitor struct {
@@ -19,21 +23,39 @@ type visitor struct {
 	lastRefill time.Time
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter() *RateLimiter {
+// NewRateLimiter creates a new rate limiter refilling buckets by the time c
+// tells (clock.Real outside tests). Its cleanup goroutine runs until ctx
+// is cancelled.
+func NewRateLimiter(ctx context.Context, c clock.Clock) *RateLimiter {
 	rl := &RateLimiter{
 		visitors: make(map[string]*visitor),
+		clock:    c,
 	}
 	
 	// Cleanup old visitors every 10 minutes
-	go rl.cleanupLoop()
+	go rl.cleanupLoop(ctx)
 	
 	return rl
 }
 
+// Limit configures a token bucket: a visitor may send Burst requests at
+// once, then one more every Refill. The sustained rate is thus one
+// request per Refill, however large the burst.
+type Limit struct {
+	Burst  int           // bucket size: requests allowed back to back
+	Refill time.Duration // time to earn back one request
+}
+
+// PerWindow is the Limit allowing maxRequests per window, both as the burst
+// and on average: the bucket holds maxRequests and refills one token every
+// window / maxRequests
+func PerWindow(maxRequests int, window time.Duration) Limit {
+	return Limit{Burst: maxRequests, Refill: window / time.Duration(maxRequests)}
+}
+
 // Allow checks if a request should be allowed based on rate limits
-// maxTokens: maximum number of requests allowed
-// refillRate: how often to add 1 token back
+// maxTokens: maximum number of requests allowed at once (the burst)
+// refillRate: how often to add 1 token back (the sustained rate)
 func (rl *RateLimiter) Allow(key string, maxTokens int, refillRate time.Duration) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
@@ -43,20 +65,25 @@ func (rl *RateLimiter) Allow(key string, maxTokens int, refillRate time.Duration
 		// First request from this visitor
 		v = &visitor{
 			tokens:     maxTokens - 1,
-			lastRefill: time.Now(),
+			lastRefill: rl.clock.Now(),
 		}
 		rl.visitors[key] = v
 		return true
 	}
 	
-	// Refill tokens based on time passed
-	now := time.Now()
+	// Refill tokens based on time passed. Time not yet worth a whole token
+	// is kept, so a slow refill rate is not rounded down on every request;
+	// a full bucket starts counting again from now.
+	now := rl.clock.Now()
 	elapsed := now.Sub(v.lastRefill)
 	tokensToAdd := int(elapsed / refillRate)
 	
 	if tokensToAdd > 0 {
 		v.tokens = min(v.tokens+tokensToAdd, maxTokens)
-		v.lastRefill = now
+		v.lastRefill = v.lastRefill.Add(time.Duration(tokensToAdd) * refillRate)
+		if v.tokens == maxTokens {
+			v.lastRefill = now
+		}
 	}
 	
 	// Check if request allowed
@@ -69,11 +96,18 @@ func (rl *RateLimiter) Allow(key string, maxTokens int, refillRate time.Duration
 }
 
 // cleanupLoop removes inactive visitors to prevent memory leaks
-func (rl *RateLimiter) cleanupLoop() {
+func (rl *RateLimiter) cleanupLoop(ctx context.Context) {
 	ticker := time.NewTicker(10 * time.Minute)
-	for range ticker.C {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 		rl.mu.Lock()
-		now := time.Now()
+		now := rl.clock.Now()
 		for key, v := range rl.visitors {
 			// Remove visitors inactive for > 1 hour
 			if now.Sub(v.lastRefill) > 1*time.Hour {
@@ -84,18 +118,25 @@ func (rl *RateLimiter) cleanupLoop() {
 	}
 }
 
-// RateLimit middleware wrapper
-func RateLimit(limiter *RateLimiter, maxRequests int, window time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+// RateLimit middleware wrapper; use PerWindow for a plain "N per window"
+// limit. Every policy has its own bucket per client. The optional onLimit
+// hooks run for every rejected request, before the 429 is written.
+func RateLimit(limiter *RateLimiter, policy string, limit Limit, onLimit ...func(r *http.Request)) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			// Use IP as key (or user ID if authenticated)
-			key := getClientIP(r)
+			key := policy + ":" + utils.ClientIP(r)
 			
-			// Calculate refill rate: window / maxRequests
-			refillRate := window / time.Duration(maxRequests)
-			
-			if !limiter.Allow(key, maxRequests, refillRate) {
-				http.Error(w, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+			if !limiter.Allow(key, limit.Burst, limit.Refill) {
+				for _, hook := range onLimit {
+					hook(r)
+				}
+				const message = "Too many requests. Please try again later."
+				if utils.WantsJSON(r) || strings.HasPrefix(r.URL.Path, "/api/") {
+					utils.JSONError(w, http.StatusTooManyRequests, message)
+				} else {
+					http.Error(w, message, http.StatusTooManyRequests)
+				}
 				return
 			}
 			
@@ -104,25 +145,25 @@ func RateLimit(limiter *RateLimiter, maxRequests int, window time.Duration) func
 	}
 }
 
-// getClientIP extracts the real client IP address
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (if behind proxy/load balancer)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-	
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-	
-	// Use RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+// MonitorRateLimit is RateLimit in monitor mode: a request over the limit
+// is logged as one that would have been rejected and then served anyway,
+// so a new or stricter limit can be tried against real traffic first
+func MonitorRateLimit(limiter *RateLimiter, policy string, limit Limit, logger *utils.Logger) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ip := utils.ClientIP(r)
+			if !limiter.Allow(policy+":"+ip, limit.Burst, limit.Refill) {
+				logger.Warn("Request would be rate limited",
+					"policy", policy,
+					"ip", ip,
+					"method", r.Method,
+					"path", r.URL.Path,
+				)
+			}
+
+			next(w, r)
+		}
 	}
-	return ip
 }
 
 func min(a, b int) int {
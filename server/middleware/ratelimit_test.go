@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+)
+
+func TestRateLimitBurstAndRefill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	limiter := NewRateLimiter(ctx, now)
+	handler := RateLimit(limiter, "create", Limit{Burst: 3, Refill: 3 * time.Second})(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	request := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/post/createpost", nil)
+		r.RemoteAddr = "192.0.2.1:5000"
+		handler(w, r)
+		return w.Code
+	}
+
+	for i := 1; i <= 3; i++ {
+		if code := request(); code != http.StatusOK {
+			t.Fatalf("request %d of the burst: status %d", i, code)
+		}
+	}
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst: status %d, want 429", code)
+	}
+
+	// Less than one refill earns nothing; a full one earns one request
+	now.Advance(2 * time.Second)
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("after 2s: status %d, want 429", code)
+	}
+	now.Advance(time.Second)
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("after one refill: status %d, want 200", code)
+	}
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("second request after one refill: status %d, want 429", code)
+	}
+
+	// A long pause refills the bucket up to the burst, not beyond
+	now.Advance(time.Hour)
+	for i := 1; i <= 3; i++ {
+		if code := request(); code != http.StatusOK {
+			t.Fatalf("request %d after a refill: status %d", i, code)
+		}
+	}
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("request past a refilled burst: status %d, want 429", code)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"forum/server/utils"
+)
+
+// RequestIDHeader carries the correlation ID on requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID middleware tags every request with an ID, reusing a well-formed
+// inbound X-Request-ID so a trace can span services
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next(w, r.WithContext(utils.WithRequestID(r.Context(), id)))
+	}
+}
+
+// newRequestID generates a random 16 character hex ID
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// validRequestID rejects inbound IDs that could pollute the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, char := range id {
+		isAlnum := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if !isAlnum && char != '-' && char != '_' && char != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/models"
+)
+
+// SlidingSession extends the session of an active user, so people are not
+// logged out while browsing. The absolute cap comes from cfg.MaxLifetime.
+// Sliding sessions use a browser-session cookie, so only the stored expiry
+// moves; the cookie is sent again to replace any older persistent one.
+// c tells the time, the same clock RequireAuth checks expiry by.
+func SlidingSession(db *sql.DB, cfg config.SessionConfig, c clock.Clock) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Static files come with every page view; the page itself is enough
+			if strings.HasPrefix(r.URL.Path, "/assets/") {
+				next(w, r)
+				return
+			}
+
+			if cookie, err := r.Cookie(config.SessionCookieName); err == nil && cookie.Value != "" {
+				_, refreshed, err := models.RefreshSession(db, cookie.Value, cfg.IdleTimeout, cfg.MaxLifetime, c)
+				if err != nil {
+					log.Println("Error refreshing session:", err)
+				} else if refreshed {
+					config.SetSessionCookie(w, cfg, c, cookie.Value, time.Time{})
+				}
+			}
+
+			next(w, r)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package migrations
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,9 +21,20 @@ type Migration struct {
 	Name      string
 	UpSQL     string
 	DownSQL   string
+	Checksum  string
 	AppliedAt time.Time
 }
 
+// Migration lock settings used to serialize concurrent instances
+const (
+	lockPollInterval = 250 * time.Millisecond
+	lockWaitTimeout  = 2 * time.Minute
+	lockStaleAfter   = 10 * time.Minute
+)
+
+// ErrLockTimeout is returned when another instance holds the migration lock too long
+var ErrLockTimeout = errors.New("timed out waiting for migration lock")
+
 // Migrator handles database migrations
 type Migrator struct {
 	db            *sql.DB
@@ -39,6 +55,13 @@ func (m *Migrator) InitMigrationsTable() error {
 		CREATE TABLE IF NOT EXISTS schema_migrations (
 			version TEXT PRIMARY KEY,
 			name TEXT NOT NULL,
+			checksum TEXT NOT NULL DEFAULT '',
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS schema_seeds (
+			version TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
 			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
 	`
@@ -46,9 +69,109 @@ func (m *Migrator) InitMigrationsTable() error {
 	if err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
+
+	// Tables created before checksums were tracked need the new column
+	return m.ensureChecksumColumn()
+}
+
+// ensureChecksumColumn adds the checksum column to an existing migrations table
+func (m *Migrator) ensureChecksumColumn() error {
+	rows, err := m.db.Query("PRAGMA table_info(schema_migrations)")
+	if err != nil {
+		return fmt.Errorf("failed to inspect migrations table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan migrations table info: %w", err)
+		}
+		if name == "checksum" {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect migrations table: %w", err)
+	}
+	rows.Close()
+
+	if _, err := m.db.Exec("ALTER TABLE schema_migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''"); err != nil {
+		// Another instance may have added it between our check and the ALTER
+		if strings.Contains(err.Error(), "duplicate column name") {
+			return nil
+		}
+		return fmt.Errorf("failed to add checksum column: %w", err)
+	}
 	return nil
 }
 
+// acquireLock takes the single migration lock row, waiting while another
+// instance holds it. A lock older than lockStaleAfter is assumed abandoned
+// by a crashed instance and is taken over.
+func (m *Migrator) acquireLock() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			locked_by TEXT NOT NULL,
+			locked_at TIMESTAMP NOT NULL
+		);
+	`
+	if _, err := m.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+	
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	deadline := time.Now().Add(lockWaitTimeout)
+	waiting := false
+	
+	for {
+		// Clear a lock left behind by an instance that died mid-migration
+		if _, err := m.db.Exec(
+			"DELETE FROM schema_migrations_lock WHERE id = 1 AND locked_at < ?",
+			time.Now().Add(-lockStaleAfter),
+		); err != nil {
+			return fmt.Errorf("failed to clear stale migration lock: %w", err)
+		}
+		
+		result, err := m.db.Exec(
+			"INSERT OR IGNORE INTO schema_migrations_lock (id, locked_by, locked_at) VALUES (1, ?, ?)",
+			owner, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			return nil
+		}
+		
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		if !waiting {
+			fmt.Println("Waiting for another instance to finish migrating...")
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// releaseLock frees the migration lock so other instances can proceed
+func (m *Migrator) releaseLock() {
+	if _, err := m.db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1"); err != nil {
+		fmt.Printf("failed to release migration lock: %v\n", err)
+	}
+}
+
+// Checksum returns the SHA-256 checksum of a migration's SQL
+func Checksum(sqlContent string) string {
+	sum := sha256.Sum256([]byte(sqlContent))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetAppliedMigrations returns list of applied migration versions
 func (m *Migrator) GetAppliedMigrations() (map[string]time.Time, error) {
 	applied := make(map[string]time.Time)
@@ -71,21 +194,14 @@ func (m *Migrator) GetAppliedMigrations() (map[string]time.Time, error) {
 	return applied, nil
 }
 
-// GetPendingMigrations returns migrations that haven't been applied
-func (m *Migrator) GetPendingMigrations() ([]Migration, error) {
-	// Get applied migrations
-	applied, err := m.GetAppliedMigrations()
-	if err != nil {
-		return nil, err
-	}
-	
-	// Read all migration files
+// loadMigrations reads every up migration file in the migrations directory
+func (m *Migrator) loadMigrations() ([]Migration, error) {
 	files, err := ioutil.ReadDir(m.migrationsDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 	
-	var pending []Migration
+	var migrations []Migration
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".up.sql") {
 			continue
@@ -94,11 +210,6 @@ func (m *Migrator) GetPendingMigrations() ([]Migration, error) {
 		// Extract version from filename (e.g., "001_create_users.up.sql" -> "001")
 		version := strings.Split(file.Name(), "_")[0]
 		
-		// Skip if already applied
-		if _, exists := applied[version]; exists {
-			continue
-		}
-		
 		// Read migration content
 		upPath := filepath.Join(m.migrationsDir, file.Name())
 		upSQL, err := ioutil.ReadFile(upPath)
@@ -110,23 +221,120 @@ func (m *Migrator) GetPendingMigrations() ([]Migration, error) {
 		nameParts := strings.Split(file.Name(), "_")
 		name := strings.TrimSuffix(strings.Join(nameParts[1:], "_"), ".up.sql")
 		
-		pending = append(pending, Migration{
-			Version: version,
-			Name:    name,
-			UpSQL:   string(upSQL),
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			UpSQL:    string(upSQL),
+			Checksum: Checksum(string(upSQL)),
 		})
 	}
 	
 	// Sort by version
-	sort.Slice(pending, func(i, j int) bool {
-		return pending[i].Version < pending[j].Version
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
 	})
 	
+	return migrations, nil
+}
+
+// GetPendingMigrations returns migrations that haven't been applied
+func (m *Migrator) GetPendingMigrations() ([]Migration, error) {
+	// Get applied migrations
+	applied, err := m.GetAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	
+	migrations, err := m.loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	
+	var pending []Migration
+	for _, migration := range migrations {
+		// Skip if already applied
+		if _, exists := applied[migration.Version]; exists {
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	
 	return pending, nil
 }
 
+// getAppliedChecksums returns the stored checksum of every applied migration
+func (m *Migrator) getAppliedChecksums() (map[string]string, error) {
+	checksums := make(map[string]string)
+	
+	rows, err := m.db.Query("SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migration checksums: %w", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var version, checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[version] = checksum
+	}
+	
+	return checksums, rows.Err()
+}
+
+// VerifyChecksums ensures no applied migration file was edited after it ran.
+// Migrations recorded before checksums were tracked are backfilled with the
+// checksum of their current file.
+func (m *Migrator) VerifyChecksums() error {
+	checksums, err := m.getAppliedChecksums()
+	if err != nil {
+		return err
+	}
+	
+	migrations, err := m.loadMigrations()
+	if err != nil {
+		return err
+	}
+	
+	for _, migration := range migrations {
+		stored, applied := checksums[migration.Version]
+		if !applied {
+			continue
+		}
+		
+		if stored == "" {
+			if _, err := m.db.Exec(
+				"UPDATE schema_migrations SET checksum = ? WHERE version = ?",
+				migration.Checksum, migration.Version,
+			); err != nil {
+				return fmt.Errorf("failed to backfill checksum for migration %s: %w", migration.Version, err)
+			}
+			continue
+		}
+		
+		if stored != migration.Checksum {
+			return fmt.Errorf("migration %s_%s has been modified after being applied (checksum %s, expected %s)",
+				migration.Version, migration.Name, migration.Checksum, stored)
+		}
+	}
+	
+	return nil
+}
+
 // Up applies all pending migrations
 func (m *Migrator) Up() error {
+	// Only one instance may migrate at a time; the others wait and then
+	// find nothing pending
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+	
+	if err := m.VerifyChecksums(); err != nil {
+		return err
+	}
+	
 	pending, err := m.GetPendingMigrations()
 	if err != nil {
 		return err
@@ -138,55 +346,318 @@ func (m *Migrator) Up() error {
 	}
 	
 	for _, migration := range pending {
-		fmt.Printf("Applying migration %s: %s...\n", migration.Version, migration.Name)
+		if err := m.apply(migration); err != nil {
+			return err
+		}
+	}
+	
+	fmt.Printf("\nApplied %d migration(s)\n", len(pending))
+	return nil
+}
+
+// Down rolls back the last applied migration
+func (m *Migrator) Down() error {
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+	
+	// Get last applied migration
+	row := m.db.QueryRow("SELECT version, name FROM schema_migrations ORDER BY version DESC LIMIT 1")
+	
+	var version, name string
+	if err := row.Scan(&version, &name); err != nil {
+		if err == sql.ErrNoRows {
+			fmt.Println("No migrations to rollback")
+			return nil
+		}
+		return fmt.Errorf("failed to get last migration: %w", err)
+	}
+	
+	return m.rollback(version, name)
+}
+
+// MigrateTo applies or rolls back migrations until the schema is at exactly
+// the target version
+func (m *Migrator) MigrateTo(target string) error {
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+	
+	if err := m.VerifyChecksums(); err != nil {
+		return err
+	}
+	
+	migrations, err := m.loadMigrations()
+	if err != nil {
+		return err
+	}
+	
+	found := false
+	for _, migration := range migrations {
+		if migration.Version == target {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("migration version %s does not exist", target)
+	}
+	
+	applied, err := m.GetAppliedMigrations()
+	if err != nil {
+		return err
+	}
+	
+	// Roll back newer migrations first, newest to oldest
+	rows, err := m.db.Query("SELECT version, name FROM schema_migrations WHERE version > ? ORDER BY version DESC", target)
+	if err != nil {
+		return fmt.Errorf("failed to query migrations: %w", err)
+	}
+	var toRollback []Migration
+	for rows.Next() {
+		var migration Migration
+		if err := rows.Scan(&migration.Version, &migration.Name); err != nil {
+			rows.Close()
+			return err
+		}
+		toRollback = append(toRollback, migration)
+	}
+	rows.Close()
+	
+	for _, migration := range toRollback {
+		if err := m.rollback(migration.Version, migration.Name); err != nil {
+			return err
+		}
+	}
+	
+	// Then apply anything missing up to and including the target
+	count := len(toRollback)
+	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, exists := applied[migration.Version]; exists {
+			continue
+		}
+		if err := m.apply(migration); err != nil {
+			return err
+		}
+		count++
+	}
+	
+	if count == 0 {
+		fmt.Printf("Already at migration %s\n", target)
+		return nil
+	}
+	
+	fmt.Printf("\nMigrated to version %s\n", target)
+	return nil
+}
+
+// loadSeeds reads every seed file (NNN_name.seed.sql) in the migrations directory
+func (m *Migrator) loadSeeds() ([]Migration, error) {
+	files, err := ioutil.ReadDir(m.migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+	
+	var seeds []Migration
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".seed.sql") {
+			continue
+		}
+		
+		nameParts := strings.Split(file.Name(), "_")
+		seedSQL, err := ioutil.ReadFile(filepath.Join(m.migrationsDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seed %s: %w", file.Name(), err)
+		}
+		
+		seeds = append(seeds, Migration{
+			Version:  nameParts[0],
+			Name:     strings.TrimSuffix(strings.Join(nameParts[1:], "_"), ".seed.sql"),
+			UpSQL:    string(seedSQL),
+			Checksum: Checksum(string(seedSQL)),
+		})
+	}
+	
+	sort.Slice(seeds, func(i, j int) bool {
+		return seeds[i].Version < seeds[j].Version
+	})
+	
+	return seeds, nil
+}
+
+// Seed applies seed files that have not run yet, recording each one so it
+// is never applied twice. Run it after the schema migrations are up.
+func (m *Migrator) Seed() error {
+	if err := m.acquireLock(); err != nil {
+		return err
+	}
+	defer m.releaseLock()
+	
+	seeds, err := m.loadSeeds()
+	if err != nil {
+		return err
+	}
+	
+	applied := make(map[string]string)
+	rows, err := m.db.Query("SELECT version, checksum FROM schema_seeds")
+	if err != nil {
+		return fmt.Errorf("failed to query seeds: %w", err)
+	}
+	for rows.Next() {
+		var version, checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = checksum
+	}
+	rows.Close()
+	
+	count := 0
+	for _, seed := range seeds {
+		if checksum, exists := applied[seed.Version]; exists {
+			if checksum != seed.Checksum {
+				return fmt.Errorf("seed %s_%s has been modified after being applied", seed.Version, seed.Name)
+			}
+			continue
+		}
+		
+		fmt.Printf("Applying seed %s: %s...\n", seed.Version, seed.Name)
 		
-		// Start transaction
 		tx, err := m.db.Begin()
 		if err != nil {
 			return fmt.Errorf("failed to start transaction: %w", err)
 		}
 		
-		// Execute migration
-		if _, err := tx.Exec(migration.UpSQL); err != nil {
+		if _, err := tx.Exec(seed.UpSQL); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
+			return fmt.Errorf("failed to apply seed %s: %w", seed.Version, err)
 		}
 		
-		// Record migration
 		if _, err := tx.Exec(
-			"INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
-			migration.Version, migration.Name,
+			"INSERT INTO schema_seeds (version, name, checksum) VALUES (?, ?, ?)",
+			seed.Version, seed.Name, seed.Checksum,
 		); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+			return fmt.Errorf("failed to record seed %s: %w", seed.Version, err)
 		}
 		
-		// Commit transaction
 		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
+			return fmt.Errorf("failed to commit seed %s: %w", seed.Version, err)
 		}
 		
-		fmt.Printf("✓ Migration %s applied successfully\n", migration.Version)
+		fmt.Printf("✓ Seed %s applied successfully\n", seed.Version)
+		count++
 	}
 	
-	fmt.Printf("\nApplied %d migration(s)\n", len(pending))
+	if count == 0 {
+		fmt.Println("No pending seeds")
+		return nil
+	}
+	
+	fmt.Printf("\nApplied %d seed(s)\n", count)
 	return nil
 }
 
-// Down rolls back the last applied migration
-func (m *Migrator) Down() error {
-	// Get last applied migration
-	row := m.db.QueryRow("SELECT version, name FROM schema_migrations ORDER BY version DESC LIMIT 1")
+// Create scaffolds empty up/down files for a new migration using the next
+// sequential version number, and returns the version it was given
+func (m *Migrator) Create(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("migration name is required")
+	}
+	if strings.ContainsAny(name, " \t/\\") || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("migration name %q must not contain spaces or path separators", name)
+	}
 	
-	var version, name string
-	if err := row.Scan(&version, &name); err != nil {
-		if err == sql.ErrNoRows {
-			fmt.Println("No migrations to rollback")
-			return nil
+	files, err := ioutil.ReadDir(m.migrationsDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+	
+	// Find the highest existing version across both up and down files
+	highest := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".sql") {
+			continue
+		}
+		number, err := strconv.Atoi(strings.Split(file.Name(), "_")[0])
+		if err != nil {
+			continue
+		}
+		if number > highest {
+			highest = number
 		}
-		return fmt.Errorf("failed to get last migration: %w", err)
 	}
+	version := fmt.Sprintf("%03d", highest+1)
 	
+	created := time.Now().Format("2006-01-02 15:04:05")
+	stubs := map[string]string{
+		"up":   fmt.Sprintf("-- Migration %s: %s\n-- Created at %s\n-- Write the SQL that applies this migration below\n\n", version, name, created),
+		"down": fmt.Sprintf("-- Migration %s: %s\n-- Created at %s\n-- Write the SQL that reverts this migration below\n\n", version, name, created),
+	}
+	
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(m.migrationsDir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		// O_EXCL guards against overwriting a file created in the meantime
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to create migration file %s: %w", path, err)
+		}
+		if _, err := file.WriteString(stubs[direction]); err != nil {
+			file.Close()
+			return "", fmt.Errorf("failed to write migration file %s: %w", path, err)
+		}
+		if err := file.Close(); err != nil {
+			return "", fmt.Errorf("failed to write migration file %s: %w", path, err)
+		}
+		fmt.Printf("✓ Created %s\n", path)
+	}
+	
+	return version, nil
+}
+
+// apply runs a single up migration and records it in one transaction
+func (m *Migrator) apply(migration Migration) error {
+	fmt.Printf("Applying migration %s: %s...\n", migration.Version, migration.Name)
+	
+	// Start transaction
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	
+	// Execute migration
+	if _, err := tx.Exec(migration.UpSQL); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
+	}
+	
+	// Record migration
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, migration.Checksum,
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+	}
+	
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
+	}
+	
+	fmt.Printf("✓ Migration %s applied successfully\n", migration.Version)
+	return nil
+}
+
+// rollback runs a single down migration and removes its record in one transaction
+func (m *Migrator) rollback(version, name string) error {
 	// Read down migration file
 	downFile := fmt.Sprintf("%s_%s.down.sql", version, name)
 	downPath := filepath.Join(m.migrationsDir, downFile)
@@ -226,11 +697,28 @@ func (m *Migrator) Down() error {
 
 // Status shows current migration status
 func (m *Migrator) Status() error {
+	// Verify first so legacy rows are backfilled; report the error after printing
+	verifyErr := m.VerifyChecksums()
+	
 	applied, err := m.GetAppliedMigrations()
 	if err != nil {
 		return err
 	}
 	
+	checksums, err := m.getAppliedChecksums()
+	if err != nil {
+		return err
+	}
+	
+	migrations, err := m.loadMigrations()
+	if err != nil {
+		return err
+	}
+	current := make(map[string]string)
+	for _, migration := range migrations {
+		current[migration.Version] = migration.Checksum
+	}
+	
 	pending, err := m.GetPendingMigrations()
 	if err != nil {
 		return err
@@ -251,7 +739,12 @@ func (m *Migrator) Status() error {
 		sort.Strings(versions)
 		
 		for _, v := range versions {
-			fmt.Printf("  ✓ %s (applied at %s)\n", v, applied[v].Format("2006-01-02 15:04:05"))
+			mark := "✓"
+			if sum, ok := current[v]; ok && checksums[v] != "" && sum != checksums[v] {
+				mark = "✗ MODIFIED"
+			}
+			fmt.Printf("  %s %s (applied at %s, checksum %s)\n", mark, v,
+				applied[v].Format("2006-01-02 15:04:05"), shortChecksum(checksums[v]))
 		}
 		fmt.Println()
 	}
@@ -259,9 +752,20 @@ func (m *Migrator) Status() error {
 	if len(pending) > 0 {
 		fmt.Println("Pending Migrations:")
 		for _, m := range pending {
-			fmt.Printf("  ○ %s: %s\n", m.Version, m.Name)
+			fmt.Printf("  ○ %s: %s (checksum %s)\n", m.Version, m.Name, shortChecksum(m.Checksum))
 		}
 	}
 	
-	return nil
+	return verifyErr
+}
+
+// shortChecksum abbreviates a checksum for display
+func shortChecksum(checksum string) string {
+	if checksum == "" {
+		return "none"
+	}
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
 }
@@ -0,0 +1,255 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestMigrator returns a migrator over an empty database in a temporary
+// file, with foreign keys enforced as in production
+func newTestMigrator(t *testing.T) (*Migrator, *sql.DB) {
+	t.Helper()
+	return openTestMigrator(t, filepath.Join(t.TempDir(), "forum.db"))
+}
+
+// openTestMigrator opens the database at path with its own connection pool,
+// as another instance of the app would, and returns a migrator over it
+func openTestMigrator(t *testing.T, path string) (*Migrator, *sql.DB) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator := NewMigrator(db, "../database/migrations")
+	if err := migrator.InitMigrationsTable(); err != nil {
+		t.Fatal(err)
+	}
+	return migrator, db
+}
+
+func TestMigrateUpAfterRollingBackTheSeed(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// A user signing up after the seed takes the next id
+	if _, err := db.Exec("INSERT INTO users (email, username, password) VALUES ('frank@example.com', 'frank', 'x')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.MigrateTo("001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up after rolling back to 001: %v", err)
+	}
+
+	var id int
+	if err := db.QueryRow("SELECT id FROM users WHERE username = 'alice'").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Errorf("alice has id %d after the round trip, want 1", id)
+	}
+}
+
+func TestEveryDownMigrationCanBeReapplied(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := migrator.loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Roll back one migration at a time down to nothing, then go back up
+	for range migrations {
+		if err := migrator.Down(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var applied int
+	db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	if applied != 0 {
+		t.Fatalf("%d migrations still applied after rolling back all of them", applied)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up after rolling back everything: %v", err)
+	}
+
+	var posts int
+	db.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id IN (SELECT id FROM users)").Scan(&posts)
+	if posts != 5 {
+		t.Errorf("%d seeded posts with an author, want 5", posts)
+	}
+}
+
+// appliedVersions returns the applied migration versions, oldest first
+func appliedVersions(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// wantVersions fails the test unless exactly the migrations up to and
+// including last are applied
+func wantVersions(t *testing.T, db *sql.DB, last string) {
+	t.Helper()
+	versions := appliedVersions(t, db)
+	if len(versions) == 0 || versions[len(versions)-1] != last {
+		t.Fatalf("applied %v, want up to %s", versions, last)
+	}
+	for i, version := range versions {
+		if want := fmt.Sprintf("%03d", i+1); version != want {
+			t.Fatalf("applied %v, want every version up to %s", versions, last)
+		}
+	}
+}
+
+func TestMigrateToForward(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+
+	if err := migrator.MigrateTo("003"); err != nil {
+		t.Fatal(err)
+	}
+	wantVersions(t, db, "003")
+
+	// 003 added the draft status, 004 not yet applied adds soft deletes
+	if _, err := db.Exec("SELECT status FROM posts"); err != nil {
+		t.Errorf("posts.status missing after migrating to 003: %v", err)
+	}
+	if _, err := db.Exec("SELECT deleted_at FROM posts"); err == nil {
+		t.Error("posts.deleted_at exists before migration 004")
+	}
+}
+
+func TestMigrateToBackward(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.MigrateTo("003"); err != nil {
+		t.Fatal(err)
+	}
+	wantVersions(t, db, "003")
+	if _, err := db.Exec("SELECT deleted_at FROM posts"); err == nil {
+		t.Error("posts.deleted_at still exists after rolling back to 003")
+	}
+}
+
+func TestRollingBackSoftDeleteKeepsRoles(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE users SET role = 'admin' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Undo only the soft delete migration, leaving everything after it
+	downSQL, err := os.ReadFile("../database/migrations/004_add_soft_delete.down.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(downSQL)); err != nil {
+		t.Fatalf("rolling back 004: %v", err)
+	}
+	if _, err := db.Exec("SELECT deleted_at FROM posts"); err == nil {
+		t.Fatal("posts.deleted_at still exists after rolling back 004")
+	}
+
+	var role string
+	if err := db.QueryRow("SELECT role FROM users WHERE id = 1").Scan(&role); err != nil {
+		t.Fatalf("users.role lost with soft delete: %v", err)
+	}
+	if role != "admin" {
+		t.Errorf("role %q after rolling back soft delete, want admin", role)
+	}
+}
+
+func TestMigrateToCurrentVersion(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.MigrateTo("002"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.MigrateTo("002"); err != nil {
+		t.Fatalf("migrating to the current version: %v", err)
+	}
+	wantVersions(t, db, "002")
+}
+
+func TestMigrateToUnknownVersion(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+	if err := migrator.MigrateTo("002"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := migrator.MigrateTo("999")
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("err = %v, want a does not exist error", err)
+	}
+	wantVersions(t, db, "002")
+}
+
+func TestConcurrentUpAppliesEachMigrationOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forum.db")
+	first, db := openTestMigrator(t, path)
+	second, _ := openTestMigrator(t, path)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, migrator := range []*Migrator{first, second} {
+		wg.Add(1)
+		go func(m *Migrator) {
+			defer wg.Done()
+			errs <- m.Up()
+		}(migrator)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := first.loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied := appliedVersions(t, db); len(applied) != len(migrations) {
+		t.Errorf("applied %v, want each of the %d migrations once", applied, len(migrations))
+	}
+	// The seed inserts five users; a second run would have failed or doubled them
+	var users int
+	db.QueryRow("SELECT COUNT(*) FROM users").Scan(&users)
+	if users != 5 {
+		t.Errorf("%d users after two concurrent runs, want 5", users)
+	}
+}
@@ -2,13 +2,18 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrCategoryNotFound is returned for a category that does not exist
+var ErrCategoryNotFound = errors.New("category not found")
+
 type Category struct {
 	ID         int
 	Label      string
+	Slug       string
 	PostsCount int
 }
 
@@ -18,13 +23,17 @@ func FetchCategories(db *sql.DB) ([]Category, error) {
 		SELECT
 			c.id,
 			c.label,
+			COALESCE(c.slug, ''),
 			(
 				SELECT
-					COUNT(id)
+					COUNT(pc.id)
 				FROM
 					post_category pc
+				INNER JOIN posts p ON p.id = pc.post_id
 				WHERE
 					pc.category_id = c.id
+					AND p.status = 'published'
+					AND p.deleted_at IS NULL
 			) as posts_count
 		FROM categories c
 		ORDER BY posts_count DESC;
@@ -36,13 +45,31 @@ func FetchCategories(db *sql.DB) ([]Category, error) {
 	defer rows.Close()
 	for rows.Next() {
 		var category Category
-		rows.Scan(&category.ID, &category.Label, &category.PostsCount)
+		rows.Scan(&category.ID, &category.Label, &category.Slug, &category.PostsCount)
 		categories = append(categories, category)
 	}
 	return categories, nil
 }
 
 func CheckCategories(db *sql.DB, ids []int) error {
+	missing, err := MissingCategories(db, ids)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("categories does not exists in db")
+	}
+
+	return nil
+}
+
+// MissingCategories returns the IDs in ids that are not categories, in the
+// order given, looking them all up in a single query
+func MissingCategories(db *sql.DB, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
 	placeholders := strings.Repeat("?,", len(ids))
 	placeholders = placeholders[:len(placeholders)-1]
 
@@ -59,20 +86,26 @@ func CheckCategories(db *sql.DB, ids []int) error {
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
-	var count int
+	found := make(map[int]bool, len(ids))
 	for rows.Next() {
 		var id int
 		if err := rows.Scan(&id); err != nil {
-			return err
+			return nil, err
 		}
-		count++
+		found[id] = true
 	}
-	if count != len(ids) {
-		return fmt.Errorf("categories does not exists in db")
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	var missing []int
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
 }
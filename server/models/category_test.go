@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// countingConnector opens SQLite connections that count the statements
+// prepared on them. The connections only offer Prepare, so database/sql
+// prepares every query and exec it sends.
+type countingConnector struct {
+	dsn        string
+	statements atomic.Int64
+}
+
+func (c *countingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn, &c.statements}, nil
+}
+
+func (c *countingConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+type countingConn struct {
+	driver.Conn
+	statements *atomic.Int64
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.statements.Add(1)
+	return c.Conn.Prepare(query)
+}
+
+func TestMissingCategories(t *testing.T) {
+	db := testutil.NewDB(t)
+	tests := []struct {
+		name string
+		ids  []int
+		want []int
+	}{
+		{"all valid", []int{1, 2, 3}, nil},
+		{"mixed", []int{999, 1, 998, 2}, []int{999, 998}},
+		{"all invalid", []int{42}, []int{42}},
+		{"none", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, err := MissingCategories(db, tt.ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(missing, tt.want) {
+				t.Errorf("MissingCategories(%v) = %v, want %v", tt.ids, missing, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingCategoriesUsesOneQuery(t *testing.T) {
+	// Migrate the file first, then count on a second pool over it
+	path := filepath.Join(t.TempDir(), "forum.db")
+	migrated := testutil.NewDBAt(t, path)
+	migrated.Close()
+
+	// NewDBAt pointed DB_PATH at path, so this is the DSN the server uses
+	connector := &countingConnector{dsn: config.LoadConfig().Database.DataSourceName("")}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	missing, err := MissingCategories(db, []int{1, 2, 3, 4, 5, 999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(missing, []int{999}) {
+		t.Fatalf("missing = %v, want [999]", missing)
+	}
+	if n := connector.statements.Load(); n != 1 {
+		t.Errorf("checking 6 categories ran %d statements, want 1", n)
+	}
+}
@@ -1,28 +1,46 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"forum/server/config"
+	"forum/server/queries"
 )
 
+// ErrCommentNotFound is returned for a comment that does not exist or is
+// deleted
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrCommentLimit is returned when a post already has as many comments as
+// it may take
+var ErrCommentLimit = errors.New("comment limit reached")
+
 type Comment struct {
 	ID        int
 	UserID    int
 	PostID    int
 	UserName  string
+	AvatarURL string
 	Content   string
 	Likes     int
 	Dislikes  int
 	CreatedAt string
 }
 
-func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
+// FetchCommentsByPostID returns the visible comments of a post in the given
+// order. excludeSelf leaves the authors' own reactions out of the counts.
+func FetchCommentsByPostID(ctx context.Context, postID int, db *sql.DB, sort queries.CommentSort, excludeSelf bool) ([]Comment, error) {
 	var comments []Comment
 	query := `
 	SELECT
 		c.id,
 		c.user_id,
 		u.username,
+		COALESCE(u.avatar_path, ''),
 		c.content,
 		strftime('%m/%d/%Y %I:%M %p', c.created_at) AS formatted_created_at,
 		(
@@ -32,7 +50,7 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 				comment_reactions AS cr
 			WHERE
 				cr.comment_id = c.id
-				AND cr.reaction = 'like'
+				AND cr.reaction = 'like'` + notSelfReaction(excludeSelf, "cr", "c") + `
 		) AS likes_count,
 		(
 			SELECT
@@ -41,7 +59,7 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 				comment_reactions AS cr
 			WHERE
 				cr.comment_id = c.id
-				AND cr.reaction = 'dislike'
+				AND cr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "cr", "c") + `
 		) AS dislikes_count
 	FROM
 		comments c
@@ -49,11 +67,11 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 	ON c.user_id = u.id
 	WHERE
 		c.post_id = ?
+		AND c.deleted_at IS NULL
 	ORDER BY
-		c.created_at DESC
-	`
+		` + sort.OrderBy()
 
-	rows, err := db.Query(query, postID)
+	rows, err := db.QueryContext(ctx, query, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +83,7 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 			&comment.ID,
 			&comment.UserID,
 			&comment.UserName,
+			&comment.AvatarURL,
 			&comment.Content,
 			&comment.CreatedAt,
 			&comment.Likes,
@@ -76,6 +95,7 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 
 		// Assign the post ID and format the created_at field
 		comment.PostID = postID
+		comment.AvatarURL = config.AvatarURL(comment.UserID, comment.AvatarURL)
 		// comment.CreatedAt = utils.FormatTime(comment.CreatedAt)
 
 		// Append the comment to the slice
@@ -85,17 +105,67 @@ func FetchCommentsByPostID(postID int, db *sql.DB) ([]Comment, error) {
 	return comments, nil
 }
 
-func StoreComment(db *sql.DB, user_id, post_id int, content string) (int64, error) {
-	query := `INSERT INTO comments (user_id,post_id,content) VALUES (?,?,?)`
+// StoreComment adds a comment, as InsertComment does, and notifies the
+// post author
+func StoreComment(db *sql.DB, user_id, post_id int, content string, maxComments int) (int64, error) {
+	commentID, err := InsertComment(db, user_id, post_id, content, maxComments)
+	if err != nil {
+		return 0, err
+	}
+	logNotifyError(NotifyPostComment(db, user_id, post_id, commentID))
+
+	return commentID, nil
+}
 
-	result, err := db.Exec(query, user_id, post_id, content)
+// InsertComment adds a comment to a post unless the post already has
+// maxComments visible ones (0 means no limit), in which case it returns
+// ErrCommentLimit. The count is taken by the INSERT itself, so comments
+// sent at the same moment cannot push a post past the limit.
+func InsertComment(db *sql.DB, userID, postID int, content string, maxComments int) (int64, error) {
+	result, err := db.Exec(`
+		INSERT INTO comments (user_id, post_id, content)
+		SELECT ?, ?, ?
+		WHERE ? <= 0
+		OR (SELECT COUNT(*) FROM comments WHERE post_id = ? AND deleted_at IS NULL) < ?`,
+		userID, postID, content, maxComments, postID, maxComments,
+	)
 	if err != nil {
-		return 0, fmt.Errorf("%v", err)
+		return 0, fmt.Errorf("failed to insert comment: %w", err)
 	}
 
-	commentID, _ := result.LastInsertId()
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check affected rows: %w", err)
+	}
+	if rows == 0 {
+		return 0, ErrCommentLimit
+	}
 
-	return commentID, nil
+	return result.LastInsertId()
+}
+
+// IsRepeatComment reports whether content is the same as the user's latest
+// comment on postID and that comment is less than window old. A window of
+// zero or less turns the check off.
+func IsRepeatComment(db *sql.DB, userID, postID int, content string, window time.Duration) (bool, error) {
+	if window <= 0 {
+		return false, nil
+	}
+
+	var latest string
+	query := `SELECT content FROM comments
+		WHERE user_id = ? AND post_id = ? AND deleted_at IS NULL
+		AND created_at >= datetime('now', ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+	err := db.QueryRow(query, userID, postID, fmt.Sprintf("-%f seconds", window.Seconds())).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking for a repeated comment: %v", err)
+	}
+	return latest == content, nil
 }
 
 func StoreCommentReaction(db *sql.DB, user_id, comment_id int, reaction string) (int64, error) {
@@ -113,7 +183,7 @@ func StoreCommentReaction(db *sql.DB, user_id, comment_id int, reaction string)
 // Count comments by post ID
 func CountCommentsByPostID(db *sql.DB, postID int) (int, error) {
 	var count int
-	query := "SELECT COUNT(*) FROM comments WHERE post_id = ?"
+	query := "SELECT COUNT(*) FROM comments WHERE post_id = ? AND deleted_at IS NULL"
 	err := db.QueryRow(query, postID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("error counting comments: %v", err)
@@ -132,37 +202,20 @@ func FetchCommentTimeByID(db *sql.DB, commentID int64) (string, error) {
 	return commentTime, nil
 }
 
-func ReactToComment(db *sql.DB, user_id, comment_id int, userReaction string) (int, int, error) {
-	var likeCount, dislikeCount int
-	var dbreaction string
-	var err error
-
-	db.QueryRow("SELECT reaction FROM comment_reactions WHERE user_id=? AND comment_id=?", user_id, comment_id).Scan(&dbreaction)
-
-	if dbreaction == "" {
-		_, err = StoreCommentReaction(db, user_id, comment_id, userReaction)
-	} else {
-		if userReaction == dbreaction {
-			query := "DELETE FROM comment_reactions WHERE user_id = ? AND comment_id = ?"
-			_, err = db.Exec(query, user_id, comment_id)
-
-		} else {
-			query := "UPDATE comment_reactions SET reaction = ? WHERE user_id = ? AND comment_id = ?"
-			_, err = db.Exec(query, userReaction, user_id, comment_id)
-		}
-	}
+// FetchCommentPostID returns the post a comment belongs to
+func FetchCommentPostID(db *sql.DB, commentID int) (int, error) {
+	var postID int
+	err := db.QueryRow("SELECT post_id FROM comments WHERE id = ?", commentID).Scan(&postID)
 	if err != nil {
-		return 0, 0, err
+		return 0, fmt.Errorf("error fetching post of comment %d: %v", commentID, err)
 	}
+	return postID, nil
+}
 
-	// Fetch the new count of reactions for this post
-	err = db.QueryRow("SELECT COUNT(*) FROM comment_reactions WHERE comment_id=? AND reaction=?", comment_id, "like").Scan(&likeCount)
-	if err != nil {
-		return 0, 0, fmt.Errorf("error fetching likes count: %v", err)
-	}
-	err = db.QueryRow("SELECT COUNT(*) FROM comment_reactions WHERE comment_id=? AND reaction=?", comment_id, "dislike").Scan(&dislikeCount)
-	if err != nil {
-		return 0, 0, fmt.Errorf("error fetching likes count: %v", err)
-	}
-	return likeCount, dislikeCount, nil
+// ReactToComment toggles a reaction on a comment. A comment that is unknown
+// or deleted, or whose post is deleted, gives ErrCommentNotFound;
+// content.SelfReactions decides how the author's own reactions are
+// treated (see toggleReaction).
+func ReactToComment(db *sql.DB, content config.ContentConfig, user_id, comment_id int, userReaction string) (int, int, error) {
+	return toggleReaction(db, "comment_reactions", "comment_id", "comments", reactableCommentSQL, ErrCommentNotFound, content, user_id, comment_id, userReaction, NotifyCommentReaction)
 }
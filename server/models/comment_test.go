@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/server/testutil"
+)
+
+func countComments(t *testing.T, db *sql.DB, postID int) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = ? AND deleted_at IS NULL", postID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestInsertCommentLimit(t *testing.T) {
+	db := testutil.NewDB(t)
+	// Seeded post 1 has two comments
+	const postID, limit = 1, 3
+
+	if _, err := InsertComment(db, 4, postID, "Third comment", limit); err != nil {
+		t.Fatalf("comment below the limit: %v", err)
+	}
+	if _, err := InsertComment(db, 5, postID, "Fourth comment", limit); !errors.Is(err, ErrCommentLimit) {
+		t.Fatalf("comment at the limit: err = %v, want ErrCommentLimit", err)
+	}
+	if n := countComments(t, db, postID); n != limit {
+		t.Fatalf("%d comments, want %d", n, limit)
+	}
+
+	// A deleted comment frees its slot
+	if _, err := db.Exec("UPDATE comments SET deleted_at = CURRENT_TIMESTAMP WHERE post_id = ? AND content = 'Third comment'", postID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InsertComment(db, 5, postID, "Fourth comment", limit); err != nil {
+		t.Fatalf("comment after a deletion: %v", err)
+	}
+
+	// No limit
+	if _, err := InsertComment(db, 5, postID, "Fifth comment", 0); err != nil {
+		t.Fatalf("comment with the limit off: %v", err)
+	}
+}
+
+func TestConcurrentCommentsStayWithinLimit(t *testing.T) {
+	db := testutil.NewDB(t)
+	const postID, limit, senders = 1, 5, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, senders)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := InsertComment(db, 5, postID, fmt.Sprintf("Comment %d", i), limit)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	stored := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			stored++
+		case !errors.Is(err, ErrCommentLimit):
+			t.Fatal(err)
+		}
+	}
+	// Two seeded comments leave three slots
+	if stored != 3 {
+		t.Errorf("%d comments stored, want 3", stored)
+	}
+	if n := countComments(t, db, postID); n != limit {
+		t.Errorf("%d comments on the post, want %d", n, limit)
+	}
+}
+
+func TestIsRepeatComment(t *testing.T) {
+	db := testutil.NewDB(t)
+	const userID, postID = 5, 1
+	if _, err := InsertComment(db, userID, postID, "Double click", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		window  time.Duration
+		want    bool
+	}{
+		{"same content", "Double click", time.Minute, true},
+		{"different content", "Something else", time.Minute, false},
+		{"check turned off", "Double click", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsRepeatComment(db, userID, postID, tt.content, tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRepeatComment = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Only the latest comment counts, and only while it is in the window
+	if _, err := InsertComment(db, userID, postID, "A newer comment", 0); err != nil {
+		t.Fatal(err)
+	}
+	if repeated, _ := IsRepeatComment(db, userID, postID, "Double click", time.Minute); repeated {
+		t.Error("repeat of an earlier comment, not the latest")
+	}
+	if _, err := db.Exec("UPDATE comments SET created_at = datetime('now', '-2 minutes') WHERE user_id = ?", userID); err != nil {
+		t.Fatal(err)
+	}
+	if repeated, _ := IsRepeatComment(db, userID, postID, "A newer comment", time.Minute); repeated {
+		t.Error("repeat of a comment older than the window")
+	}
+	// Other users' comments do not count
+	if repeated, _ := IsRepeatComment(db, 2, postID, "A newer comment", time.Hour); repeated {
+		t.Error("repeat of another user's comment")
+	}
+}
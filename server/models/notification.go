@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Notifications are a side effect of commenting and reacting. The notify
+// functions below return their error so callers can log it, but a failed
+// notification must never undo or fail the action that caused it.
+//
+// Each one is a single INSERT ... SELECT that looks up the owner of the
+// post or comment and skips the insert when the actor is that owner.
+
+// NotifyPostComment tells a post's author that actorID commented on it.
+func NotifyPostComment(db *sql.DB, actorID, postID int, commentID int64) error {
+	_, err := db.Exec(`
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+		SELECT user_id, ?, 'comment', id, ?
+		FROM posts
+		WHERE id = ? AND user_id <> ?`,
+		actorID, commentID, postID, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to notify comment on post %d: %w", postID, err)
+	}
+	return nil
+}
+
+// NotifyPostReaction tells a post's author that actorID liked or disliked it.
+func NotifyPostReaction(db *sql.DB, actorID, postID int, reaction string) error {
+	_, err := db.Exec(`
+		INSERT INTO notifications (user_id, actor_id, type, post_id)
+		SELECT user_id, ?, 'post_' || ?, id
+		FROM posts
+		WHERE id = ? AND user_id <> ?`,
+		actorID, reaction, postID, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to notify reaction on post %d: %w", postID, err)
+	}
+	return nil
+}
+
+// NotifyCommentReaction tells a comment's author that actorID liked or
+// disliked it.
+func NotifyCommentReaction(db *sql.DB, actorID, commentID int, reaction string) error {
+	_, err := db.Exec(`
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+		SELECT user_id, ?, 'comment_' || ?, post_id, id
+		FROM comments
+		WHERE id = ? AND user_id <> ?`,
+		actorID, reaction, commentID, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to notify reaction on comment %d: %w", commentID, err)
+	}
+	return nil
+}
+
+// logNotifyError logs a failed notification and carries on.
+func logNotifyError(err error) {
+	if err != nil {
+		log.Println("Notification error:", err)
+	}
+}
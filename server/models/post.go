@@ -1,16 +1,25 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+
+	"forum/server/config"
+	"forum/server/queries"
 )
 
+// ErrPostNotFound is returned by lookups of a single post that does not
+// exist or is not visible to the viewer.
+var ErrPostNotFound = errors.New("post not found")
+
 type Post struct {
 	ID            int
 	UserID        int
 	UserName      string
+	AvatarURL     string // only set by FetchPost
 	Title         string
 	Content       string
 	CreatedAt     string
@@ -19,14 +28,26 @@ type Post struct {
 	Comments      int
 	CategoriesStr string
 	Categories    []string
+	Status        string
+	Locked        bool // closed to new comments; only set by FetchPost
+	Pinned        bool // kept at the top of the homepage and category listings
 }
 
 type PostDetail struct {
-	Post     Post
-	Comments []Comment
+	Post        Post
+	Images      []string // public URLs of attached images
+	Comments    []Comment
+	CommentSort queries.CommentSort
 }
 
-func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
+// PostsPageSize is how many posts one page of a listing shows
+const PostsPageSize = 10
+
+// FetchPosts returns a page of published posts for the homepage, and
+// whether another page follows. excludeSelf leaves the authors' own
+// reactions out of the counts. The query is abandoned when ctx is
+// cancelled.
+func FetchPosts(ctx context.Context, db *sql.DB, currentPage int, excludeSelf bool) ([]Post, bool, int, error) {
 	var posts []Post
 
 	// Query to fetch posts
@@ -44,7 +65,7 @@ func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'like'
+				AND pr.reaction = 'like'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS likes_count,
 		(
 			SELECT
@@ -53,7 +74,7 @@ func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'dislike'
+				AND pr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS dislikes_count,
 		(
 			SELECT
@@ -62,27 +83,26 @@ func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
 				comments c
 			WHERE
 				c.post_id = p.id
+				AND c.deleted_at IS NULL
 		) AS comments_count,
-		(
-			SELECT
-				GROUP_CONCAT(c.label)
-			FROM
-				categories c
-			INNER JOIN post_category pc ON c.id = pc.category_id
-			WHERE
-				pc.post_id = p.id
-		) AS categories
+		COALESCE(` + queries.CategoryLabelsSQL("p") + `, '') AS categories,
+		p.pinned
 	FROM
 		posts p
 		INNER JOIN users u ON p.user_id = u.id
+	WHERE p.status = 'published'
+	AND p.deleted_at IS NULL
 	ORDER BY
+		p.pinned DESC,
+		p.pin_order,
 		p.created_at DESC
-	LIMIT 10 OFFSET ? ;
+	LIMIT ? OFFSET ? ;
 	`
-	rows, err := db.Query(query, currentPage)
+	// One post past the page tells whether there is a next one
+	rows, err := db.QueryContext(ctx, query, PostsPageSize+1, currentPage)
 	if err != nil {
 		log.Println("Error executing query:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 	defer rows.Close()
 
@@ -99,13 +119,13 @@ func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
 			&post.Likes,
 			&post.Dislikes,
 			&post.Comments,
-			&post.CategoriesStr)
+			&post.CategoriesStr,
+			&post.Pinned)
 		if err != nil {
 			log.Println("Error scanning row:", err)
-			return nil, 500, err
+			return nil, false, 500, err
 		}
-		// it came from the  database as "technology,sports...", so we need to split it
-		post.Categories = strings.Split(post.CategoriesStr, ",")
+		post.Categories = queries.SplitCategoryLabels(post.CategoriesStr)
 
 		// Format the created_at field to a more readable format
 		// post.CreatedAt = utils.FormatTime(post.CreatedAt)
@@ -116,13 +136,20 @@ func FetchPosts(db *sql.DB, currentPage int) ([]Post, int, error) {
 	// Check for errors during iteration
 	if err = rows.Err(); err != nil {
 		log.Println("Error iterating rows:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 
-	return posts, 200, nil
+	if len(posts) > PostsPageSize {
+		return posts[:PostsPageSize], true, 200, nil
+	}
+	return posts, false, 200, nil
 }
 
-func FetchPost(db *sql.DB, postID int) (PostDetail, int, error) {
+// FetchPost loads a post with its comments in the given order. Drafts are
+// only visible to their author, so viewerID must be the current user (or
+// -1 for guests). excludeSelf leaves the authors' own reactions out of the
+// post and comment counts. The queries are abandoned when ctx is cancelled.
+func FetchPost(ctx context.Context, db *sql.DB, postID, viewerID int, sort queries.CommentSort, excludeSelf bool) (PostDetail, int, error) {
 	var post Post
 	post.ID = postID
 
@@ -130,6 +157,7 @@ func FetchPost(db *sql.DB, postID int) (PostDetail, int, error) {
 	query := `SELECT
 		p.user_id,
 		u.username,
+		COALESCE(u.avatar_path, ''),
 		p.title,
 		p.content,
 		strftime('%m/%d/%Y %I:%M %p', p.created_at) AS formatted_created_at,
@@ -137,69 +165,90 @@ func FetchPost(db *sql.DB, postID int) (PostDetail, int, error) {
 			SELECT COUNT(*)
 			FROM post_reactions AS pr
 			WHERE pr.post_id = p.id
-			AND pr.reaction = 'like'
+			AND pr.reaction = 'like'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS likes_count,
 		(
 			SELECT COUNT(*)
 			FROM post_reactions AS pr
 			WHERE pr.post_id = p.id
-			AND pr.reaction = 'dislike'
+			AND pr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS dislikes_count,
 		(
 			SELECT COUNT(*)
 			FROM comments c
 			WHERE c.post_id = p.id
+			AND c.deleted_at IS NULL
 		) AS comments_count,
-		(
-			SELECT GROUP_CONCAT(c.label)
-			FROM categories c
-			INNER JOIN post_category pc ON c.id = pc.category_id
-			WHERE pc.post_id = p.id
-		) AS categories
+		COALESCE(` + queries.CategoryLabelsSQL("p") + `, '') AS categories,
+		p.status,
+		p.locked,
+		p.pinned
 	FROM
 		posts p
 		INNER JOIN users u ON p.user_id = u.id
-	WHERE p.id = ?`
+	WHERE p.id = ?
+	AND p.deleted_at IS NULL
+	AND (p.status = 'published' OR p.user_id = ?)`
 
 	// Use QueryRow for a single result
-	row := db.QueryRow(query, postID)
+	row := db.QueryRowContext(ctx, query, postID, viewerID)
 
 	// Scan the data into the Post struct
 	err := row.Scan(
 		&post.UserID,
 		&post.UserName,
+		&post.AvatarURL,
 		&post.Title,
 		&post.Content,
 		&post.CreatedAt,
 		&post.Likes,
 		&post.Dislikes,
 		&post.Comments,
-		&post.CategoriesStr)
+		&post.CategoriesStr,
+		&post.Status,
+		&post.Locked,
+		&post.Pinned)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return PostDetail{}, 404, fmt.Errorf("post not found: %w", err)
+			return PostDetail{}, 404, ErrPostNotFound
 		}
 		log.Println("Error scanning row:", err)
 		return PostDetail{}, 500, err
 	}
 
 	// Process categories
-	post.Categories = strings.Split(post.CategoriesStr, ",")
+	post.Categories = queries.SplitCategoryLabels(post.CategoriesStr)
+	post.AvatarURL = config.AvatarURL(post.UserID, post.AvatarURL)
 
 	// Format the created_at field
 	// post.CreatedAt = post.CreatedAt.Format("01/02/2006 03:04 PM")
-	comments, err := FetchCommentsByPostID(postID, db)
+	comments, err := FetchCommentsByPostID(ctx, postID, db, sort, excludeSelf)
 	if err != nil {
 		log.Println("Error fetching comments from the database:", err)
 	}
 
+	images, err := FetchPostImages(ctx, db, postID)
+	if err != nil {
+		log.Println("Error fetching post images:", err)
+	}
+
+	// Missing comments or images are tolerated above, but not a request
+	// that was cancelled or ran out of time halfway through
+	if err := ctx.Err(); err != nil {
+		return PostDetail{}, 500, err
+	}
+
 	return PostDetail{
-		Post:     post,
-		Comments: comments,
+		Post:        post,
+		Images:      images,
+		Comments:    comments,
+		CommentSort: sort,
 	}, 200, nil
 }
 
-func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post, int, error) {
+// FetchPostsByCategory returns a page of the published posts in a
+// category, and whether another page follows
+func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int, excludeSelf bool) ([]Post, bool, int, error) {
 	var posts []Post
 	query := `
 		SELECT
@@ -216,7 +265,7 @@ func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post,
 					post_reactions AS pr
 				WHERE
 					pr.post_id = p.id
-					AND pr.reaction = 'like'
+					AND pr.reaction = 'like'` + notSelfReaction(excludeSelf, "pr", "p") + `
 			) AS likes_count,
 			(
 				SELECT
@@ -225,7 +274,7 @@ func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post,
 					post_reactions AS pr
 				WHERE
 					pr.post_id = p.id
-					AND pr.reaction = 'dislike'
+					AND pr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "pr", "p") + `
 			) AS dislikes_count,
 			(
 				SELECT
@@ -234,29 +283,27 @@ func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post,
 					comments c
 				WHERE
 					c.post_id = p.id
+					AND c.deleted_at IS NULL
 			) AS comments_count,
-			(
-				SELECT
-					GROUP_CONCAT(c.label)
-				FROM
-					categories c
-				INNER JOIN post_category pc ON c.id = pc.category_id
-				WHERE
-					pc.post_id = p.id
-			) AS categories
+			COALESCE(` + queries.CategoryLabelsSQL("p") + `, '') AS categories,
+			p.pinned
 		FROM
 			posts p
 			INNER JOIN users u ON p.user_id = u.id
 			INNER JOIN post_category pc ON p.id = pc.post_id
 		WHERE pc.category_id = ?
+		AND p.status = 'published'
+		AND p.deleted_at IS NULL
 		ORDER BY
+			p.pinned DESC,
+			p.pin_order,
 			p.created_at
-		LIMIT 10 OFFSET ? ;
+		LIMIT ? OFFSET ? ;
 	`
-	rows, err := db.Query(query, categoryID, currentpage)
+	rows, err := db.Query(query, categoryID, PostsPageSize+1, currentpage)
 	if err != nil {
 		log.Println("Error executing query:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 	defer rows.Close()
 	for rows.Next() {
@@ -270,14 +317,14 @@ func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post,
 			&post.Likes,
 			&post.Dislikes,
 			&post.Comments,
-			&post.CategoriesStr)
+			&post.CategoriesStr,
+			&post.Pinned)
 		if err != nil {
 			log.Println("Error scanning row:", err)
-			return nil, 500, err
+			return nil, false, 500, err
 		}
 
-		// it came from the  database as "technology,sports...", so we need to split it
-		post.Categories = strings.Split(post.CategoriesStr, ",")
+		post.Categories = queries.SplitCategoryLabels(post.CategoriesStr)
 
 		// post.CreatedAt = utils.FormatTime(post.CreatedAt)
 
@@ -287,13 +334,18 @@ func FetchPostsByCategory(db *sql.DB, categoryID int, currentpage int) ([]Post,
 	// Check for errors during iteration
 	if err = rows.Err(); err != nil {
 		log.Println("Error iterating rows:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 
-	return posts, 200, nil
+	if len(posts) > PostsPageSize {
+		return posts[:PostsPageSize], true, 200, nil
+	}
+	return posts, false, 200, nil
 }
 
-func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, int, error) {
+// FetchCreatedPostsByUser lists a user's own posts, drafts included, and
+// whether another page follows
+func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int, excludeSelf bool) ([]Post, bool, int, error) {
 	var posts []Post
 
 	// Query to fetch posts
@@ -311,7 +363,7 @@ func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post,
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'like'
+				AND pr.reaction = 'like'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS likes_count,
 		(
 			SELECT
@@ -320,7 +372,7 @@ func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post,
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'dislike'
+				AND pr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS dislikes_count,
 		(
 			SELECT
@@ -329,28 +381,23 @@ func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post,
 				comments c
 			WHERE
 				c.post_id = p.id
+				AND c.deleted_at IS NULL
 		) AS comments_count,
-		(
-			SELECT
-				GROUP_CONCAT(c.label)
-			FROM
-				categories c
-			INNER JOIN post_category pc ON c.id = pc.category_id
-			WHERE
-				pc.post_id = p.id
-		) AS categories
+		COALESCE(` + queries.CategoryLabelsSQL("p") + `, '') AS categories,
+		p.status
 	FROM
 		posts p
 		INNER JOIN users u ON p.user_id = u.id
 	WHERE p.user_id = ?
+	AND p.deleted_at IS NULL
 	ORDER BY
 		p.created_at DESC
-	LIMIT 10 OFFSET ? ;
+	LIMIT ? OFFSET ? ;
 	`
-	rows, err := db.Query(query, user_id, currentPage)
+	rows, err := db.Query(query, user_id, PostsPageSize+1, currentPage)
 	if err != nil {
 		log.Println("Error executing query:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 	defer rows.Close()
 
@@ -367,13 +414,13 @@ func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post,
 			&post.Likes,
 			&post.Dislikes,
 			&post.Comments,
-			&post.CategoriesStr)
+			&post.CategoriesStr,
+			&post.Status)
 		if err != nil {
 			log.Println("Error scanning row:", err)
-			return nil, 500, err
+			return nil, false, 500, err
 		}
-		// it came from the  database as "technology,sports...", so we need to split it
-		post.Categories = strings.Split(post.CategoriesStr, ",")
+		post.Categories = queries.SplitCategoryLabels(post.CategoriesStr)
 
 		// Format the created_at field to a more readable format
 		// post.CreatedAt = utils.FormatTime(post.CreatedAt)
@@ -385,13 +432,18 @@ func FetchCreatedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post,
 	// Check for errors during iteration
 	if err = rows.Err(); err != nil {
 		log.Println("Error iterating rows:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 
-	return posts, 200, nil
+	if len(posts) > PostsPageSize {
+		return posts[:PostsPageSize], true, 200, nil
+	}
+	return posts, false, 200, nil
 }
 
-func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, int, error) {
+// FetchLikedPostsByUser lists the posts a user has liked, and whether
+// another page follows
+func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int, excludeSelf bool) ([]Post, bool, int, error) {
 	var posts []Post
 
 	// Query to fetch posts
@@ -409,7 +461,7 @@ func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, in
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'like'
+				AND pr.reaction = 'like'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS likes_count,
 		(
 			SELECT
@@ -418,7 +470,7 @@ func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, in
 				post_reactions AS pr
 			WHERE
 				pr.post_id = p.id
-				AND pr.reaction = 'dislike'
+				AND pr.reaction = 'dislike'` + notSelfReaction(excludeSelf, "pr", "p") + `
 		) AS dislikes_count,
 		(
 			SELECT
@@ -427,29 +479,23 @@ func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, in
 				comments c
 			WHERE
 				c.post_id = p.id
+				AND c.deleted_at IS NULL
 		) AS comments_count,
-		(
-			SELECT
-				GROUP_CONCAT(c.label)
-			FROM
-				categories c
-			INNER JOIN post_category pc ON c.id = pc.category_id
-			WHERE
-				pc.post_id = p.id
-		) AS categories
+		COALESCE(` + queries.CategoryLabelsSQL("p") + `, '') AS categories
 	FROM
 		posts p
 		INNER JOIN users u ON p.user_id = u.id
 		INNER JOIN post_reactions pr ON p.id = pr.post_id
-	WHERE pr.user_id = ? AND pr.reaction = 'like' 
+	WHERE pr.user_id = ? AND pr.reaction = 'like' AND p.status = 'published'
+		AND p.deleted_at IS NULL
 	ORDER BY
 		p.created_at DESC
-	LIMIT 10 OFFSET ? ;
+	LIMIT ? OFFSET ? ;
 	`
-	rows, err := db.Query(query, user_id, currentPage)
+	rows, err := db.Query(query, user_id, PostsPageSize+1, currentPage)
 	if err != nil {
 		log.Println("Error executing query:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 	defer rows.Close()
 
@@ -469,10 +515,9 @@ func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, in
 			&post.CategoriesStr)
 		if err != nil {
 			log.Println("Error scanning row:", err)
-			return nil, 500, err
+			return nil, false, 500, err
 		}
-		// it came from the  database as "technology,sports...", so we need to split it
-		post.Categories = strings.Split(post.CategoriesStr, ",")
+		post.Categories = queries.SplitCategoryLabels(post.CategoriesStr)
 
 		// Format the created_at field to a more readable format
 		// post.CreatedAt = utils.FormatTime(post.CreatedAt)
@@ -484,36 +529,78 @@ func FetchLikedPostsByUser(db *sql.DB, user_id int, currentPage int) ([]Post, in
 	// Check for errors during iteration
 	if err = rows.Err(); err != nil {
 		log.Println("Error iterating rows:", err)
-		return nil, 500, err
+		return nil, false, 500, err
 	}
 
-	return posts, 200, nil
+	if len(posts) > PostsPageSize {
+		return posts[:PostsPageSize], true, 200, nil
+	}
+	return posts, false, 200, nil
 }
 
-func StorePost(db *sql.DB, user_id int, title, content string) (int64, error) {
-	query := `INSERT INTO posts (user_id,title,content) VALUES (?,?,?)`
+// StorePost inserts a post, either published right away or as a draft,
+// together with its category links. Both happen in one transaction: if a
+// category was deleted since the caller checked it, nothing is stored and
+// the error wraps ErrCategoryNotFound.
+func StorePost(db *sql.DB, user_id int, title, content string, draft bool, categoryIDs []int) (int64, error) {
+	query := `INSERT INTO posts (user_id,title,content,status,published_at) VALUES (?,?,?,'published',CURRENT_TIMESTAMP)`
+	if draft {
+		query = `INSERT INTO posts (user_id,title,content,status) VALUES (?,?,?,'draft')`
+	}
 
-	result, err := db.Exec(query, user_id, title, content)
+	tx, err := db.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to store post for user %d: %w", user_id, err)
+		return 0, fmt.Errorf("failed to start post transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	result, err := tx.Exec(query, user_id, title, content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store post for user %d: %w", user_id, err)
+	}
 	postID, _ := result.LastInsertId()
 
+	for _, categoryID := range categoryIDs {
+		if err := LinkPostCategory(tx, postID, categoryID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit post for user %d: %w", user_id, err)
+	}
 	return postID, nil
 }
 
-func StorePostCategory(db *sql.DB, post_id int64, category_id int) (int64, error) {
-	query := `INSERT INTO post_category (post_id, category_id) VALUES (?,?)`
-
-	result, err := db.Exec(query, post_id, category_id)
+// LinkPostCategory adds a post to a category inside tx. A link that
+// already exists is left as it is. The category is read in the same
+// statement, so one deleted after validation is reported as
+// ErrCategoryNotFound rather than as a failed insert; the caller should
+// roll back.
+func LinkPostCategory(tx *sql.Tx, postID int64, categoryID int) error {
+	result, err := tx.Exec(
+		`INSERT INTO post_category (post_id, category_id)
+		SELECT ?, id FROM categories WHERE id = ?
+		ON CONFLICT(post_id, category_id) DO NOTHING`,
+		postID, categoryID,
+	)
 	if err != nil {
-		return 0, fmt.Errorf("failed to link post %d with category %d: %w", post_id, category_id, err)
+		return fmt.Errorf("failed to link post %d with category %d: %w", postID, categoryID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		return nil
 	}
 
-	postcatID, _ := result.LastInsertId()
-
-	return postcatID, nil
+	// Nothing inserted: either the link exists or the category is gone
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check category %d: %w", categoryID, err)
+	}
+	if !exists {
+		return fmt.Errorf("category %d: %w", categoryID, ErrCategoryNotFound)
+	}
+	return nil
 }
 
 func StorePostReaction(db *sql.DB, user_id, post_id int, reaction string) (int64, error) {
@@ -527,31 +614,48 @@ func StorePostReaction(db *sql.DB, user_id, post_id int, reaction string) (int64
 	return preactionID, nil
 }
 
-func ReactToPost(db *sql.DB, user_id, post_id int, userReaction string) (int, int, error) {
-	var likeCount, dislikeCount int
-	var dbreaction string
-	var err error
-	db.QueryRow("SELECT reaction FROM post_reactions WHERE user_id=? AND post_id=?", user_id, post_id).Scan(&dbreaction)
-
-	if dbreaction == "" {
-		_, err = StorePostReaction(db, user_id, post_id, userReaction)
-	} else {
-		if userReaction == dbreaction {
-			query := "DELETE FROM post_reactions WHERE user_id = ? AND post_id = ?"
-			_, err = db.Exec(query, user_id, post_id)
-		} else {
-			query := "UPDATE post_reactions SET reaction = ? WHERE user_id = ? AND post_id = ?"
-			_, err = db.Exec(query, userReaction, user_id, post_id)
-		}
+// ReactToPost toggles a reaction on a published post. An unknown, draft or
+// deleted post gives ErrPostNotFound; content.SelfReactions decides how
+// the author's own reactions are treated (see toggleReaction).
+func ReactToPost(db *sql.DB, content config.ContentConfig, user_id, post_id int, userReaction string) (int, int, error) {
+	return toggleReaction(db, "post_reactions", "post_id", "posts", reactablePostSQL, ErrPostNotFound, content, user_id, post_id, userReaction, NotifyPostReaction)
+}
+
+// PostIsPublished reports whether postID is a published, non-deleted post
+func PostIsPublished(db *sql.DB, postID int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL)`
+	if err := db.QueryRow(query, postID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check post %d: %w", postID, err)
 	}
+	return exists, nil
+}
 
+// IsPostLocked reports whether postID is closed to new comments. A post
+// that is missing, deleted or still a draft gives ErrPostNotFound.
+func IsPostLocked(db *sql.DB, postID int) (bool, error) {
+	var locked bool
+	err := db.QueryRow("SELECT locked FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL", postID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("post %d: %w", postID, ErrPostNotFound)
+	}
 	if err != nil {
-		return 0, 0, err
+		return false, fmt.Errorf("failed to check post %d: %w", postID, err)
 	}
+	return locked, nil
+}
+
+// PublishPost turns one of the user's drafts into a published post
+func PublishPost(db *sql.DB, user_id, post_id int) error {
+	query := `UPDATE posts SET status = 'published', published_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND status = 'draft' AND deleted_at IS NULL`
 
-	// Fetch the new count of reactions for this post
-	db.QueryRow("SELECT COUNT(*) FROM post_reactions WHERE post_id=? AND reaction=?", post_id, "like").Scan(&likeCount)
-	db.QueryRow("SELECT COUNT(*) FROM post_reactions WHERE post_id=? AND reaction=?", post_id, "dislike").Scan(&dislikeCount)
+	result, err := db.Exec(query, post_id, user_id)
+	if err != nil {
+		return fmt.Errorf("failed to publish post %d: %w", post_id, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("draft %d of user %d: %w", post_id, user_id, ErrPostNotFound)
+	}
 
-	return likeCount, dislikeCount, nil
+	return nil
 }
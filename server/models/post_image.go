@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"forum/server/config"
+)
+
+// CanAttachImage reports whether user_id may attach images to post_id:
+// the post must exist, not be deleted, and belong to the user
+func CanAttachImage(db *sql.DB, user_id, post_id int) (bool, error) {
+	var ok bool
+	query := `SELECT EXISTS(SELECT 1 FROM posts WHERE id = ? AND user_id = ? AND deleted_at IS NULL)`
+	if err := db.QueryRow(query, post_id, user_id).Scan(&ok); err != nil {
+		return false, fmt.Errorf("failed to check post %d: %w", post_id, err)
+	}
+	return ok, nil
+}
+
+// StorePostImage records an uploaded image file for a post
+func StorePostImage(db *sql.DB, post_id int, path string) (int64, error) {
+	result, err := db.Exec(`INSERT INTO post_images (post_id, path) VALUES (?, ?)`, post_id, path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store image of post %d: %w", post_id, err)
+	}
+	return result.LastInsertId()
+}
+
+// FetchPostImages returns the public URLs of a post's images, oldest first
+func FetchPostImages(ctx context.Context, db *sql.DB, post_id int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT path FROM post_images WHERE post_id = ? ORDER BY id`, post_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images of post %d: %w", post_id, err)
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		urls = append(urls, config.UploadURL(path))
+	}
+	return urls, rows.Err()
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"forum/server/queries"
+	"forum/server/testutil"
+)
+
+func TestFetchPostCategoriesWithCommas(t *testing.T) {
+	db := testutil.NewDB(t)
+	result, err := db.Exec("INSERT INTO categories (label, slug) VALUES ('Food, Drink', 'food-drink')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	categoryID, _ := result.LastInsertId()
+	// Seeded post 2 is in Programming
+	if _, err := db.Exec("INSERT INTO post_category (post_id, category_id) VALUES (2, ?)", categoryID); err != nil {
+		t.Fatal(err)
+	}
+
+	post, _, err := FetchPost(context.Background(), db, 2, -1, queries.CommentSortNewest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Food, Drink", "Programming"}; !reflect.DeepEqual(post.Post.Categories, want) {
+		t.Errorf("categories = %q, want %q", post.Post.Categories, want)
+	}
+}
+
+func TestStorePostWithTheSameCategoryTwice(t *testing.T) {
+	db := testutil.NewDB(t)
+	postID, err := StorePost(db, 1, "Twice", "Same category twice", false, []int{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LinkPostCategory(tx, postID, 2); err != nil {
+		t.Fatalf("linking an existing category again: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var links int
+	db.QueryRow("SELECT COUNT(*) FROM post_category WHERE post_id = ?", postID).Scan(&links)
+	if links != 1 {
+		t.Errorf("%d links for the post, want 1", links)
+	}
+
+	// The unique index holds even for inserts that skip LinkPostCategory
+	_, err = db.Exec("INSERT INTO post_category (post_id, category_id) VALUES (?, 2)", postID)
+	if err == nil {
+		t.Fatal("inserted a duplicate post_category row")
+	}
+}
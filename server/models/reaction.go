@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"forum/server/config"
+)
+
+// ErrSelfReaction is returned when the self-reaction policy is forbid and
+// a user reacts to their own post or comment
+var ErrSelfReaction = errors.New("cannot react to your own content")
+
+// Queries telling whether a post or comment, by ID, can be reacted to
+const (
+	reactablePostSQL = `SELECT EXISTS(
+		SELECT 1 FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL)`
+	reactableCommentSQL = `SELECT EXISTS(
+		SELECT 1 FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND c.deleted_at IS NULL AND p.deleted_at IS NULL)`
+)
+
+// toggleReaction applies a like/dislike click for the post or comment
+// behind table/column and returns the new like and dislike counts.
+// Clicking the same reaction again removes it. When a reaction is added
+// or switched, notify is called after the commit to tell the owner;
+// its failure is logged and does not affect the result.
+//
+// Everything runs in one transaction that starts with a write, so SQLite
+// takes the write lock first and concurrent clicks by the same user are
+// applied one after the other. table and column are fixed by the callers.
+// exists must select whether the target can be reacted to; when it cannot,
+// nothing is changed and notFound is returned.
+//
+// parent is the table of the target itself, whose user_id is its author.
+// content.SelfReactions decides what happens when that author reacts:
+// forbid refuses a new reaction with ErrSelfReaction (removing an old one
+// is still allowed), and exclude leaves the author out of the counts.
+func toggleReaction(db *sql.DB, table, column, parent, exists string, notFound error, content config.ContentConfig, userID, targetID int, reaction string, notify func(*sql.DB, int, int, string) error) (int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error starting reaction transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ? AND "+column+" = ? AND reaction = ?", userID, targetID, reaction)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error removing reaction: %v", err)
+	}
+	removed, _ := result.RowsAffected()
+
+	// Checked after the first write so the transaction holds the write lock
+	var found bool
+	if err := tx.QueryRow(exists, targetID).Scan(&found); err != nil {
+		return 0, 0, fmt.Errorf("error checking reaction target: %v", err)
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("%d: %w", targetID, notFound)
+	}
+
+	if removed == 0 {
+		if content.SelfReactions == config.SelfReactionsForbid {
+			var authorID int
+			if err := tx.QueryRow("SELECT user_id FROM "+parent+" WHERE id = ?", targetID).Scan(&authorID); err != nil {
+				return 0, 0, fmt.Errorf("error checking reaction target author: %v", err)
+			}
+			if authorID == userID {
+				return 0, 0, ErrSelfReaction
+			}
+		}
+
+		// Not a toggle off: add the reaction or switch like <-> dislike
+		query := "INSERT INTO " + table + " (user_id, " + column + ", reaction) VALUES (?, ?, ?) ON CONFLICT(user_id, " + column + ") DO UPDATE SET reaction = ?"
+		if _, err := tx.Exec(query, userID, targetID, reaction, reaction); err != nil {
+			return 0, 0, fmt.Errorf("error storing reaction: %v", err)
+		}
+	}
+
+	var likeCount, dislikeCount int
+	notSelf := notSelfReaction(content.ExcludeSelfReactions(), "r", "t")
+	query := "SELECT COUNT(CASE WHEN r.reaction = 'like'" + notSelf + " THEN 1 END), COUNT(CASE WHEN r.reaction = 'dislike'" + notSelf + " THEN 1 END)" +
+		" FROM " + table + " r JOIN " + parent + " t ON t.id = r." + column + " WHERE r." + column + " = ?"
+	if err := tx.QueryRow(query, targetID).Scan(&likeCount, &dislikeCount); err != nil {
+		return 0, 0, fmt.Errorf("error fetching reaction counts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("error saving reaction: %v", err)
+	}
+	if removed == 0 {
+		logNotifyError(notify(db, userID, targetID, reaction))
+	}
+	return likeCount, dislikeCount, nil
+}
+
+// notSelfReaction returns an extra condition excluding reactions (alias r)
+// made by the author of the target (alias t), or "" when exclude is false
+func notSelfReaction(exclude bool, r, t string) string {
+	if !exclude {
+		return ""
+	}
+	return " AND " + r + ".user_id <> " + t + ".user_id"
+}
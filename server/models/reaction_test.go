@@ -0,0 +1,65 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func TestConcurrentReactionTogglesAreSerialized(t *testing.T) {
+	db := testutil.NewDB(t)
+	content := config.ContentConfig{SelfReactions: config.SelfReactionsAllow}
+	// Post 1 by alice has seeded likes from users 2, 3 and 4
+	const userID, postID, clicks = 5, 1, 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, clicks)
+	for i := 0; i < clicks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := ReactToPost(db, content, userID, postID, "like")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An even number of clicks on the same reaction toggles it off again
+	var reactions int
+	db.QueryRow("SELECT COUNT(*) FROM post_reactions WHERE user_id = ? AND post_id = ?", userID, postID).Scan(&reactions)
+	if reactions != 0 {
+		t.Errorf("user has %d reactions after %d clicks, want 0", reactions, clicks)
+	}
+
+	likes, dislikes, err := ReactToPost(db, content, userID, postID, "like")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if likes != 4 || dislikes != 0 {
+		t.Errorf("counts = %d likes, %d dislikes, want 4 and 0", likes, dislikes)
+	}
+}
+
+func TestReactionSwitchesBetweenLikeAndDislike(t *testing.T) {
+	db := testutil.NewDB(t)
+	content := config.ContentConfig{SelfReactions: config.SelfReactionsAllow}
+
+	if _, _, err := ReactToPost(db, content, 5, 1, "like"); err != nil {
+		t.Fatal(err)
+	}
+	likes, dislikes, err := ReactToPost(db, content, 5, 1, "dislike")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if likes != 3 || dislikes != 1 {
+		t.Errorf("counts = %d likes, %d dislikes, want 3 and 1", likes, dislikes)
+	}
+}
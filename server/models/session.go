@@ -2,15 +2,23 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
 )
 
-func StoreSession(db *sql.DB, user_id int, session_id string, expires_at time.Time) error {
-	query := `INSERT OR REPLACE INTO sessions (user_id,session_id,expires_at) VALUES (?,?,?)`
+// StoreSession saves the user's session, created at created_at, replacing
+// any previous one. remember marks a "remember me" session, which keeps its
+// expiry instead of sliding.
+func StoreSession(db *sql.DB, user_id int, session_id string, created_at, expires_at time.Time, remember bool) error {
+	query := `INSERT OR REPLACE INTO sessions (user_id,session_id,expires_at,created_at,remember) VALUES (?,?,?,?,?)`
 
-	_, err := db.Exec(query, user_id, session_id, expires_at)
+	_, err := db.Exec(query, user_id, session_id, expires_at, created_at, remember)
 	if err != nil {
 		return fmt.Errorf("%v", err)
 	}
@@ -18,14 +26,29 @@ func StoreSession(db *sql.DB, user_id int, session_id string, expires_at time.Ti
 	return nil
 }
 
-func ValidSession(r *http.Request, db *sql.DB) (int, string, bool) {
-	cookie, err := r.Cookie("session_id")
-	if err != nil || cookie == nil {
-		return -1, "", false
+// ErrNotAuthenticated is returned by ValidateSession for a request without
+// a live session: no cookie, an unknown session or an expired one
+var ErrNotAuthenticated = errors.New("not authenticated")
+
+// SessionUser is the user a request's session belongs to
+type SessionUser struct {
+	ID        int
+	Username  string
+	SessionID string
+	ExpiresAt time.Time
+}
+
+// ValidateSession is the one place a request's session is checked: it
+// reads the session cookie, looks the session up and rejects it once
+// expires_at has passed by c. Any other error is a database failure, which
+// callers should not mistake for a logged-out visitor.
+func ValidateSession(db *sql.DB, r *http.Request, c clock.Clock) (SessionUser, error) {
+	cookie, err := r.Cookie(config.SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return SessionUser{}, ErrNotAuthenticated
 	}
-	var expiration time.Time
-	var user_id int
-	var username string
+
+	user := SessionUser{SessionID: cookie.Value}
 	query := `
 		SELECT 
 			s.user_id,
@@ -35,11 +58,71 @@ func ValidSession(r *http.Request, db *sql.DB) (int, string, bool) {
 		INNER JOIN users u ON s.user_id = u.id 
 		WHERE session_id = ?
 	`
-	err = db.QueryRow(query, cookie.Value).Scan(&user_id, &expiration, &username)
-	if err != nil || expiration.Before(time.Now()) {
+	err = db.QueryRow(query, cookie.Value).Scan(&user.ID, &user.ExpiresAt, &user.Username)
+	if err == sql.ErrNoRows {
+		return SessionUser{}, ErrNotAuthenticated
+	}
+	if err != nil {
+		return SessionUser{}, fmt.Errorf("failed to load session: %w", err)
+	}
+	if !user.ExpiresAt.After(c.Now()) {
+		return SessionUser{}, ErrNotAuthenticated
+	}
+	return user, nil
+}
+
+// ValidSession is ValidateSession for pages that only need to know who is
+// browsing: a database failure is logged and treated as logged out.
+func ValidSession(r *http.Request, db *sql.DB, c clock.Clock) (int, string, bool) {
+	user, err := ValidateSession(db, r, c)
+	if err != nil {
+		if !errors.Is(err, ErrNotAuthenticated) {
+			log.Println("Error validating session:", err)
+		}
 		return -1, "", false
 	}
-	return user_id, username, true
+	return user.ID, user.Username, true
+}
+
+// RefreshSession implements sliding expiration for the session with the
+// given ID. Once less than half of the idle timeout is left, the expiry is
+// pushed back to a full idle timeout from now, but never past maxLifetime
+// after the session was created. c tells the time. It returns the new
+// expiry and whether the session was extended; expired, unknown and
+// "remember me" sessions are never extended.
+func RefreshSession(db *sql.DB, session_id string, idleTimeout, maxLifetime time.Duration, c clock.Clock) (time.Time, bool, error) {
+	var expiresAt time.Time
+	var createdAt sql.NullTime
+	var remember bool
+	err := db.QueryRow(`SELECT expires_at, created_at, remember FROM sessions WHERE session_id = ?`, session_id).Scan(&expiresAt, &createdAt, &remember)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	now := c.Now()
+	if remember || !expiresAt.After(now) || expiresAt.Sub(now) > idleTimeout/2 {
+		return expiresAt, false, nil
+	}
+
+	if !createdAt.Valid {
+		createdAt.Time = expiresAt.Add(-idleTimeout)
+	}
+	newExpiry := now.Add(idleTimeout)
+	if limit := createdAt.Time.Add(maxLifetime); newExpiry.After(limit) {
+		newExpiry = limit
+	}
+	if !newExpiry.After(expiresAt) {
+		return expiresAt, false, nil
+	}
+
+	_, err = db.Exec(`UPDATE sessions SET expires_at = ? WHERE session_id = ?`, newExpiry, session_id)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to extend session: %w", err)
+	}
+	return newExpiry, true, nil
 }
 
 func DeleteUserSession(db *sql.DB, userID int) error {
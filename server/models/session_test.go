@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func TestRefreshSession(t *testing.T) {
+	const idle = time.Hour
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		remember    bool
+		maxLifetime time.Duration
+		after       time.Duration // time passed since the session was created
+		wantExpiry  time.Duration // from start
+		wantMoved   bool
+	}{
+		{"most of the idle timeout left", false, 24 * time.Hour, 20 * time.Minute, idle, false},
+		{"less than half left", false, 24 * time.Hour, 40 * time.Minute, 40*time.Minute + idle, true},
+		{"capped by the lifetime", false, 80 * time.Minute, 40 * time.Minute, 80 * time.Minute, true},
+		{"at the expiry", false, 24 * time.Hour, idle, idle, false},
+		{"expired", false, 24 * time.Hour, 2 * time.Hour, idle, false},
+		{"remember me", true, 24 * time.Hour, 40 * time.Minute, idle, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			now := clock.NewFake(start)
+			if err := StoreSession(db, 1, "session", start, start.Add(idle), tt.remember); err != nil {
+				t.Fatal(err)
+			}
+
+			now.Advance(tt.after)
+			expiry, moved, err := RefreshSession(db, "session", idle, tt.maxLifetime, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if moved != tt.wantMoved || !expiry.Equal(start.Add(tt.wantExpiry)) {
+				t.Errorf("RefreshSession = %v, %v, want %v, %v", expiry, moved, start.Add(tt.wantExpiry), tt.wantMoved)
+			}
+		})
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	db := testutil.NewDB(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := clock.NewFake(start)
+	if err := StoreSession(db, 2, "bob-session", start, start.Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+	withCookie := func(value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if value != "" {
+			r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: value})
+		}
+		return r
+	}
+
+	user, err := ValidateSession(db, withCookie("bob-session"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 2 || user.Username != "bob" || user.SessionID != "bob-session" || !user.ExpiresAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("user = %+v, want bob's session", user)
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		advance time.Duration
+	}{
+		{"no cookie", withCookie(""), 0},
+		{"unknown session", withCookie("forged"), 0},
+		{"expired session", withCookie("bob-session"), time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now.Set(start.Add(tt.advance))
+			if _, err := ValidateSession(db, tt.request, now); !errors.Is(err, ErrNotAuthenticated) {
+				t.Errorf("err = %v, want ErrNotAuthenticated", err)
+			}
+		})
+	}
+}
+
+func TestValidateSessionReportsDatabaseErrors(t *testing.T) {
+	db := testutil.NewDB(t)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: "bob-session"})
+	db.Close()
+
+	_, err := ValidateSession(db, r, clock.Real)
+	if err == nil || errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("err = %v, want a database error rather than ErrNotAuthenticated", err)
+	}
+}
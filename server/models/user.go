@@ -7,10 +7,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// GetUserInfo returns the ID and password hash of the user with the given
+// username, ignoring case
 func GetUserInfo(db *sql.DB, username string) (int, string, error) {
 	var user_id int
 	var hashedPassword string
-	err := db.QueryRow("SELECT id,password FROM users WHERE username = ?", username).Scan(&user_id, &hashedPassword)
+	err := db.QueryRow("SELECT id,password FROM users WHERE LOWER(username) = LOWER(?)", username).Scan(&user_id, &hashedPassword)
 	if err != nil {
 		return 0, "", err
 	}
@@ -33,3 +35,41 @@ func StoreUser(db *sql.DB, email, username, password string) (int64, error) {
 
 	return userID, nil
 }
+
+// RegistrationConflicts reports whether email and username are already used
+// by an account, ignoring case like the unique indexes do
+func RegistrationConflicts(db *sql.DB, email, username string) (emailTaken, usernameTaken bool, err error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER(?)),
+		EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER(?))`
+	err = db.QueryRow(query, email, username).Scan(&emailTaken, &usernameTaken)
+	return emailTaken, usernameTaken, err
+}
+
+// GetUserRole returns the role ("user" or "admin") of the given user
+func GetUserRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// SetUserAvatar stores the file name of a user's new avatar and returns
+// the previous one ("" if there was none) so the caller can delete it
+func SetUserAvatar(db *sql.DB, userID int, path string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	if err := tx.QueryRow("SELECT avatar_path FROM users WHERE id = ?", userID).Scan(&previous); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("UPDATE users SET avatar_path = ? WHERE id = ?", path, userID); err != nil {
+		return "", err
+	}
+	return previous.String, tx.Commit()
+}
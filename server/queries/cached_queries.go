@@ -1,10 +1,16 @@
 package queries
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"forum/server/config"
+	"forum/server/metrics"
 )
 
 // CachedPostQueryService wraps PostQueryService with caching
@@ -15,9 +21,10 @@ type CachedPostQueryService struct {
 
 // QueryCache provides simple in-memory caching for queries
 type QueryCache struct {
-	mu    sync.RWMutex
-	items map[string]*cacheItem
-	ttl   time.Duration
+	mu         sync.RWMutex
+	items      map[string]*cacheItem
+	ttl        time.Duration
+	prefixTTLs map[string]time.Duration
 }
 
 type cacheItem struct {
@@ -25,66 +32,107 @@ type cacheItem struct {
 	expiresAt time.Time
 }
 
-// NewQueryCache creates a new query cache
-func NewQueryCache(ttl time.Duration) *QueryCache {
+// NewQueryCache creates a new query cache. Expired items are swept until
+// ctx is cancelled.
+func NewQueryCache(ctx context.Context, ttl time.Duration) *QueryCache {
 	cache := &QueryCache{
-		items: make(map[string]*cacheItem),
-		ttl:   ttl,
+		items:      make(map[string]*cacheItem),
+		ttl:        ttl,
+		prefixTTLs: make(map[string]time.Duration),
 	}
 
 	// Start cleanup goroutine
-	go cache.cleanup()
+	go cache.cleanup(ctx)
 
 	return cache
 }
 
-// Get retrieves an item from cache
+// Get retrieves an item from cache. Callers check the type of what comes
+// back and treat a mismatch as a miss, so a key that ends up holding some
+// other kind of value falls back to the database instead of panicking.
 func (c *QueryCache) Get(key string) (interface{}, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	item, exists := c.items[key]
-	if !exists {
+	if !exists || time.Now().After(item.expiresAt) {
+		metrics.CacheLookup(cacheGroup(key), false)
 		return nil, false
 	}
 
-	// Check if expired
-	if time.Now().After(item.expiresAt) {
-		return nil, false
+	metrics.CacheLookup(cacheGroup(key), true)
+	return item.data, true
+}
+
+// cacheGroup names the kind of entry a key holds for the metrics: the part
+// before the first underscore, e.g. "posts" or "count"
+func cacheGroup(key string) string {
+	if i := strings.IndexByte(key, '_'); i > 0 {
+		return key[:i]
 	}
+	return key
+}
 
-	return item.data, true
+// SetPrefixTTL makes keys starting with prefix expire after ttl instead of
+// the cache default. The longest matching prefix wins.
+func (c *QueryCache) SetPrefixTTL(prefix string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prefixTTLs[prefix] = ttl
 }
 
-// Set stores an item in cache
-func (c *QueryCache) Set(key string, data interface{}) {
+// Set stores an item in cache. An optional ttl overrides both the
+// prefix and the default TTL for this item.
+func (c *QueryCache) Set(key string, data interface{}, ttl ...time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	expiry := c.ttlFor(key)
+	if len(ttl) > 0 {
+		expiry = ttl[0]
+	}
+
 	c.items[key] = &cacheItem{
 		data:      data,
-		expiresAt: time.Now().Add(c.ttl),
+		expiresAt: time.Now().Add(expiry),
 	}
 }
 
+// ttlFor returns the TTL for key; callers must hold the lock
+func (c *QueryCache) ttlFor(key string) time.Duration {
+	ttl, matched := c.ttl, ""
+	for prefix, prefixTTL := range c.prefixTTLs {
+		if strings.HasPrefix(key, prefix) && len(prefix) > len(matched) {
+			ttl, matched = prefixTTL, prefix
+		}
+	}
+	return ttl
+}
+
 // Invalidate removes items with matching key prefix
 func (c *QueryCache) Invalidate(keyPrefix string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	for key := range c.items {
-		if len(keyPrefix) == 0 || key[:len(keyPrefix)] == keyPrefix {
+		if strings.HasPrefix(key, keyPrefix) {
 			delete(c.items, key)
 		}
 	}
 }
 
 // cleanup removes expired items periodically
-func (c *QueryCache) cleanup() {
+func (c *QueryCache) cleanup(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 		c.mu.Lock()
 		now := time.Now()
 		for key, item := range c.items {
@@ -96,46 +144,97 @@ func (c *QueryCache) cleanup() {
 	}
 }
 
-// NewCachedPostQueryService creates a cached query service
-func NewCachedPostQueryService(db *sql.DB, cacheTTL time.Duration) *CachedPostQueryService {
+// NewCachedPostQueryService creates a cached query service. Post data uses
+// cfg.PostTTL; categories change rarely and counts change on every post, so
+// they get their own TTLs. content is passed on to the query service.
+// The cache's background sweep stops when ctx is cancelled.
+func NewCachedPostQueryService(ctx context.Context, db *sql.DB, cfg config.CacheConfig, content config.ContentConfig) *CachedPostQueryService {
+	cache := NewQueryCache(ctx, cfg.PostTTL)
+	cache.SetPrefixTTL("categories_", cfg.CategoryTTL)
+	cache.SetPrefixTTL("count_", cfg.CountTTL)
+	cache.SetPrefixTTL("stats_", cfg.StatsTTL)
+
 	return &CachedPostQueryService{
-		queryService: NewPostQueryService(db),
-		cache:        NewQueryCache(cacheTTL),
+		queryService: NewPostQueryService(db, content),
+		cache:        cache,
 	}
 }
 
+// postList is a cached GetAllPosts result
+type postList struct {
+	posts     []PostListItem
+	truncated bool
+}
+
 // GetAllPosts with caching
-func (s *CachedPostQueryService) GetAllPosts(userID int) ([]PostListItem, error) {
+func (s *CachedPostQueryService) GetAllPosts(ctx context.Context, userID int) ([]PostListItem, bool, error) {
 	cacheKey := fmt.Sprintf("posts_all_user_%d", userID)
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.([]PostListItem), nil
+		if value, ok := cached.(postList); ok {
+			return value.posts, value.truncated, nil
+		}
 	}
 
 	// Query database
-	posts, err := s.queryService.GetAllPosts(userID)
+	posts, truncated, err := s.queryService.GetAllPosts(ctx, userID)
 	if err != nil {
-		return nil, err
+		countQueryError("GetAllPosts", err)
+		return nil, false, err
 	}
 
 	// Cache result
+	s.cache.Set(cacheKey, postList{posts: posts, truncated: truncated})
+	return posts, truncated, nil
+}
+
+// GetPostsUpTo is not cached: it serves rare, one-off reads such as
+// exports, whose limits would each need their own entry
+func (s *CachedPostQueryService) GetPostsUpTo(ctx context.Context, userID, limit int) ([]PostListItem, bool, error) {
+	posts, truncated, err := s.queryService.GetPostsUpTo(ctx, userID, limit)
+	if err != nil {
+		countQueryError("GetPostsUpTo", err)
+	}
+	return posts, truncated, err
+}
+
+// GetPostsPage with caching. The key starts with "posts_", so a new or
+// changed post drops every cached page.
+func (s *CachedPostQueryService) GetPostsPage(ctx context.Context, userID, limit, offset int) ([]PostListItem, error) {
+	cacheKey := fmt.Sprintf("posts_page_%d_%d_user_%d", limit, offset, userID)
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.([]PostListItem); ok {
+			return value, nil
+		}
+	}
+
+	posts, err := s.queryService.GetPostsPage(ctx, userID, limit, offset)
+	if err != nil {
+		countQueryError("GetPostsPage", err)
+		return nil, err
+	}
+
 	s.cache.Set(cacheKey, posts)
 	return posts, nil
 }
 
 // GetPostByID with caching
-func (s *CachedPostQueryService) GetPostByID(postID, userID int) (*PostDetail, error) {
-	cacheKey := fmt.Sprintf("post_%d_user_%d", postID, userID)
+func (s *CachedPostQueryService) GetPostByID(ctx context.Context, postID, userID int, sort CommentSort) (*PostDetail, error) {
+	cacheKey := fmt.Sprintf("post_%d_user_%d_sort_%s", postID, userID, sort)
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.(*PostDetail), nil
+		if value, ok := cached.(*PostDetail); ok {
+			return value, nil
+		}
 	}
 
 	// Query database
-	post, err := s.queryService.GetPostByID(postID, userID)
+	post, err := s.queryService.GetPostByID(ctx, postID, userID, sort)
 	if err != nil {
+		countQueryError("GetPostByID", err)
 		return nil, err
 	}
 
@@ -150,12 +249,15 @@ func (s *CachedPostQueryService) GetPostsByCategory(categoryID, userID int) ([]P
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.([]PostListItem), nil
+		if value, ok := cached.([]PostListItem); ok {
+			return value, nil
+		}
 	}
 
 	// Query database
 	posts, err := s.queryService.GetPostsByCategory(categoryID, userID)
 	if err != nil {
+		countQueryError("GetPostsByCategory", err)
 		return nil, err
 	}
 
@@ -164,18 +266,51 @@ func (s *CachedPostQueryService) GetPostsByCategory(categoryID, userID int) ([]P
 	return posts, nil
 }
 
+// GetPostsByCategorySlug with caching
+func (s *CachedPostQueryService) GetPostsByCategorySlug(slug string, userID int) ([]PostListItem, error) {
+	categoryID, err := s.GetCategoryIDBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPostsByCategory(categoryID, userID)
+}
+
+// GetCategoryIDBySlug with caching (under categories_, so it shares
+// CategoryTTL and is dropped by InvalidateCategoryCache)
+func (s *CachedPostQueryService) GetCategoryIDBySlug(slug string) (int, error) {
+	cacheKey := "categories_slug_" + slug
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.(int); ok {
+			return value, nil
+		}
+	}
+
+	categoryID, err := s.queryService.GetCategoryIDBySlug(slug)
+	if err != nil {
+		countQueryError("GetCategoryIDBySlug", err)
+		return 0, err
+	}
+
+	s.cache.Set(cacheKey, categoryID)
+	return categoryID, nil
+}
+
 // GetUserCreatedPosts with caching
 func (s *CachedPostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, error) {
 	cacheKey := fmt.Sprintf("posts_created_user_%d", userID)
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.([]PostListItem), nil
+		if value, ok := cached.([]PostListItem); ok {
+			return value, nil
+		}
 	}
 
 	// Query database
 	posts, err := s.queryService.GetUserCreatedPosts(userID)
 	if err != nil {
+		countQueryError("GetUserCreatedPosts", err)
 		return nil, err
 	}
 
@@ -190,12 +325,15 @@ func (s *CachedPostQueryService) GetUserLikedPosts(userID int) ([]PostListItem,
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.([]PostListItem), nil
+		if value, ok := cached.([]PostListItem); ok {
+			return value, nil
+		}
 	}
 
 	// Query database
 	posts, err := s.queryService.GetUserLikedPosts(userID)
 	if err != nil {
+		countQueryError("GetUserLikedPosts", err)
 		return nil, err
 	}
 
@@ -210,24 +348,171 @@ func (s *CachedPostQueryService) GetAllCategories() ([]CategorySummary, error) {
 
 	// Try cache first
 	if cached, found := s.cache.Get(cacheKey); found {
-		return cached.([]CategorySummary), nil
+		if value, ok := cached.([]CategorySummary); ok {
+			return value, nil
+		}
 	}
 
 	// Query database
 	categories, err := s.queryService.GetAllCategories()
 	if err != nil {
+		countQueryError("GetAllCategories", err)
+		return nil, err
+	}
+
+	// Cache result (categories change rarely, so they use CategoryTTL)
+	s.cache.Set(cacheKey, categories)
+	return categories, nil
+}
+
+// GetCategoriesPage with caching, per sort and page. Post counts change
+// with every post, so InvalidatePostCache drops these pages as well.
+func (s *CachedPostQueryService) GetCategoriesPage(ctx context.Context, sort CategorySort, limit, offset int) ([]CategorySummary, error) {
+	cacheKey := fmt.Sprintf("categories_page_%s_%d_%d", sort, limit, offset)
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.([]CategorySummary); ok {
+			return value, nil
+		}
+	}
+
+	categories, err := s.queryService.GetCategoriesPage(ctx, sort, limit, offset)
+	if err != nil {
+		countQueryError("GetCategoriesPage", err)
 		return nil, err
 	}
 
-	// Cache result (categories change rarely, so cache longer)
 	s.cache.Set(cacheKey, categories)
 	return categories, nil
 }
 
+// CountCategories with caching
+func (s *CachedPostQueryService) CountCategories(ctx context.Context) (int, error) {
+	cacheKey := "categories_count"
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.(int); ok {
+			return value, nil
+		}
+	}
+
+	count, err := s.queryService.CountCategories(ctx)
+	if err != nil {
+		countQueryError("CountCategories", err)
+		return 0, err
+	}
+
+	s.cache.Set(cacheKey, count)
+	return count, nil
+}
+
+// CountPosts with caching
+func (s *CachedPostQueryService) CountPosts(ctx context.Context) (int, error) {
+	cacheKey := "count_posts"
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.(int); ok {
+			return value, nil
+		}
+	}
+
+	count, err := s.queryService.CountPosts(ctx)
+	if err != nil {
+		countQueryError("CountPosts", err)
+		return 0, err
+	}
+
+	s.cache.Set(cacheKey, count)
+	return count, nil
+}
+
+// CountPostsByCategory with caching
+func (s *CachedPostQueryService) CountPostsByCategory(categoryID int) (int, error) {
+	cacheKey := fmt.Sprintf("count_posts_cat_%d", categoryID)
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.(int); ok {
+			return value, nil
+		}
+	}
+
+	count, err := s.queryService.CountPostsByCategory(categoryID)
+	if err != nil {
+		countQueryError("CountPostsByCategory", err)
+		return 0, err
+	}
+
+	s.cache.Set(cacheKey, count)
+	return count, nil
+}
+
+// GetCategoryEngagement with caching. The aggregate reads every post,
+// comment and reaction, so it is not invalidated by writes; it is at most
+// StatsTTL old.
+func (s *CachedPostQueryService) GetCategoryEngagement(ctx context.Context) ([]CategoryEngagement, error) {
+	cacheKey := "stats_category_engagement"
+
+	if cached, found := s.cache.Get(cacheKey); found {
+		if value, ok := cached.([]CategoryEngagement); ok {
+			return value, nil
+		}
+	}
+
+	categories, err := s.queryService.GetCategoryEngagement(ctx)
+	if err != nil {
+		countQueryError("GetCategoryEngagement", err)
+		return nil, err
+	}
+
+	s.cache.Set(cacheKey, categories)
+	return categories, nil
+}
+
+// GetAuditLog is not cached: admins reviewing moderation need to see an
+// action as soon as it is taken
+func (s *CachedPostQueryService) GetAuditLog(ctx context.Context, limit, offset int) ([]AuditLogEntry, error) {
+	entries, err := s.queryService.GetAuditLog(ctx, limit, offset)
+	if err != nil {
+		countQueryError("GetAuditLog", err)
+	}
+	return entries, err
+}
+
+// CountAuditLog is not cached, for the same reason as GetAuditLog
+func (s *CachedPostQueryService) CountAuditLog(ctx context.Context) (int, error) {
+	count, err := s.queryService.CountAuditLog(ctx)
+	if err != nil {
+		countQueryError("CountAuditLog", err)
+	}
+	return count, err
+}
+
+// Warm loads what a guest's first page view needs (the post list and count
+// for userID 0, and the categories) into the cache, so the first request
+// after a restart does not pay for the queries. Every part is tried; the
+// failures are returned together.
+func (s *CachedPostQueryService) Warm(ctx context.Context) error {
+	_, _, postsErr := s.GetAllPosts(ctx, 0)
+	_, categoriesErr := s.GetAllCategories()
+	_, countErr := s.CountPosts(ctx)
+	return errors.Join(postsErr, categoriesErr, countErr)
+}
+
 // InvalidatePostCache invalidates all post-related cache entries
 func (s *CachedPostQueryService) InvalidatePostCache() {
 	s.cache.Invalidate("posts_")
 	s.cache.Invalidate("post_")
+	s.cache.Invalidate("count_")
+	s.cache.Invalidate("categories_page_")
+}
+
+// InvalidateCategoryCache invalidates the category list and everything
+// that shows category labels or per-category counts
+func (s *CachedPostQueryService) InvalidateCategoryCache() {
+	s.cache.Invalidate("categories_")
+	s.cache.Invalidate("posts_")
+	s.cache.Invalidate("post_")
+	s.cache.Invalidate("count_posts_cat_")
 }
 
 // InvalidateUserCache invalidates user-specific cache entries
@@ -236,3 +521,33 @@ func (s *CachedPostQueryService) InvalidateUserCache(userID int) {
 	s.cache.Invalidate(fmt.Sprintf("posts_created_user_%d", userID))
 	s.cache.Invalidate(fmt.Sprintf("posts_liked_user_%d", userID))
 }
+
+// GetPostsByIDs is not cached: every caller asks for a different set of
+// posts, which the per-post invalidation could not keep track of
+func (s *CachedPostQueryService) GetPostsByIDs(ctx context.Context, ids []int, userID int) ([]PostListItem, error) {
+	posts, err := s.queryService.GetPostsByIDs(ctx, ids, userID)
+	if err != nil {
+		countQueryError("GetPostsByIDs", err)
+	}
+	return posts, err
+}
+
+// GetUnreadNotifications is not cached: marking one read has to show up
+// on the next page load
+func (s *CachedPostQueryService) GetUnreadNotifications(userID int) ([]NotificationItem, error) {
+	notifications, err := s.queryService.GetUnreadNotifications(userID)
+	if err != nil {
+		countQueryError("GetUnreadNotifications", err)
+	}
+	return notifications, err
+}
+
+// countQueryError records a failed query in the metrics. Lookups that
+// simply found nothing and requests the client abandoned are not database
+// errors.
+func countQueryError(query string, err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrPostNotFound) || errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrCategoryNotFound) {
+		return
+	}
+	metrics.DBError(query)
+}
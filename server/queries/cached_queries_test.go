@@ -0,0 +1,58 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func newTestCachedService(t *testing.T) *CachedPostQueryService {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewCachedPostQueryService(ctx, testutil.NewDB(t), config.LoadConfig().Cache, config.ContentConfig{})
+}
+
+func TestCachedQueriesIgnoreWrongTypedEntries(t *testing.T) {
+	s := newTestCachedService(t)
+	ctx := context.Background()
+
+	// Each key holds a value of some other type, as a clash between key
+	// schemes would leave behind
+	s.cache.Set("post_1_user_0_sort_"+string(CommentSortNewest), []PostListItem{})
+	s.cache.Set("posts_all_user_0", &PostDetail{})
+	s.cache.Set("posts_page_10_0_user_0", "not a list")
+
+	post, err := s.GetPostByID(ctx, 1, 0, CommentSortNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.ID != 1 || post.Title != "Welcome to the Forum!" {
+		t.Errorf("GetPostByID = %+v, want post 1 from the database", post)
+	}
+
+	posts, _, err := s.GetAllPosts(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 5 {
+		t.Errorf("GetAllPosts returned %d posts, want the 5 seeded ones", len(posts))
+	}
+
+	page, err := s.GetPostsPage(ctx, 0, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 5 {
+		t.Errorf("GetPostsPage returned %d posts, want 5", len(page))
+	}
+
+	// The database result replaced the bad entry
+	if cached, _ := s.cache.Get("posts_all_user_0"); cached == nil {
+		t.Error("GetAllPosts did not cache its result")
+	} else if _, ok := cached.(postList); !ok {
+		t.Errorf("posts_all_user_0 holds %T, want postList", cached)
+	}
+}
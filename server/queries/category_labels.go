@@ -0,0 +1,46 @@
+package queries
+
+import (
+	"sort"
+	"strings"
+
+	"forum/server/config"
+)
+
+// labelSeparator joins category labels inside SQL results. It is the
+// ASCII unit separator, which category labels may not contain (unlike a
+// comma), so splitting on it is always safe.
+const labelSeparator = "\x1f"
+
+// CategoryLabelsSQL returns a scalar subquery yielding the category labels
+// of the post aliased postAlias, joined by labelSeparator, or NULL when it
+// has none. A subquery keeps the labels out of the outer GROUP BY, where
+// joined reaction and comment rows would repeat them, and SQLite does not
+// allow a separator with GROUP_CONCAT(DISTINCT ...).
+func CategoryLabelsSQL(postAlias string) string {
+	return `(
+			SELECT ` + config.DialectSQLite.GroupConcat("label_cat.label", labelSeparator) + `
+			FROM post_category label_pc
+			INNER JOIN categories label_cat ON label_cat.id = label_pc.category_id
+			WHERE label_pc.post_id = ` + postAlias + `.id
+		)`
+}
+
+// SplitCategoryLabels turns a CategoryLabelsSQL value into labels sorted
+// alphabetically (case-insensitively), so posts always list their
+// categories in the same order. An empty value gives an empty slice.
+func SplitCategoryLabels(joined string) []string {
+	if joined == "" {
+		return []string{}
+	}
+	labels := strings.Split(joined, labelSeparator)
+	sortLabels(labels)
+	return labels
+}
+
+// sortLabels sorts category labels alphabetically, ignoring case
+func sortLabels(labels []string) {
+	sort.SliceStable(labels, func(i, j int) bool {
+		return strings.ToLower(labels[i]) < strings.ToLower(labels[j])
+	})
+}
@@ -0,0 +1,47 @@
+package queries
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func TestSplitCategoryLabels(t *testing.T) {
+	joined := "science" + labelSeparator + "Food, Drink" + labelSeparator + "Art"
+	want := []string{"Art", "Food, Drink", "science"}
+	if got := SplitCategoryLabels(joined); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitCategoryLabels = %q, want %q", got, want)
+	}
+	if got := SplitCategoryLabels(""); len(got) != 0 {
+		t.Errorf("SplitCategoryLabels(\"\") = %q, want none", got)
+	}
+}
+
+func TestPostCategoriesWithCommas(t *testing.T) {
+	db := testutil.NewDB(t)
+	result := mustExec(t, db, "INSERT INTO categories (label, slug) VALUES ('Food, Drink', 'food-drink')")
+	categoryID, _ := result.LastInsertId()
+	// Seeded post 2 is in Programming
+	mustExec(t, db, "INSERT INTO post_category (post_id, category_id) VALUES (2, ?)", categoryID)
+	want := []string{"Food, Drink", "Programming"}
+
+	service := NewPostQueryService(db, config.ContentConfig{})
+	post, err := service.GetPostByID(context.Background(), 2, 0, CommentSortNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(post.Categories, want) {
+		t.Errorf("post categories = %q, want %q", post.Categories, want)
+	}
+
+	posts, err := service.GetPostsByIDs(context.Background(), []int{2}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || !reflect.DeepEqual(posts[0].Categories, want) {
+		t.Errorf("listed categories = %+v, want %q", posts, want)
+	}
+}
@@ -0,0 +1,44 @@
+package queries
+
+import "fmt"
+
+// CategorySort selects the order of a category listing
+type CategorySort string
+
+const (
+	CategorySortName  CategorySort = "name"
+	CategorySortPosts CategorySort = "posts" // most posts first
+)
+
+// DefaultCategorySort is used when no order is requested
+const DefaultCategorySort = CategorySortName
+
+// categoryOrderBy is the whitelist of ORDER BY clauses. Only these strings
+// ever reach the SQL; they expect the categories table to be aliased as c
+// and the count to be selected as post_count.
+var categoryOrderBy = map[CategorySort]string{
+	CategorySortName:  "c.label ASC, c.id ASC",
+	CategorySortPosts: "post_count DESC, c.label ASC, c.id ASC",
+}
+
+// ParseCategorySort validates a sort name from user input. An empty value
+// yields fallback; anything not in the whitelist is an error.
+func ParseCategorySort(value string, fallback CategorySort) (CategorySort, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	sort := CategorySort(value)
+	if _, ok := categoryOrderBy[sort]; !ok {
+		return "", fmt.Errorf("invalid category sort %q (expected name or posts)", value)
+	}
+	return sort, nil
+}
+
+// OrderBy returns the ORDER BY clause for the sort, falling back to
+// DefaultCategorySort for unknown values
+func (s CategorySort) OrderBy() string {
+	if clause, ok := categoryOrderBy[s]; ok {
+		return clause
+	}
+	return categoryOrderBy[DefaultCategorySort]
+}
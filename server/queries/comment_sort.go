@@ -0,0 +1,49 @@
+package queries
+
+import "fmt"
+
+// CommentSort selects the order in which a post's comments are listed
+type CommentSort string
+
+const (
+	CommentSortOldest CommentSort = "oldest"
+	CommentSortNewest CommentSort = "newest"
+	CommentSortTop    CommentSort = "top" // net score: likes minus dislikes
+)
+
+// DefaultCommentSort is used when no order is requested
+const DefaultCommentSort = CommentSortOldest
+
+// commentOrderBy is the whitelist of ORDER BY clauses. Only these strings
+// ever reach the SQL; they expect the comments table to be aliased as c.
+var commentOrderBy = map[CommentSort]string{
+	CommentSortOldest: "c.created_at ASC, c.id ASC",
+	CommentSortNewest: "c.created_at DESC, c.id DESC",
+	CommentSortTop: `(
+			SELECT COALESCE(SUM(CASE WHEN r.reaction = 'like' THEN 1 WHEN r.reaction = 'dislike' THEN -1 ELSE 0 END), 0)
+			FROM comment_reactions r
+			WHERE r.comment_id = c.id
+		) DESC, c.created_at ASC, c.id ASC`,
+}
+
+// ParseCommentSort validates a sort name from user input. An empty value
+// yields fallback; anything not in the whitelist is an error.
+func ParseCommentSort(value string, fallback CommentSort) (CommentSort, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	sort := CommentSort(value)
+	if _, ok := commentOrderBy[sort]; !ok {
+		return "", fmt.Errorf("invalid comment sort %q (expected oldest, newest or top)", value)
+	}
+	return sort, nil
+}
+
+// OrderBy returns the ORDER BY clause for the sort, falling back to
+// DefaultCommentSort for unknown values
+func (s CommentSort) OrderBy() string {
+	if clause, ok := commentOrderBy[s]; ok {
+		return clause
+	}
+	return commentOrderBy[DefaultCommentSort]
+}
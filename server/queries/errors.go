@@ -0,0 +1,12 @@
+package queries
+
+import "errors"
+
+// Sentinel errors for lookups that can legitimately miss. Callers should
+// check them with errors.Is to tell a 404 apart from a database failure.
+var (
+	ErrPostNotFound     = errors.New("post not found")
+	ErrCommentNotFound  = errors.New("comment not found")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrCategoryNotFound = errors.New("category not found")
+)
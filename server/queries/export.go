@@ -0,0 +1,165 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/server/config"
+)
+
+// ExportUserData gathers the profile, posts, comments and reactions of
+// userID. It returns ErrUserNotFound when the user does not exist.
+func (s *PostQueryService) ExportUserData(ctx context.Context, userID int) (*UserExport, error) {
+	export := UserExport{
+		ExportedAt: time.Now().UTC(),
+		Posts:      []ExportPost{},
+		Comments:   []ExportComment{},
+		Reactions:  []ExportReaction{},
+	}
+
+	var avatarPath sql.NullString
+	profile := &export.Profile
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, username, email, role, avatar_path, created_at FROM users WHERE id = ?",
+		userID,
+	).Scan(&profile.ID, &profile.Username, &profile.Email, &profile.Role, &avatarPath, &profile.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	profile.AvatarURL = config.AvatarURL(profile.ID, avatarPath.String)
+
+	if err := s.exportPosts(ctx, userID, &export); err != nil {
+		return nil, err
+	}
+	if err := s.exportComments(ctx, userID, &export); err != nil {
+		return nil, err
+	}
+	if err := s.exportReactions(ctx, userID, &export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// exportPosts adds every post of userID, with categories and images
+func (s *PostQueryService) exportPosts(ctx context.Context, userID int, export *UserExport) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			p.id,
+			p.title,
+			p.content,
+			p.status,
+			COALESCE(`+CategoryLabelsSQL("p")+`, '') as categories,
+			p.created_at,
+			p.updated_at,
+			p.published_at,
+			p.deleted_at
+		FROM posts p
+		WHERE p.user_id = ?
+		ORDER BY p.created_at, p.id
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var post ExportPost
+		var categories string
+		var updatedAt, publishedAt, deletedAt sql.NullTime
+		err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Status, &categories,
+			&post.CreatedAt, &updatedAt, &publishedAt, &deletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan post: %w", err)
+		}
+		post.Categories = SplitCategoryLabels(categories)
+		post.UpdatedAt = timePtr(updatedAt)
+		post.PublishedAt = timePtr(publishedAt)
+		post.DeletedAt = timePtr(deletedAt)
+		export.Posts = append(export.Posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read posts: %w", err)
+	}
+	rows.Close()
+
+	for i := range export.Posts {
+		images, err := s.getPostImages(ctx, export.Posts[i].ID)
+		if err != nil {
+			return fmt.Errorf("failed to get images: %w", err)
+		}
+		export.Posts[i].Images = images
+	}
+	return nil
+}
+
+// exportComments adds every comment of userID
+func (s *PostQueryService) exportComments(ctx context.Context, userID int, export *UserExport) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, post_id, content, created_at, updated_at, deleted_at
+		FROM comments
+		WHERE user_id = ?
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment ExportComment
+		var updatedAt, deletedAt sql.NullTime
+		err := rows.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.CreatedAt, &updatedAt, &deletedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comment.UpdatedAt = timePtr(updatedAt)
+		comment.DeletedAt = timePtr(deletedAt)
+		export.Comments = append(export.Comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read comments: %w", err)
+	}
+	return nil
+}
+
+// exportReactions adds the likes and dislikes userID gave to posts and
+// comments
+func (s *PostQueryService) exportReactions(ctx context.Context, userID int, export *UserExport) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT 'post', post_id, reaction, created_at FROM post_reactions WHERE user_id = ?
+		UNION ALL
+		SELECT 'comment', comment_id, reaction, created_at FROM comment_reactions WHERE user_id = ?
+		ORDER BY 4, 1, 2
+	`, userID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to query reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reaction ExportReaction
+		var createdAt sql.NullTime
+		if err := rows.Scan(&reaction.Target, &reaction.TargetID, &reaction.Reaction, &createdAt); err != nil {
+			return fmt.Errorf("failed to scan reaction: %w", err)
+		}
+		reaction.CreatedAt = createdAt.Time
+		export.Reactions = append(export.Reactions, reaction)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read reactions: %w", err)
+	}
+	return nil
+}
+
+// timePtr returns nil for NULL timestamps, so they are left out of JSON
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
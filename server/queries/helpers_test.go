@@ -0,0 +1,16 @@
+package queries
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// mustExec runs a statement the test depends on
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) sql.Result {
+	t.Helper()
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -1,6 +1,22 @@
 package queries
 
-import "time"
+import (
+	"database/sql"
+	"time"
+)
+
+// editGracePeriod is how long after creation a change still counts as part
+// of writing rather than as an edit
+const editGracePeriod = time.Minute
+
+// editedAt returns when a post or comment last changed and whether that was
+// an edit. Rows from before updated_at existed count as unchanged.
+func editedAt(createdAt time.Time, updatedAt sql.NullTime) (time.Time, bool) {
+	if !updatedAt.Valid {
+		return createdAt, false
+	}
+	return updatedAt.Time, updatedAt.Time.Sub(createdAt) > editGracePeriod
+}
 
 // PostListItem represents a post in list view (homepage, category page)
 type PostListItem struct {
@@ -9,13 +25,19 @@ type PostListItem struct {
 	ContentPreview  string    `json:"content_preview"` // First 200 chars
 	AuthorID        int       `json:"author_id"`
 	AuthorUsername  string    `json:"author_username"`
+	AuthorAvatarURL string    `json:"author_avatar_url"`
 	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"` // last title or content change
+	IsEdited        bool      `json:"is_edited"`  // changed after editGracePeriod
 	CommentCount    int       `json:"comment_count"`
 	LikeCount       int       `json:"like_count"`
 	DislikeCount    int       `json:"dislike_count"`
+	Score           int       `json:"score"` // LikeCount - DislikeCount
 	Categories      []string  `json:"categories"`
 	UserHasLiked    bool      `json:"user_has_liked"`
 	UserHasDisliked bool      `json:"user_has_disliked"`
+	Status          string    `json:"status"` // "draft" or "published"
+	Pinned          bool      `json:"pinned"` // listed ahead of the other posts
 }
 
 // PostDetail represents full post details for post view page
@@ -25,12 +47,20 @@ type PostDetail struct {
 	Content         string    `json:"content"`
 	AuthorID        int       `json:"author_id"`
 	AuthorUsername  string    `json:"author_username"`
+	AuthorAvatarURL string    `json:"author_avatar_url"`
 	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"` // last title or content change
+	IsEdited        bool      `json:"is_edited"`  // changed after editGracePeriod
 	Categories      []string  `json:"categories"`
 	LikeCount       int       `json:"like_count"`
 	DislikeCount    int       `json:"dislike_count"`
+	Score           int       `json:"score"` // LikeCount - DislikeCount
 	UserHasLiked    bool      `json:"user_has_liked"`
 	UserHasDisliked bool      `json:"user_has_disliked"`
+	Status          string    `json:"status"`
+	Locked          bool      `json:"locked"` // closed to new comments
+	Pinned          bool      `json:"pinned"` // listed ahead of the other posts
+	Images          []string  `json:"images"` // public URLs of attached images
 	Comments        []CommentDetail `json:"comments"`
 }
 
@@ -41,11 +71,31 @@ type CommentDetail struct {
 	Content         string    `json:"content"`
 	AuthorID        int       `json:"author_id"`
 	AuthorUsername  string    `json:"author_username"`
+	AuthorAvatarURL string    `json:"author_avatar_url"`
 	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"` // last title or content change
+	IsEdited        bool      `json:"is_edited"`  // changed after editGracePeriod
 	LikeCount       int       `json:"like_count"`
 	DislikeCount    int       `json:"dislike_count"`
+	Score           int       `json:"score"` // LikeCount - DislikeCount
 	UserHasLiked    bool      `json:"user_has_liked"`
 	UserHasDisliked bool      `json:"user_has_disliked"`
+	// Author is only filled in on request, see AddCommentAuthors
+	Author *CommentAuthor `json:"author,omitempty"`
+}
+
+// CommentAuthor is what readers see of a commenter besides their name
+type CommentAuthor struct {
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+	PostCount int       `json:"post_count"` // published, not deleted
+}
+
+// CommentPage is one page of a post's comments. HasMore tells whether a
+// further page exists, which is all a "load more" button needs to know.
+type CommentPage struct {
+	Comments []CommentDetail `json:"comments"`
+	HasMore  bool            `json:"has_more"`
 }
 
 // UserPostsSummary for "My Posts" page
@@ -56,9 +106,142 @@ type UserPostsSummary struct {
 	RecentPosts     []PostListItem `json:"recent_posts"`
 }
 
+// UserProfile for the public profile page of any user
+type UserProfile struct {
+	ID          int              `json:"id"`
+	Username    string           `json:"username"`
+	AvatarURL   string           `json:"avatar_url"`
+	JoinedAt    time.Time        `json:"joined_at"`
+	PostCount   int              `json:"post_count"`
+	Summary     UserPostsSummary `json:"summary"`
+	RecentPosts []PostListItem   `json:"recent_posts"`
+}
+
+// PageMeta describes where a page of results sits in the full listing
+type PageMeta struct {
+	TotalItems int `json:"total_items"`
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalPages int `json:"total_pages"`
+}
+
+// NewPageMeta builds the metadata for page (1-based) of a listing with
+// totalItems entries split into pages of pageSize.
+func NewPageMeta(totalItems, page, pageSize int) PageMeta {
+	meta := PageMeta{TotalItems: totalItems, Page: page, PageSize: pageSize}
+	if pageSize > 0 {
+		meta.TotalPages = (totalItems + pageSize - 1) / pageSize
+	}
+	if meta.TotalPages == 0 {
+		meta.TotalPages = 1
+	}
+	return meta
+}
+
+// Reactor is a user who liked or disliked a post
+type Reactor struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	ReactedAt time.Time `json:"reacted_at"`
+}
+
 // CategorySummary for category listing
 type CategorySummary struct {
 	ID        int    `json:"id"`
 	Label     string `json:"label"`
+	Slug      string `json:"slug"`
 	PostCount int    `json:"post_count"`
 }
+
+// CategoryEngagement is one category's activity for the admin stats.
+// Counts cover published, undeleted posts and their undeleted comments;
+// reactions are those on both.
+type CategoryEngagement struct {
+	ID            int    `json:"id"`
+	Label         string `json:"label"`
+	Slug          string `json:"slug"`
+	PostCount     int    `json:"post_count"`
+	CommentCount  int    `json:"comment_count"`
+	LikeCount     int    `json:"like_count"`
+	DislikeCount  int    `json:"dislike_count"`
+	ReactionCount int    `json:"reaction_count"` // LikeCount + DislikeCount
+	Score         int    `json:"score"`          // see engagementScore
+}
+
+// AuditLogEntry is one privileged action from the audit log
+type AuditLogEntry struct {
+	ID            int       `json:"id"`
+	ActorID       int       `json:"actor_id"`       // 0 once the actor's account is deleted
+	ActorUsername string    `json:"actor_username"` // "" once the actor's account is deleted
+	Action        string    `json:"action"`         // e.g. post.delete, category.rename
+	TargetType    string    `json:"target_type"`    // post, comment or category
+	TargetID      int       `json:"target_id"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NotificationItem is one entry on the notifications page
+type NotificationItem struct {
+	ID            int       `json:"id"`
+	Type          string    `json:"type"` // comment, post_like, post_dislike, comment_like or comment_dislike
+	ActorID       int       `json:"actor_id"`
+	ActorUsername string    `json:"actor_username"`
+	PostID        int       `json:"post_id"`
+	PostTitle     string    `json:"post_title"`
+	CommentID     int       `json:"comment_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// UserExport is everything stored about one user, for data portability
+// requests. It deliberately has no room for the password hash or session
+// IDs. Deleted posts and comments are included, since they are still kept.
+type UserExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Profile    ExportProfile    `json:"profile"`
+	Posts      []ExportPost     `json:"posts"`
+	Comments   []ExportComment  `json:"comments"`
+	Reactions  []ExportReaction `json:"reactions"`
+}
+
+// ExportProfile is the account part of a UserExport
+type ExportProfile struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportPost is one of the user's posts, drafts and deleted posts included
+type ExportPost struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Status      string     `json:"status"`
+	Categories  []string   `json:"categories"`
+	Images      []string   `json:"images"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportComment is one of the user's comments
+type ExportComment struct {
+	ID        int        `json:"id"`
+	PostID    int        `json:"post_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportReaction is one like or dislike the user gave
+type ExportReaction struct {
+	Target    string    `json:"target"` // "post" or "comment"
+	TargetID  int       `json:"target_id"`
+	Reaction  string    `json:"reaction"`
+	CreatedAt time.Time `json:"created_at"`
+}
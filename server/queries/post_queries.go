@@ -1,98 +1,391 @@
 package queries
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+
+	"forum/server/config"
 )
 
 // PostQueryService handles all read operations for posts
 type PostQueryService struct {
-	db *sql.DB
+	db          *sql.DB
+	excludeSelf bool // leave authors' reactions to their own content out of counts
+	listLimit   int  // most posts GetAllPosts returns; 0 means no limit
+}
+
+// NewPostQueryService creates a new query service. content decides whether
+// self-reactions are counted and how many posts GetAllPosts returns.
+func NewPostQueryService(db *sql.DB, content config.ContentConfig) *PostQueryService {
+	return &PostQueryService{db: db, excludeSelf: content.ExcludeSelfReactions(), listLimit: content.HomepagePostLimit}
 }
 
-// NewPostQueryService creates a new query service
-func NewPostQueryService(db *sql.DB) *PostQueryService {
-	return &PostQueryService{db: db}
+// notSelf returns an extra condition excluding reactions (alias r) made by
+// the author of the target (alias t), or "" when self-reactions count
+func (s *PostQueryService) notSelf(r, t string) string {
+	if !s.excludeSelf {
+		return ""
+	}
+	return " AND " + r + ".user_id <> " + t + ".user_id"
 }
 
-// GetAllPosts retrieves all posts with aggregated data (homepage)
-func (s *PostQueryService) GetAllPosts(userID int) ([]PostListItem, error) {
+// GetAllPosts retrieves the published posts with aggregated data
+// (homepage), pinned ones first and then newest first. It returns at most
+// content.HomepagePostLimit posts, so a growing table is never loaded
+// whole; truncated reports whether older posts were left out. The posts
+// come from one lean query; their counts, categories and the viewer's
+// reactions are added by batched queries (see addListAggregates) rather
+// than by joining comments, reactions and categories all at once.
+func (s *PostQueryService) GetAllPosts(ctx context.Context, userID int) (posts []PostListItem, truncated bool, err error) {
+	return s.GetPostsUpTo(ctx, userID, s.listLimit)
+}
+
+// GetPostsUpTo is GetAllPosts with the caller's own limit, for those such
+// as admin exports that must see past the homepage limit. A limit of 0 or
+// less returns every post.
+func (s *PostQueryService) GetPostsUpTo(ctx context.Context, userID, limit int) (posts []PostListItem, truncated bool, err error) {
 	query := `
-		SELECT 
+		SELECT` + listPostColumns + `
+		FROM posts p
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE p.status = 'published'
+		AND p.deleted_at IS NULL
+		ORDER BY p.pinned DESC, p.pin_order, p.created_at DESC, p.id DESC
+	`
+	if limit <= 0 {
+		posts, err = s.listPosts(ctx, query, userID)
+		return posts, false, err
+	}
+
+	// One post past the limit tells whether any were left out
+	posts, err = s.listPosts(ctx, query+"LIMIT ?", userID, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(posts) > limit {
+		return posts[:limit], true, nil
+	}
+	return posts, false, nil
+}
+
+// GetPostsPage returns limit published posts, pinned ones first and then
+// newest first, starting at offset, with the same aggregates as
+// GetAllPosts. Past the last post it returns an empty slice.
+func (s *PostQueryService) GetPostsPage(ctx context.Context, userID, limit, offset int) ([]PostListItem, error) {
+	query := `
+		SELECT` + listPostColumns + `
+		FROM posts p
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE p.status = 'published'
+		AND p.deleted_at IS NULL
+		ORDER BY p.pinned DESC, p.pin_order, p.created_at DESC, p.id DESC
+		LIMIT ? OFFSET ?
+	`
+	posts, err := s.listPosts(ctx, query, userID, limit, offset)
+	if posts == nil && err == nil {
+		posts = []PostListItem{}
+	}
+	return posts, err
+}
+
+// listPosts runs a query selecting listPostColumns and adds the list
+// aggregates for userID to its rows
+func (s *PostQueryService) listPosts(ctx context.Context, query string, userID int, args ...interface{}) ([]PostListItem, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []PostListItem
+	for rows.Next() {
+		post, err := scanListPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read posts: %w", err)
+	}
+	rows.Close()
+
+	if err := s.addListAggregates(ctx, posts, userID); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// listPostColumns are the columns scanListPost reads, from posts p joined
+// with users u; counts and categories come from addListAggregates
+const listPostColumns = `
 			p.id,
 			p.title,
 			SUBSTR(p.content, 1, 200) as content_preview,
 			p.user_id,
 			u.username,
+			u.avatar_path,
 			p.created_at,
-			COUNT(DISTINCT c.id) as comment_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'like' THEN pr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike' THEN pr.user_id END) as dislike_count,
-			GROUP_CONCAT(DISTINCT cat.label) as categories,
-			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'like' THEN 1 ELSE 0 END) as user_has_liked,
-			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked
+			p.updated_at,
+			p.status,
+			p.pinned`
+
+// scanListPost reads a row of listPostColumns
+func scanListPost(rows *sql.Rows) (PostListItem, error) {
+	var post PostListItem
+	var avatarPath sql.NullString
+	var updatedAt sql.NullTime
+	var contentPreview sql.NullString
+
+	err := rows.Scan(
+		&post.ID,
+		&post.Title,
+		&contentPreview,
+		&post.AuthorID,
+		&post.AuthorUsername,
+		&avatarPath,
+		&post.CreatedAt,
+		&updatedAt,
+		&post.Status,
+		&post.Pinned,
+	)
+	if err != nil {
+		return post, fmt.Errorf("failed to scan post: %w", err)
+	}
+	post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+	post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
+
+	if contentPreview.Valid {
+		post.ContentPreview = contentPreview.String
+		if len(post.ContentPreview) == 200 {
+			post.ContentPreview += "..."
+		}
+	}
+	return post, nil
+}
+
+// GetPostsByIDs loads a set of posts in the order of ids, for lists put
+// together elsewhere (bookmarks, notifications, related posts). Repeated
+// IDs are loaded once, at their first position. Posts that do not exist,
+// are deleted or are someone else's draft are left out, so the result can
+// be shorter than ids. Reaction flags are those of userID.
+func (s *PostQueryService) GetPostsByIDs(ctx context.Context, ids []int, userID int) ([]PostListItem, error) {
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	found := make(map[int]PostListItem, len(unique))
+	for start := 0; start < len(unique); start += maxBatchIDs {
+		batch := unique[start:min(start+maxBatchIDs, len(unique))]
+		args := make([]interface{}, 0, len(batch)+1)
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		args = append(args, userID)
+
+		query := `
+		SELECT` + listPostColumns + `
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
-		LEFT JOIN post_reactions pr ON p.id = pr.post_id
-		LEFT JOIN post_category pc ON p.id = pc.post_id
-		LEFT JOIN categories cat ON pc.category_id = cat.id
-		GROUP BY p.id
-		ORDER BY p.created_at DESC
+		WHERE p.id IN (` + placeholders(len(batch)) + `)
+		AND p.deleted_at IS NULL
+		AND (p.status = 'published' OR p.user_id = ?)
 	`
+		if err := s.collectListPosts(ctx, found, query, args); err != nil {
+			return nil, err
+		}
+	}
+
+	posts := make([]PostListItem, 0, len(found))
+	for _, id := range unique {
+		if post, ok := found[id]; ok {
+			posts = append(posts, post)
+		}
+	}
 
-	rows, err := s.db.Query(query, userID, userID)
+	if err := s.addListAggregates(ctx, posts, userID); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// collectListPosts runs a query selecting listPostColumns and adds the
+// posts to found by ID
+func (s *PostQueryService) collectListPosts(ctx context.Context, found map[int]PostListItem, query string, args []interface{}) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query posts: %w", err)
+		return fmt.Errorf("failed to query posts: %w", err)
 	}
 	defer rows.Close()
 
-	var posts []PostListItem
 	for rows.Next() {
-		var post PostListItem
-		var categoriesStr sql.NullString
-		var contentPreview sql.NullString
-
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&contentPreview,
-			&post.AuthorID,
-			&post.AuthorUsername,
-			&post.CreatedAt,
-			&post.CommentCount,
-			&post.LikeCount,
-			&post.DislikeCount,
-			&categoriesStr,
-			&post.UserHasLiked,
-			&post.UserHasDisliked,
-		)
+		post, err := scanListPost(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan post: %w", err)
+			return err
 		}
+		found[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read posts: %w", err)
+	}
+	return nil
+}
 
-		if contentPreview.Valid {
-			post.ContentPreview = contentPreview.String
-			if len(post.ContentPreview) == 200 {
-				post.ContentPreview += "..."
-			}
+// maxBatchIDs keeps IN (...) lists well below SQLite's limit on bound
+// parameters
+const maxBatchIDs = 500
+
+// addListAggregates fills in the comment and reaction counts, score,
+// categories (sorted like SplitCategoryLabels) and the viewer's reactions of posts, with one
+// query per aggregate for every maxBatchIDs posts
+func (s *PostQueryService) addListAggregates(ctx context.Context, posts []PostListItem, userID int) error {
+	byID := make(map[int]*PostListItem, len(posts))
+	for i := range posts {
+		posts[i].Categories = []string{}
+		byID[posts[i].ID] = &posts[i]
+	}
+
+	for start := 0; start < len(posts); start += maxBatchIDs {
+		batch := posts[start:min(start+maxBatchIDs, len(posts))]
+		ids := make([]interface{}, len(batch))
+		for i, post := range batch {
+			ids[i] = post.ID
 		}
 
-		if categoriesStr.Valid && categoriesStr.String != "" {
-			post.Categories = strings.Split(categoriesStr.String, ",")
-		} else {
-			post.Categories = []string{}
+		if err := s.addReactionCounts(ctx, byID, ids, userID); err != nil {
+			return err
+		}
+		if err := s.addCommentCounts(ctx, byID, ids); err != nil {
+			return err
 		}
+		if err := s.addCategoryLabels(ctx, byID, ids); err != nil {
+			return err
+		}
+	}
 
-		posts = append(posts, post)
+	for i := range posts {
+		posts[i].Score = posts[i].LikeCount - posts[i].DislikeCount
 	}
+	return nil
+}
 
-	return posts, nil
+func (s *PostQueryService) addReactionCounts(ctx context.Context, byID map[int]*PostListItem, ids []interface{}, userID int) error {
+	query := `
+		SELECT
+			pr.post_id,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END),
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END),
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'like' THEN 1 ELSE 0 END),
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END)
+		FROM post_reactions pr
+		INNER JOIN posts p ON p.id = pr.post_id
+		WHERE pr.post_id IN (` + placeholders(len(ids)) + `)
+		GROUP BY pr.post_id
+	`
+	args := append([]interface{}{userID, userID}, ids...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query post reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var likes, dislikes int
+		var liked, disliked bool
+		if err := rows.Scan(&postID, &likes, &dislikes, &liked, &disliked); err != nil {
+			return fmt.Errorf("failed to scan post reactions: %w", err)
+		}
+		if post, ok := byID[postID]; ok {
+			post.LikeCount, post.DislikeCount = likes, dislikes
+			post.UserHasLiked, post.UserHasDisliked = liked, disliked
+		}
+	}
+	return rows.Err()
+}
+
+func (s *PostQueryService) addCommentCounts(ctx context.Context, byID map[int]*PostListItem, ids []interface{}) error {
+	query := `
+		SELECT post_id, COUNT(*)
+		FROM comments
+		WHERE post_id IN (` + placeholders(len(ids)) + `)
+		AND deleted_at IS NULL
+		GROUP BY post_id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to query comment counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, count int
+		if err := rows.Scan(&postID, &count); err != nil {
+			return fmt.Errorf("failed to scan comment count: %w", err)
+		}
+		if post, ok := byID[postID]; ok {
+			post.CommentCount = count
+		}
+	}
+	return rows.Err()
 }
 
-// GetPostByID retrieves full post details with comments
-func (s *PostQueryService) GetPostByID(postID, userID int) (*PostDetail, error) {
+func (s *PostQueryService) addCategoryLabels(ctx context.Context, byID map[int]*PostListItem, ids []interface{}) error {
+	query := `
+		SELECT pc.post_id, cat.label
+		FROM post_category pc
+		INNER JOIN categories cat ON cat.id = pc.category_id
+		WHERE pc.post_id IN (` + placeholders(len(ids)) + `)
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to query post categories: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var label string
+		if err := rows.Scan(&postID, &label); err != nil {
+			return fmt.Errorf("failed to scan post category: %w", err)
+		}
+		if post, ok := byID[postID]; ok {
+			post.Categories = append(post.Categories, label)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		sortLabels(byID[id.(int)].Categories)
+	}
+	return nil
+}
+
+// placeholders returns n comma separated bind parameters for an IN list
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// GetPostByID retrieves full post details with comments listed in the
+// given order. It returns ErrPostNotFound when the post does not exist or
+// is hidden.
+func (s *PostQueryService) GetPostByID(ctx context.Context, postID, userID int, sort CommentSort) (*PostDetail, error) {
 	// Get post details
 	query := `
 		SELECT 
@@ -101,52 +394,69 @@ func (s *PostQueryService) GetPostByID(postID, userID int) (*PostDetail, error)
 			p.content,
 			p.user_id,
 			u.username,
+			u.avatar_path,
 			p.created_at,
-			GROUP_CONCAT(DISTINCT cat.label) as categories,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'like' THEN pr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike' THEN pr.user_id END) as dislike_count,
+			p.updated_at,
+			` + CategoryLabelsSQL("p") + ` as categories,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as dislike_count,
 			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'like' THEN 1 ELSE 0 END) as user_has_liked,
-			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked,
+			p.status,
+			p.locked,
+			p.pinned
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
 		LEFT JOIN post_reactions pr ON p.id = pr.post_id
-		LEFT JOIN post_category pc ON p.id = pc.post_id
-		LEFT JOIN categories cat ON pc.category_id = cat.id
 		WHERE p.id = ?
-		GROUP BY p.id
+		AND p.deleted_at IS NULL
+		AND (p.status = 'published' OR p.user_id = ?)
+		GROUP BY p.id, p.title, p.content, p.user_id, u.username, u.avatar_path, p.created_at, p.updated_at, p.status, p.locked, p.pinned
 	`
 
 	var post PostDetail
 	var categoriesStr sql.NullString
+	var avatarPath sql.NullString
+	var updatedAt sql.NullTime
 
-	err := s.db.QueryRow(query, userID, userID, postID).Scan(
+	err := s.db.QueryRowContext(ctx, query, userID, userID, postID, userID).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
 		&post.AuthorID,
 		&post.AuthorUsername,
+		&avatarPath,
 		&post.CreatedAt,
+		&updatedAt,
 		&categoriesStr,
 		&post.LikeCount,
 		&post.DislikeCount,
 		&post.UserHasLiked,
 		&post.UserHasDisliked,
+		&post.Status,
+		&post.Locked,
+		&post.Pinned,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("post not found")
+			return nil, ErrPostNotFound
 		}
 		return nil, fmt.Errorf("failed to query post: %w", err)
 	}
+	post.Score = post.LikeCount - post.DislikeCount
+	post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+	post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
 
-	if categoriesStr.Valid && categoriesStr.String != "" {
-		post.Categories = strings.Split(categoriesStr.String, ",")
-	} else {
-		post.Categories = []string{}
+	post.Categories = SplitCategoryLabels(categoriesStr.String)
+
+	images, err := s.getPostImages(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images: %w", err)
 	}
+	post.Images = images
 
 	// Get comments
-	comments, err := s.getCommentsByPostID(postID, userID)
+	comments, err := s.getCommentsByPostID(ctx, postID, userID, sort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get comments: %w", err)
 	}
@@ -155,58 +465,312 @@ func (s *PostQueryService) GetPostByID(postID, userID int) (*PostDetail, error)
 	return &post, nil
 }
 
-// getCommentsByPostID retrieves all comments for a post
-func (s *PostQueryService) getCommentsByPostID(postID, userID int) ([]CommentDetail, error) {
-	query := `
+// getPostImages retrieves the URLs of a post's images, oldest first
+func (s *PostQueryService) getPostImages(ctx context.Context, postID int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT path FROM post_images WHERE post_id = ? ORDER BY id", postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		images = append(images, config.UploadURL(path))
+	}
+	return images, rows.Err()
+}
+
+// commentDetailQuery selects CommentDetail rows matching where, which
+// takes its arguments after the two viewer IDs
+func (s *PostQueryService) commentDetailQuery(where string) string {
+	return `
 		SELECT 
 			c.id,
 			c.post_id,
 			c.content,
 			c.user_id,
 			u.username,
+			u.avatar_path,
 			c.created_at,
-			COUNT(DISTINCT CASE WHEN cr.reaction = 'like' THEN cr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN cr.reaction = 'dislike' THEN cr.user_id END) as dislike_count,
+			c.updated_at,
+			COUNT(DISTINCT CASE WHEN cr.reaction = 'like'` + s.notSelf("cr", "c") + ` THEN cr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN cr.reaction = 'dislike'` + s.notSelf("cr", "c") + ` THEN cr.user_id END) as dislike_count,
 			MAX(CASE WHEN cr.user_id = ? AND cr.reaction = 'like' THEN 1 ELSE 0 END) as user_has_liked,
 			MAX(CASE WHEN cr.user_id = ? AND cr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked
 		FROM comments c
 		LEFT JOIN users u ON c.user_id = u.id
 		LEFT JOIN comment_reactions cr ON c.id = cr.comment_id
-		WHERE c.post_id = ?
-		GROUP BY c.id
-		ORDER BY c.created_at ASC
-	`
+		WHERE ` + where + `
+		AND c.deleted_at IS NULL
+		GROUP BY c.id, c.post_id, c.content, c.user_id, u.username, u.avatar_path, c.created_at, c.updated_at`
+}
+
+// scanComment reads one row of commentDetailQuery
+func scanComment(row interface{ Scan(...interface{}) error }) (CommentDetail, error) {
+	var comment CommentDetail
+	var avatarPath sql.NullString
+	var updatedAt sql.NullTime
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.Content,
+		&comment.AuthorID,
+		&comment.AuthorUsername,
+		&avatarPath,
+		&comment.CreatedAt,
+		&updatedAt,
+		&comment.LikeCount,
+		&comment.DislikeCount,
+		&comment.UserHasLiked,
+		&comment.UserHasDisliked,
+	)
+	if err != nil {
+		return comment, err
+	}
+	comment.Score = comment.LikeCount - comment.DislikeCount
+	comment.AuthorAvatarURL = config.AvatarURL(comment.AuthorID, avatarPath.String)
+	comment.UpdatedAt, comment.IsEdited = editedAt(comment.CreatedAt, updatedAt)
+	return comment, nil
+}
+
+// getCommentsByPostID retrieves all comments for a post
+func (s *PostQueryService) getCommentsByPostID(ctx context.Context, postID, userID int, sort CommentSort) ([]CommentDetail, error) {
+	query := s.commentDetailQuery("c.post_id = ?") + `
+		ORDER BY ` + sort.OrderBy()
+
+	return s.queryComments(ctx, query, userID, userID, postID)
+}
+
+// GetPostComments returns one page of a post's comments in the given
+// order. It asks for one comment past limit to learn whether another page
+// follows, so no count query is needed. It returns ErrPostNotFound when the
+// post does not exist or userID may not see it.
+func (s *PostQueryService) GetPostComments(ctx context.Context, postID, userID int, sort CommentSort, limit, offset int) (*CommentPage, error) {
+	var visible bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM posts
+			WHERE id = ? AND deleted_at IS NULL AND (status = 'published' OR user_id = ?)
+		)`, postID, userID,
+	).Scan(&visible)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query post: %w", err)
+	}
+	if !visible {
+		return nil, ErrPostNotFound
+	}
+
+	query := s.commentDetailQuery("c.post_id = ?") + `
+		ORDER BY ` + sort.OrderBy() + `
+		LIMIT ? OFFSET ?`
 
-	rows, err := s.db.Query(query, userID, userID, postID)
+	comments, err := s.queryComments(ctx, query, userID, userID, postID, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &CommentPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments, page.HasMore = comments[:limit], true
+	}
+	return page, nil
+}
+
+// queryComments runs a commentDetailQuery and collects its rows
+func (s *PostQueryService) queryComments(ctx context.Context, query string, args ...interface{}) ([]CommentDetail, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query comments: %w", err)
 	}
 	defer rows.Close()
 
-	var comments []CommentDetail
+	comments := []CommentDetail{}
 	for rows.Next() {
-		var comment CommentDetail
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.Content,
-			&comment.AuthorID,
-			&comment.AuthorUsername,
-			&comment.CreatedAt,
-			&comment.LikeCount,
-			&comment.DislikeCount,
-			&comment.UserHasLiked,
-			&comment.UserHasDisliked,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan comment: %w", err)
 		}
 		comments = append(comments, comment)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read comments: %w", err)
+	}
 
 	return comments, nil
 }
 
+// AddCommentAuthors fills in the Author of each comment, looking up all
+// the distinct commenters in one query per batch. Callers that only need
+// the names can skip it.
+func (s *PostQueryService) AddCommentAuthors(ctx context.Context, comments []CommentDetail) error {
+	authors := make(map[int]*CommentAuthor)
+	var ids []interface{}
+	for _, comment := range comments {
+		if _, ok := authors[comment.AuthorID]; !ok {
+			authors[comment.AuthorID] = nil
+			ids = append(ids, comment.AuthorID)
+		}
+	}
+
+	for start := 0; start < len(ids); start += maxBatchIDs {
+		batch := ids[start:min(start+maxBatchIDs, len(ids))]
+		query := `
+			SELECT
+				u.id,
+				u.role,
+				u.created_at,
+				(SELECT COUNT(*) FROM posts p
+				 WHERE p.user_id = u.id AND p.status = 'published' AND p.deleted_at IS NULL)
+			FROM users u
+			WHERE u.id IN (` + placeholders(len(batch)) + `)
+		`
+		if err := s.collectCommentAuthors(ctx, authors, query, batch); err != nil {
+			return err
+		}
+	}
+
+	for i := range comments {
+		comments[i].Author = authors[comments[i].AuthorID]
+	}
+	return nil
+}
+
+func (s *PostQueryService) collectCommentAuthors(ctx context.Context, authors map[int]*CommentAuthor, query string, ids []interface{}) error {
+	rows, err := s.db.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to query comment authors: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var author CommentAuthor
+		var joinedAt sql.NullTime
+		if err := rows.Scan(&id, &author.Role, &joinedAt, &author.PostCount); err != nil {
+			return fmt.Errorf("failed to scan comment author: %w", err)
+		}
+		author.JoinedAt = joinedAt.Time
+		authors[id] = &author
+	}
+	return rows.Err()
+}
+
+// GetCommentByID retrieves one comment as userID sees it. It returns
+// ErrCommentNotFound when the comment does not exist or is deleted.
+func (s *PostQueryService) GetCommentByID(ctx context.Context, commentID, userID int) (*CommentDetail, error) {
+	row := s.db.QueryRowContext(ctx, s.commentDetailQuery("c.id = ?"), userID, userID, commentID)
+	comment, err := scanComment(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to query comment: %w", err)
+	}
+	return &comment, nil
+}
+
+// GetPostReactors returns one page of the users who gave a published post
+// the given reaction ("like" or "dislike"), most recent first, and how
+// many there are in total. Self-reactions are left out when the counts
+// leave them out. It returns ErrPostNotFound for unknown or hidden posts.
+func (s *PostQueryService) GetPostReactors(ctx context.Context, postID int, reaction string, limit, offset int) ([]Reactor, int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(pr.user_id)
+		FROM posts p
+		LEFT JOIN post_reactions pr ON pr.post_id = p.id AND pr.reaction = ?`+s.notSelf("pr", "p")+`
+		WHERE p.id = ?
+		AND p.status = 'published'
+		AND p.deleted_at IS NULL
+		GROUP BY p.id
+	`, reaction, postID).Scan(&total)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrPostNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to count reactors: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT
+			u.id,
+			u.username,
+			u.avatar_path,
+			pr.created_at
+		FROM post_reactions pr
+		JOIN posts p ON p.id = pr.post_id
+		JOIN users u ON u.id = pr.user_id
+		WHERE pr.post_id = ?
+		AND pr.reaction = ?`+s.notSelf("pr", "p")+`
+		ORDER BY pr.created_at DESC, pr.user_id
+		LIMIT ? OFFSET ?
+	`, postID, reaction, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query reactors: %w", err)
+	}
+	defer rows.Close()
+
+	reactors := []Reactor{}
+	for rows.Next() {
+		var reactor Reactor
+		var avatarPath sql.NullString
+		if err := rows.Scan(&reactor.UserID, &reactor.Username, &avatarPath, &reactor.ReactedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan reactor: %w", err)
+		}
+		reactor.AvatarURL = config.AvatarURL(reactor.UserID, avatarPath.String)
+		reactors = append(reactors, reactor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read reactors: %w", err)
+	}
+	return reactors, total, nil
+}
+
+// GetPostAuthorID returns the author of a published post, or
+// ErrPostNotFound for unknown or hidden posts
+func (s *PostQueryService) GetPostAuthorID(ctx context.Context, postID int) (int, error) {
+	var authorID int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id FROM posts WHERE id = ? AND status = 'published' AND deleted_at IS NULL",
+		postID,
+	).Scan(&authorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrPostNotFound
+		}
+		return 0, fmt.Errorf("failed to query post author: %w", err)
+	}
+	return authorID, nil
+}
+
+// GetPostsByCategorySlug retrieves posts of the category with the given
+// slug, as used by /c/{slug}. It returns ErrCategoryNotFound for unknown
+// slugs.
+func (s *PostQueryService) GetPostsByCategorySlug(slug string, userID int) ([]PostListItem, error) {
+	categoryID, err := s.GetCategoryIDBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPostsByCategory(categoryID, userID)
+}
+
+// GetCategoryIDBySlug resolves a category slug to its ID
+func (s *PostQueryService) GetCategoryIDBySlug(slug string) (int, error) {
+	var categoryID int
+	err := s.db.QueryRow("SELECT id FROM categories WHERE slug = ?", slug).Scan(&categoryID)
+	if err == sql.ErrNoRows {
+		return 0, ErrCategoryNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up category slug: %w", err)
+	}
+	return categoryID, nil
+}
+
 // GetPostsByCategory retrieves posts filtered by category
 func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostListItem, error) {
 	query := `
@@ -216,24 +780,28 @@ func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostLis
 			SUBSTR(p.content, 1, 200) as content_preview,
 			p.user_id,
 			u.username,
+			u.avatar_path,
 			p.created_at,
+			p.updated_at,
 			COUNT(DISTINCT c.id) as comment_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'like' THEN pr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike' THEN pr.user_id END) as dislike_count,
-			GROUP_CONCAT(DISTINCT cat.label) as categories,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as dislike_count,
+			` + CategoryLabelsSQL("p") + ` as categories,
 			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'like' THEN 1 ELSE 0 END) as user_has_liked,
-			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked,
+			p.status,
+			p.pinned
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
+		LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL
 		LEFT JOIN post_reactions pr ON p.id = pr.post_id
-		LEFT JOIN post_category pc ON p.id = pc.post_id
-		LEFT JOIN categories cat ON pc.category_id = cat.id
 		WHERE p.id IN (
 			SELECT post_id FROM post_category WHERE category_id = ?
 		)
-		GROUP BY p.id
-		ORDER BY p.created_at DESC
+		AND p.status = 'published'
+		AND p.deleted_at IS NULL
+		GROUP BY p.id, p.title, p.content, p.user_id, u.username, u.avatar_path, p.created_at, p.updated_at, p.status, p.pinned, p.pin_order
+		ORDER BY p.pinned DESC, p.pin_order, p.created_at DESC
 	`
 
 	rows, err := s.db.Query(query, userID, userID, categoryID)
@@ -246,6 +814,8 @@ func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostLis
 	for rows.Next() {
 		var post PostListItem
 		var categoriesStr sql.NullString
+		var avatarPath sql.NullString
+		var updatedAt sql.NullTime
 		var contentPreview sql.NullString
 
 		err := rows.Scan(
@@ -254,17 +824,24 @@ func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostLis
 			&contentPreview,
 			&post.AuthorID,
 			&post.AuthorUsername,
+			&avatarPath,
 			&post.CreatedAt,
+			&updatedAt,
 			&post.CommentCount,
 			&post.LikeCount,
 			&post.DislikeCount,
 			&categoriesStr,
 			&post.UserHasLiked,
 			&post.UserHasDisliked,
+			&post.Status,
+			&post.Pinned,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
+		post.Score = post.LikeCount - post.DislikeCount
+		post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+		post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
 
 		if contentPreview.Valid {
 			post.ContentPreview = contentPreview.String
@@ -273,11 +850,7 @@ func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostLis
 			}
 		}
 
-		if categoriesStr.Valid && categoriesStr.String != "" {
-			post.Categories = strings.Split(categoriesStr.String, ",")
-		} else {
-			post.Categories = []string{}
-		}
+		post.Categories = SplitCategoryLabels(categoriesStr.String)
 
 		posts = append(posts, post)
 	}
@@ -285,7 +858,7 @@ func (s *PostQueryService) GetPostsByCategory(categoryID, userID int) ([]PostLis
 	return posts, nil
 }
 
-// GetUserCreatedPosts retrieves posts created by a user
+// GetUserCreatedPosts retrieves posts created by a user, drafts included
 func (s *PostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, error) {
 	query := `
 		SELECT 
@@ -294,21 +867,23 @@ func (s *PostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, erro
 			SUBSTR(p.content, 1, 200) as content_preview,
 			p.user_id,
 			u.username,
+			u.avatar_path,
 			p.created_at,
+			p.updated_at,
 			COUNT(DISTINCT c.id) as comment_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'like' THEN pr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike' THEN pr.user_id END) as dislike_count,
-			GROUP_CONCAT(DISTINCT cat.label) as categories,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as dislike_count,
+			` + CategoryLabelsSQL("p") + ` as categories,
 			1 as user_has_liked,
-			0 as user_has_disliked
+			0 as user_has_disliked,
+			p.status
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
+		LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL
 		LEFT JOIN post_reactions pr ON p.id = pr.post_id
-		LEFT JOIN post_category pc ON p.id = pc.post_id
-		LEFT JOIN categories cat ON pc.category_id = cat.id
 		WHERE p.user_id = ?
-		GROUP BY p.id
+		AND p.deleted_at IS NULL
+		GROUP BY p.id, p.title, p.content, p.user_id, u.username, u.avatar_path, p.created_at, p.updated_at, p.status
 		ORDER BY p.created_at DESC
 	`
 
@@ -322,6 +897,8 @@ func (s *PostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, erro
 	for rows.Next() {
 		var post PostListItem
 		var categoriesStr sql.NullString
+		var avatarPath sql.NullString
+		var updatedAt sql.NullTime
 		var contentPreview sql.NullString
 
 		err := rows.Scan(
@@ -330,17 +907,23 @@ func (s *PostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, erro
 			&contentPreview,
 			&post.AuthorID,
 			&post.AuthorUsername,
+			&avatarPath,
 			&post.CreatedAt,
+			&updatedAt,
 			&post.CommentCount,
 			&post.LikeCount,
 			&post.DislikeCount,
 			&categoriesStr,
 			&post.UserHasLiked,
 			&post.UserHasDisliked,
+			&post.Status,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
+		post.Score = post.LikeCount - post.DislikeCount
+		post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+		post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
 
 		if contentPreview.Valid {
 			post.ContentPreview = contentPreview.String
@@ -349,18 +932,206 @@ func (s *PostQueryService) GetUserCreatedPosts(userID int) ([]PostListItem, erro
 			}
 		}
 
-		if categoriesStr.Valid && categoriesStr.String != "" {
-			post.Categories = strings.Split(categoriesStr.String, ",")
-		} else {
-			post.Categories = []string{}
+		post.Categories = SplitCategoryLabels(categoriesStr.String)
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+// GetPostsByUser retrieves posts written by authorID, with reaction flags
+// computed for viewerID (who may be anyone, including a guest). Drafts are
+// included only when the viewer is the author.
+func (s *PostQueryService) GetPostsByUser(authorID, viewerID int) ([]PostListItem, error) {
+	query := `
+		SELECT 
+			p.id,
+			p.title,
+			SUBSTR(p.content, 1, 200) as content_preview,
+			p.user_id,
+			u.username,
+			u.avatar_path,
+			p.created_at,
+			p.updated_at,
+			COUNT(DISTINCT c.id) as comment_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as dislike_count,
+			` + CategoryLabelsSQL("p") + ` as categories,
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'like' THEN 1 ELSE 0 END) as user_has_liked,
+			MAX(CASE WHEN pr.user_id = ? AND pr.reaction = 'dislike' THEN 1 ELSE 0 END) as user_has_disliked,
+			p.status
+		FROM posts p
+		LEFT JOIN users u ON p.user_id = u.id
+		LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL
+		LEFT JOIN post_reactions pr ON p.id = pr.post_id
+		WHERE p.user_id = ?
+		AND p.deleted_at IS NULL
+		AND (p.status = 'published' OR p.user_id = ?)
+		GROUP BY p.id, p.title, p.content, p.user_id, u.username, u.avatar_path, p.created_at, p.updated_at, p.status
+		ORDER BY p.created_at DESC
+	`
+
+	rows, err := s.db.Query(query, viewerID, viewerID, authorID, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts by user: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []PostListItem
+	for rows.Next() {
+		var post PostListItem
+		var categoriesStr sql.NullString
+		var avatarPath sql.NullString
+		var updatedAt sql.NullTime
+		var contentPreview sql.NullString
+
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&contentPreview,
+			&post.AuthorID,
+			&post.AuthorUsername,
+			&avatarPath,
+			&post.CreatedAt,
+			&updatedAt,
+			&post.CommentCount,
+			&post.LikeCount,
+			&post.DislikeCount,
+			&categoriesStr,
+			&post.UserHasLiked,
+			&post.UserHasDisliked,
+			&post.Status,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		post.Score = post.LikeCount - post.DislikeCount
+		post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+		post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
+
+		if contentPreview.Valid {
+			post.ContentPreview = contentPreview.String
+			if len(post.ContentPreview) == 200 {
+				post.ContentPreview += "..."
+			}
 		}
 
+		post.Categories = SplitCategoryLabels(categoriesStr.String)
+
 		posts = append(posts, post)
 	}
 
 	return posts, nil
 }
 
+// GetUserSummary aggregates a user's activity: posts written, comments
+// written, likes received across their posts, and up to recentLimit of their
+// most recent posts (none are loaded when recentLimit <= 0).
+// A user with no activity gets zeros and an empty (non-nil) RecentPosts.
+func (s *PostQueryService) GetUserSummary(userID int, recentLimit int) (*UserPostsSummary, error) {
+	summary, err := s.getUserTotals(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.RecentPosts = []PostListItem{}
+	if summary.TotalPosts == 0 || recentLimit <= 0 {
+		return summary, nil
+	}
+
+	posts, err := s.GetPostsByUser(userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) > recentLimit {
+		posts = posts[:recentLimit]
+	}
+	if posts != nil {
+		summary.RecentPosts = posts
+	}
+
+	return summary, nil
+}
+
+// getUserTotals fills the counters of a UserPostsSummary in a single query
+func (s *PostQueryService) getUserTotals(userID int) (*UserPostsSummary, error) {
+	query := `
+		SELECT 
+			(SELECT COUNT(*) FROM posts WHERE user_id = ? AND status = 'published' AND deleted_at IS NULL) as total_posts,
+			(
+				SELECT COUNT(*)
+				FROM comments c
+				INNER JOIN posts p ON c.post_id = p.id
+				WHERE c.user_id = ? AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+			) as total_comments,
+			(
+				SELECT COUNT(*)
+				FROM post_reactions pr
+				INNER JOIN posts p ON pr.post_id = p.id
+				WHERE p.user_id = ? AND pr.reaction = 'like'` + s.notSelf("pr", "p") + ` AND p.deleted_at IS NULL
+			) as total_likes
+	`
+
+	var summary UserPostsSummary
+	err := s.db.QueryRow(query, userID, userID, userID).Scan(
+		&summary.TotalPosts,
+		&summary.TotalComments,
+		&summary.TotalLikes,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user summary: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// GetUserProfile retrieves the public profile of authorID as seen by viewerID.
+// It returns ErrUserNotFound when the user does not exist.
+func (s *PostQueryService) GetUserProfile(authorID, viewerID int, recentLimit int) (*UserProfile, error) {
+	var profile UserProfile
+	var avatarPath string
+	err := s.db.QueryRow(`
+		SELECT 
+			u.id,
+			u.username,
+			u.created_at,
+			COALESCE(u.avatar_path, '')
+		FROM users u
+		WHERE u.id = ?
+	`, authorID).Scan(&profile.ID, &profile.Username, &profile.JoinedAt, &avatarPath)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	profile.AvatarURL = config.AvatarURL(profile.ID, avatarPath)
+
+	// Totals only; the recent posts below carry the viewer's reaction flags
+	summary, err := s.getUserTotals(authorID)
+	if err != nil {
+		return nil, err
+	}
+	profile.PostCount = summary.TotalPosts
+	profile.Summary = *summary
+
+	posts, err := s.GetPostsByUser(authorID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if recentLimit > 0 && len(posts) > recentLimit {
+		posts = posts[:recentLimit]
+	}
+	if posts == nil {
+		posts = []PostListItem{}
+	}
+	profile.RecentPosts = posts
+	profile.Summary.RecentPosts = posts
+
+	return &profile, nil
+}
+
 // GetUserLikedPosts retrieves posts liked by a user
 func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error) {
 	query := `
@@ -370,23 +1141,26 @@ func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error)
 			SUBSTR(p.content, 1, 200) as content_preview,
 			p.user_id,
 			u.username,
+			u.avatar_path,
 			p.created_at,
+			p.updated_at,
 			COUNT(DISTINCT c.id) as comment_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'like' THEN pr.user_id END) as like_count,
-			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike' THEN pr.user_id END) as dislike_count,
-			GROUP_CONCAT(DISTINCT cat.label) as categories,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'like'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as like_count,
+			COUNT(DISTINCT CASE WHEN pr.reaction = 'dislike'` + s.notSelf("pr", "p") + ` THEN pr.user_id END) as dislike_count,
+			` + CategoryLabelsSQL("p") + ` as categories,
 			1 as user_has_liked,
-			0 as user_has_disliked
+			0 as user_has_disliked,
+			p.status
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
-		LEFT JOIN comments c ON p.id = c.post_id
+		LEFT JOIN comments c ON p.id = c.post_id AND c.deleted_at IS NULL
 		LEFT JOIN post_reactions pr ON p.id = pr.post_id
-		LEFT JOIN post_category pc ON p.id = pc.post_id
-		LEFT JOIN categories cat ON pc.category_id = cat.id
 		WHERE p.id IN (
 			SELECT post_id FROM post_reactions WHERE user_id = ? AND reaction = 'like'
 		)
-		GROUP BY p.id
+		AND p.status = 'published'
+		AND p.deleted_at IS NULL
+		GROUP BY p.id, p.title, p.content, p.user_id, u.username, u.avatar_path, p.created_at, p.updated_at, p.status
 		ORDER BY p.created_at DESC
 	`
 
@@ -400,6 +1174,8 @@ func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error)
 	for rows.Next() {
 		var post PostListItem
 		var categoriesStr sql.NullString
+		var avatarPath sql.NullString
+		var updatedAt sql.NullTime
 		var contentPreview sql.NullString
 
 		err := rows.Scan(
@@ -408,17 +1184,23 @@ func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error)
 			&contentPreview,
 			&post.AuthorID,
 			&post.AuthorUsername,
+			&avatarPath,
 			&post.CreatedAt,
+			&updatedAt,
 			&post.CommentCount,
 			&post.LikeCount,
 			&post.DislikeCount,
 			&categoriesStr,
 			&post.UserHasLiked,
 			&post.UserHasDisliked,
+			&post.Status,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
 		}
+		post.Score = post.LikeCount - post.DislikeCount
+		post.AuthorAvatarURL = config.AvatarURL(post.AuthorID, avatarPath.String)
+		post.UpdatedAt, post.IsEdited = editedAt(post.CreatedAt, updatedAt)
 
 		if contentPreview.Valid {
 			post.ContentPreview = contentPreview.String
@@ -427,11 +1209,7 @@ func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error)
 			}
 		}
 
-		if categoriesStr.Valid && categoriesStr.String != "" {
-			post.Categories = strings.Split(categoriesStr.String, ",")
-		} else {
-			post.Categories = []string{}
-		}
+		post.Categories = SplitCategoryLabels(categoriesStr.String)
 
 		posts = append(posts, post)
 	}
@@ -439,20 +1217,79 @@ func (s *PostQueryService) GetUserLikedPosts(userID int) ([]PostListItem, error)
 	return posts, nil
 }
 
-// GetAllCategories retrieves all categories with post counts
-func (s *PostQueryService) GetAllCategories() ([]CategorySummary, error) {
-	query := `
+// CountPosts returns the number of published posts
+func (s *PostQueryService) CountPosts(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM posts p
+		INNER JOIN users u ON p.user_id = u.id
+		WHERE p.status = 'published' AND p.deleted_at IS NULL
+	`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+	return count, nil
+}
+
+// CountPostsByCategory returns the number of published posts in a category
+func (s *PostQueryService) CountPostsByCategory(categoryID int) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM posts p
+		INNER JOIN users u ON p.user_id = u.id
+		INNER JOIN post_category pc ON p.id = pc.post_id
+		WHERE pc.category_id = ?
+		AND p.status = 'published' AND p.deleted_at IS NULL
+	`, categoryID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts in category %d: %w", categoryID, err)
+	}
+	return count, nil
+}
+
+// categorySummaryQuery selects every category with its number of
+// published posts, for an ORDER BY to be appended
+const categorySummaryQuery = `
 		SELECT 
 			c.id,
 			c.label,
+			COALESCE(c.slug, '') as slug,
 			COUNT(DISTINCT pc.post_id) as post_count
 		FROM categories c
 		LEFT JOIN post_category pc ON c.id = pc.category_id
-		GROUP BY c.id
-		ORDER BY c.label ASC
+			AND pc.post_id IN (SELECT id FROM posts WHERE status = 'published' AND deleted_at IS NULL)
+		GROUP BY c.id, c.label, c.slug
 	`
 
-	rows, err := s.db.Query(query)
+// GetAllCategories retrieves all categories with post counts
+func (s *PostQueryService) GetAllCategories() ([]CategorySummary, error) {
+	return s.listCategories(context.Background(), categorySummaryQuery+"ORDER BY c.label ASC")
+}
+
+// GetCategoriesPage returns one page of categories with post counts in the
+// given order
+func (s *PostQueryService) GetCategoriesPage(ctx context.Context, sort CategorySort, limit, offset int) ([]CategorySummary, error) {
+	categories, err := s.listCategories(ctx, categorySummaryQuery+"ORDER BY "+sort.OrderBy()+" LIMIT ? OFFSET ?", limit, offset)
+	if categories == nil && err == nil {
+		categories = []CategorySummary{}
+	}
+	return categories, err
+}
+
+// CountCategories returns the number of categories
+func (s *PostQueryService) CountCategories(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count categories: %w", err)
+	}
+	return count, nil
+}
+
+// listCategories runs a query selecting the CategorySummary columns
+func (s *PostQueryService) listCategories(ctx context.Context, query string, args ...interface{}) ([]CategorySummary, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query categories: %w", err)
 	}
@@ -461,7 +1298,7 @@ func (s *PostQueryService) GetAllCategories() ([]CategorySummary, error) {
 	var categories []CategorySummary
 	for rows.Next() {
 		var cat CategorySummary
-		err := rows.Scan(&cat.ID, &cat.Label, &cat.PostCount)
+		err := rows.Scan(&cat.ID, &cat.Label, &cat.Slug, &cat.PostCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan category: %w", err)
 		}
@@ -470,3 +1307,188 @@ func (s *PostQueryService) GetAllCategories() ([]CategorySummary, error) {
 
 	return categories, nil
 }
+
+// Weights of the engagement score: writing a post takes more than
+// commenting, which takes more than reacting
+const (
+	engagementPostWeight     = 3
+	engagementCommentWeight  = 2
+	engagementReactionWeight = 1
+)
+
+// engagementScore combines a category's counts into the number
+// GetCategoryEngagement ranks by
+func engagementScore(e CategoryEngagement) int {
+	return e.PostCount*engagementPostWeight +
+		e.CommentCount*engagementCommentWeight +
+		e.ReactionCount*engagementReactionWeight
+}
+
+// GetCategoryEngagement totals the posts, comments and reactions of every
+// category, most engaged first (ties by label). Comments and reactions are
+// counted per post before joining, so a post with many of both does not
+// multiply the rows.
+func (s *PostQueryService) GetCategoryEngagement(ctx context.Context) ([]CategoryEngagement, error) {
+	query := `
+		SELECT
+			c.id,
+			c.label,
+			COALESCE(c.slug, '') as slug,
+			COUNT(p.id) as post_count,
+			COALESCE(SUM(cm.comments), 0) as comment_count,
+			COALESCE(SUM(pr.likes), 0) + COALESCE(SUM(cr.likes), 0) as like_count,
+			COALESCE(SUM(pr.dislikes), 0) + COALESCE(SUM(cr.dislikes), 0) as dislike_count
+		FROM categories c
+		LEFT JOIN post_category pc ON c.id = pc.category_id
+		LEFT JOIN posts p ON p.id = pc.post_id
+			AND p.status = 'published' AND p.deleted_at IS NULL
+		LEFT JOIN (
+			SELECT post_id, COUNT(*) as comments
+			FROM comments
+			WHERE deleted_at IS NULL
+			GROUP BY post_id
+		) cm ON cm.post_id = p.id
+		LEFT JOIN (
+			SELECT pr.post_id,
+				SUM(CASE WHEN pr.reaction = 'like' THEN 1 ELSE 0 END) as likes,
+				SUM(CASE WHEN pr.reaction = 'dislike' THEN 1 ELSE 0 END) as dislikes
+			FROM post_reactions pr
+			INNER JOIN posts t ON t.id = pr.post_id` + s.notSelf("pr", "t") + `
+			GROUP BY pr.post_id
+		) pr ON pr.post_id = p.id
+		LEFT JOIN (
+			SELECT t.post_id,
+				SUM(CASE WHEN cr.reaction = 'like' THEN 1 ELSE 0 END) as likes,
+				SUM(CASE WHEN cr.reaction = 'dislike' THEN 1 ELSE 0 END) as dislikes
+			FROM comment_reactions cr
+			INNER JOIN comments t ON t.id = cr.comment_id
+				AND t.deleted_at IS NULL` + s.notSelf("cr", "t") + `
+			GROUP BY t.post_id
+		) cr ON cr.post_id = p.id
+		GROUP BY c.id, c.label, c.slug
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category engagement: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []CategoryEngagement{}
+	for rows.Next() {
+		var cat CategoryEngagement
+		err := rows.Scan(&cat.ID, &cat.Label, &cat.Slug, &cat.PostCount,
+			&cat.CommentCount, &cat.LikeCount, &cat.DislikeCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category engagement: %w", err)
+		}
+		cat.ReactionCount = cat.LikeCount + cat.DislikeCount
+		cat.Score = engagementScore(cat)
+		categories = append(categories, cat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read category engagement: %w", err)
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Score != categories[j].Score {
+			return categories[i].Score > categories[j].Score
+		}
+		return categories[i].Label < categories[j].Label
+	})
+	return categories, nil
+}
+
+// GetAuditLog returns limit audit log entries, newest first, starting at
+// offset. Past the last entry it returns an empty slice.
+func (s *PostQueryService) GetAuditLog(ctx context.Context, limit, offset int) ([]AuditLogEntry, error) {
+	query := `
+		SELECT
+			a.id,
+			COALESCE(a.actor_id, 0) as actor_id,
+			COALESCE(u.username, '') as actor_username,
+			a.action,
+			a.target_type,
+			a.target_id,
+			a.reason,
+			a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON a.actor_id = u.id
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditLogEntry{}
+	for rows.Next() {
+		var entry AuditLogEntry
+		err := rows.Scan(&entry.ID, &entry.ActorID, &entry.ActorUsername, &entry.Action,
+			&entry.TargetType, &entry.TargetID, &entry.Reason, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// CountAuditLog returns the number of audit log entries
+func (s *PostQueryService) CountAuditLog(ctx context.Context) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count audit log entries: %w", err)
+	}
+	return count, nil
+}
+
+// notificationLimit caps the unread list; older entries show up once the
+// newer ones are read
+const notificationLimit = 100
+
+// GetUnreadNotifications retrieves a user's unread notifications, newest first
+func (s *PostQueryService) GetUnreadNotifications(userID int) ([]NotificationItem, error) {
+	query := `
+		SELECT
+			n.id,
+			n.type,
+			n.actor_id,
+			u.username,
+			n.post_id,
+			p.title,
+			COALESCE(n.comment_id, 0) as comment_id,
+			n.created_at
+		FROM notifications n
+		INNER JOIN users u ON n.actor_id = u.id
+		INNER JOIN posts p ON n.post_id = p.id AND p.deleted_at IS NULL
+		WHERE n.user_id = ? AND n.read_at IS NULL
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT ?
+	`
+
+	rows, err := s.db.Query(query, userID, notificationLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []NotificationItem{}
+	for rows.Next() {
+		var n NotificationItem
+		err := rows.Scan(&n.ID, &n.Type, &n.ActorID, &n.ActorUsername, &n.PostID, &n.PostTitle, &n.CommentID, &n.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
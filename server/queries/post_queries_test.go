@@ -0,0 +1,59 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/testutil"
+)
+
+func TestCommentCountsSkipDeletedComments(t *testing.T) {
+	db := testutil.NewDB(t)
+	// Seeded post 1 by alice, in General (5), has two comments. Add three
+	// and soft-delete two of the five, leaving three live ones.
+	for _, content := range []string{"Third", "Fourth", "Fifth"} {
+		mustExec(t, db, "INSERT INTO comments (user_id, post_id, content) VALUES (4, 1, ?)", content)
+	}
+	mustExec(t, db, "UPDATE comments SET deleted_at = CURRENT_TIMESTAMP WHERE post_id = 1 AND content IN ('Nice forum setup!', 'Fourth')")
+	const want = 3
+
+	s := NewPostQueryService(db, config.ContentConfig{SelfReactions: config.SelfReactionsAllow})
+	ctx := context.Background()
+	listings := map[string]func() ([]PostListItem, error){
+		"GetAllPosts": func() ([]PostListItem, error) {
+			posts, _, err := s.GetAllPosts(ctx, 0)
+			return posts, err
+		},
+		"GetPostsPage":        func() ([]PostListItem, error) { return s.GetPostsPage(ctx, 0, 10, 0) },
+		"GetPostsByCategory":  func() ([]PostListItem, error) { return s.GetPostsByCategory(5, 0) },
+		"GetUserCreatedPosts": func() ([]PostListItem, error) { return s.GetUserCreatedPosts(1) },
+		"GetPostsByUser":      func() ([]PostListItem, error) { return s.GetPostsByUser(1, 0) },
+		"GetUserLikedPosts":   func() ([]PostListItem, error) { return s.GetUserLikedPosts(2) }, // bob likes post 1
+	}
+	for name, list := range listings {
+		t.Run(name, func(t *testing.T) {
+			posts, err := list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, post := range posts {
+				if post.ID == 1 {
+					if post.CommentCount != want {
+						t.Errorf("comment_count = %d, want %d", post.CommentCount, want)
+					}
+					return
+				}
+			}
+			t.Fatal("post 1 is not listed")
+		})
+	}
+
+	detail, err := s.GetPostByID(ctx, 1, 0, DefaultCommentSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Comments) != want {
+		t.Errorf("GetPostByID: %d comments, want %d", len(detail.Comments), want)
+	}
+}
@@ -0,0 +1,171 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrTooManySubscribers is returned when a post already has the
+	// maximum number of live subscribers
+	ErrTooManySubscribers = errors.New("too many subscribers for this post")
+	// ErrHubClosed is returned when subscribing after shutdown
+	ErrHubClosed = errors.New("live updates are shutting down")
+)
+
+// Event types sent to the subscribers of a post
+const (
+	EventComment         = "comment"
+	EventPostReaction    = "post_reaction"
+	EventCommentReaction = "comment_reaction"
+)
+
+// Event is one live update for the readers of a post
+type Event struct {
+	Type   string      `json:"type"`
+	PostID int         `json:"post_id"`
+	Data   interface{} `json:"data"`
+}
+
+// CommentData is the Data of an EventComment
+type CommentData struct {
+	ID             int64  `json:"id"`
+	AuthorID       int    `json:"author_id"`
+	AuthorUsername string `json:"author_username"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+	CommentCount   int    `json:"comment_count"` // comments on the post, including this one
+}
+
+// ReactionData is the Data of an EventPostReaction or EventCommentReaction.
+// ID is the post or comment whose counts changed.
+type ReactionData struct {
+	ID           int `json:"id"`
+	LikeCount    int `json:"like_count"`
+	DislikeCount int `json:"dislike_count"`
+}
+
+// Publisher accepts events for delivery. Writers depend on this interface
+// rather than on Hub so live updates stay optional and easy to fake.
+type Publisher interface {
+	Publish(event Event)
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is dropped
+const subscriberBuffer = 16
+
+// Hub fans events out to the subscribers of each post
+type Hub struct {
+	mu         sync.Mutex
+	maxPerPost int
+	posts      map[int]map[*Subscription]struct{}
+	closed     bool
+}
+
+// Subscription receives the events of one post until it is closed
+type Subscription struct {
+	hub    *Hub
+	postID int
+	events chan Event
+}
+
+// NewHub creates a hub allowing at most maxPerPost subscribers per post
+// (0 means no limit). All subscriptions are closed when ctx is cancelled.
+func NewHub(ctx context.Context, maxPerPost int) *Hub {
+	h := &Hub{
+		maxPerPost: maxPerPost,
+		posts:      make(map[int]map[*Subscription]struct{}),
+	}
+
+	go func() {
+		<-ctx.Done()
+		h.close()
+	}()
+
+	return h
+}
+
+// Subscribe starts receiving the events of postID
+func (h *Hub) Subscribe(postID int) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	subs := h.posts[postID]
+	if h.maxPerPost > 0 && len(subs) >= h.maxPerPost {
+		return nil, ErrTooManySubscribers
+	}
+	if subs == nil {
+		subs = make(map[*Subscription]struct{})
+		h.posts[postID] = subs
+	}
+
+	s := &Subscription{hub: h, postID: postID, events: make(chan Event, subscriberBuffer)}
+	subs[s] = struct{}{}
+	return s, nil
+}
+
+// Publish sends event to every subscriber of event.PostID without
+// blocking. A subscriber whose buffer is full is dropped, which closes its
+// channel so the connection can be ended.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.posts[event.PostID] {
+		select {
+		case s.events <- event:
+		default:
+			h.removeLocked(s)
+		}
+	}
+}
+
+// Subscribers returns the current number of subscribers of postID
+func (h *Hub) Subscribers(postID int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.posts[postID])
+}
+
+// Events returns the channel of the subscription. It is closed when the
+// subscription ends, either by Close, for being too slow, or at shutdown.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+func (h *Hub) removeLocked(s *Subscription) {
+	subs := h.posts[s.postID]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.posts, s.postID)
+	}
+	close(s.events)
+}
+
+// close ends every subscription and refuses new ones
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.posts {
+		for s := range subs {
+			h.removeLocked(s)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"forum/server/middleware"
+)
+
+// RedirectToHTTPS answers every plain HTTP request with a permanent
+// redirect to the same path on the HTTPS listener at httpsPort. The
+// redirect is built from the Host header, so only allowedHosts (see
+// middleware.TrustedHosts) are redirected.
+func RedirectToHTTPS(httpsPort int, allowedHosts string) http.Handler {
+	return middleware.TrustedHosts(allowedHosts)(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, fmt.Sprint(httpsPort))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -1,94 +1,306 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"time"
 
+	"forum/server/clock"
+	"forum/server/commands"
+	"forum/server/config"
 	"forum/server/controllers"
+	"forum/server/metrics"
 	"forum/server/middleware"
+	"forum/server/queries"
+	"forum/server/realtime"
+	"forum/server/utils"
 )
 
-func Routes(db *sql.DB) http.Handler {
+// Routes builds the application handler around the post query cache
+// created by the caller. Background goroutines started here (rate limiter
+// cleanup) exit when ctx is cancelled, which also ends all live update
+// connections. clk tells the time for session expiry and rate limit
+// refills: clock.Real, or a clock.Fake in tests.
+func Routes(ctx context.Context, db *sql.DB, cfg *config.Config, logger *utils.Logger, postQueries *queries.CachedPostQueryService, wordFilter *utils.WordFilter, clk clock.Clock) http.Handler {
 	mux := http.NewServeMux()
 
 	// Initialize rate limiter
-	limiter := middleware.NewRateLimiter()
+	limiter := middleware.NewRateLimiter(ctx, clk)
+
+	// Failed and throttled logins are logged with a per-IP failure count
+	loginMonitor := utils.NewLoginMonitor(logger, 15*time.Minute)
 	
-	// Rate limit configurations
-	publicLimit := middleware.RateLimit(limiter, 100, time.Minute)     // 100 req/min for public
-	loginLimit := middleware.RateLimit(limiter, 5, time.Minute)        // 5 req/min for login (brute-force protection)
-	createLimit := middleware.RateLimit(limiter, 10, time.Minute)      // 10 req/min for creates (spam protection)
+	// Rate limit configurations; a policy in monitor mode only logs
+	// the requests it would reject
+	rateLimit := func(policy, mode string, limit middleware.Limit, onLimit ...func(r *http.Request)) func(http.HandlerFunc) http.HandlerFunc {
+		if mode == config.RateLimitMonitor {
+			return middleware.MonitorRateLimit(limiter, policy, limit, logger)
+		}
+		return middleware.RateLimit(limiter, policy, limit, append([]func(r *http.Request){countRejection(policy)}, onLimit...)...)
+	}
+	limits := cfg.RateLimit
+	publicLimit := rateLimit("public", limits.PublicMode,
+		configuredLimit(logger, "public", limits.PublicBurst, limits.PublicRefill, middleware.PerWindow(100, time.Minute)))
+	loginLimit := rateLimit("login", limits.LoginMode, // brute-force protection
+		configuredLimit(logger, "login", limits.LoginBurst, limits.LoginRefill, middleware.PerWindow(5, time.Minute)),
+		func(r *http.Request) {
+			loginMonitor.Throttled(utils.ClientIP(r), r.PostFormValue("username"), r.URL.Path)
+		})
+	createLimit := rateLimit("create", limits.CreateMode, // spam protection
+		configuredLimit(logger, "create", limits.CreateBurst, limits.CreateRefill, middleware.PerWindow(10, time.Minute)))
+
+	// Authentication for protected and mutate routes
+	auth := middleware.RequireAuth(db, clk)
+	admin := middleware.RequireAdmin(db, clk)
+
+	categories := commands.NewCategoryCommandHandler(db)
+	notifications := commands.NewNotificationCommandHandler(db)
+	liveUpdates := realtime.NewHub(ctx, cfg.Server.LiveSubscribersPerPost)
+	posts := commands.NewPostCommandHandler(db, wordFilter, cfg.Content, liveUpdates)
 
 	// serve static files (no rate limit needed)
-	mux.HandleFunc("/assets/", controllers.ServeStaticFiles)
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		controllers.ServeStaticFiles(w, r, cfg.Web.AssetsDir)
+	})
+
+	// uploaded images (no rate limit, like the other static files)
+	mux.HandleFunc("/uploads/{name}", func(w http.ResponseWriter, r *http.Request) {
+		controllers.ServeUpload(w, r, cfg.Upload)
+	})
+
+	// generated avatars for users without an uploaded one
+	mux.HandleFunc("/avatar/{id}", func(w http.ResponseWriter, r *http.Request) {
+		controllers.DefaultAvatar(w, r)
+	})
 
 	// Health check endpoint (no auth, no rate limit - used by load balancers)
-	mux.HandleFunc("/health", controllers.HealthCheck(db))
+	mux.HandleFunc("/health", controllers.HealthCheck(db, cfg.App.BasePath+"server/database/migrations"))
+
+	// Prometheus metrics, unless they get a port of their own (see main)
+	if cfg.Metrics.Enabled && cfg.Metrics.Port == 0 {
+		mux.HandleFunc("/metrics", metrics.Default.Handler())
+	}
 
 	// Public routes with rate limiting
+	mux.HandleFunc("/{$}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.IndexPosts(w, r, db, postQueries, cfg.Content, clk)
+	}))
+
+	// Anything no other route matches gets the themed 404 page, or the
+	// single-page app's index.html with WEB_SPA_FALLBACK
 	mux.HandleFunc("/", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.IndexPosts(w, r, db)
+		if cfg.Web.SPAFallback {
+			controllers.SPAFallback(w, r, db, cfg.Web, clk)
+			return
+		}
+		controllers.NotFound(w, r, db, clk)
 	}))
 	
+	// Published posts as a JSON array, paged through headers
+	mux.HandleFunc("/api/posts", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.ListPosts(w, r, db, postQueries, clk)
+	}))
+
+	mux.HandleFunc("/categories", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.IndexCategories(w, r, db, postQueries, clk)
+	}))
+
 	mux.HandleFunc("/category/{id}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.IndexPostsByCategory(w, r, db)
+		controllers.IndexPostsByCategory(w, r, db, postQueries, cfg.Content, clk)
+	}))
+	
+	mux.HandleFunc("/c/{slug}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.IndexPostsByCategorySlug(w, r, db, postQueries, cfg.Content, clk)
 	}))
 	
 	mux.HandleFunc("/post/{id}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.ShowPost(w, r, db)
+		controllers.ShowPost(w, r, db, cfg.Content, clk)
+	}))
+
+	// One page of a post's comments, for "load more"
+	mux.HandleFunc("/post/{id}/comments", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.PostComments(w, r, db, cfg.Content, clk)
+	}))
+
+	// Who liked or disliked a post (the author and admins, see REACTORS_VISIBILITY)
+	mux.HandleFunc("/post/{id}/reactions", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.PostReactors(w, r, db, cfg.Content, clk)
+	}))
+
+	// Live comments and reaction counts for readers of a post
+	mux.HandleFunc("/ws/post/{id}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.PostUpdates(w, r, db, liveUpdates)
+	}))
+
+	mux.HandleFunc("/user/{id}", publicLimit(func(w http.ResponseWriter, r *http.Request) {
+		controllers.UserProfile(w, r, db, cfg.Content, clk)
 	}))
 
 	// Auth routes - strict rate limiting to prevent brute force
 	mux.HandleFunc("/login", loginLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.GetLoginPage(w, r, db)
+		controllers.GetLoginPage(w, r, db, clk)
 	}))
 	
 	mux.HandleFunc("/signin", loginLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.Signin(w, r, db)
+		controllers.Signin(w, r, db, cfg.Session, clk, loginMonitor)
 	})))
 	
 	mux.HandleFunc("/register", loginLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.GetRegisterPage(w, r, db)
+		controllers.GetRegisterPage(w, r, db, clk)
 	}))
 	
 	mux.HandleFunc("/signup", loginLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.Signup(w, r, db)
+		controllers.Signup(w, r, db, clk)
 	})))
 	
 	mux.HandleFunc("/logout", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.Logout(w, r, db)
+		controllers.Logout(w, r, db, cfg.Session, clk)
 	}))
 
-	// Protected routes - moderate rate limiting + input sanitization
-	mux.HandleFunc("/mycreatedposts", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.MyCreatedPosts(w, r, db)
-	}))
+	// Protected routes - moderate rate limiting + authentication
+	mux.HandleFunc("/mycreatedposts", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.MyCreatedPosts(w, r, db, cfg.Content)
+	})))
 	
-	mux.HandleFunc("/mylikedposts", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.MyLikedPosts(w, r, db)
-	}))
+	mux.HandleFunc("/mylikedposts", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.MyLikedPosts(w, r, db, cfg.Content)
+	})))
 	
-	mux.HandleFunc("/post/create", publicLimit(func(w http.ResponseWriter, r *http.Request) {
-		controllers.GetPostCreationForm(w, r, db)
-	}))
+	// Download of everything stored about the account (admins: ?user_id=N)
+	mux.HandleFunc("/myaccount/export", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.ExportAccount(w, r, db, cfg.Content)
+	})))
+
+	// Who is logged in, as JSON (401 without a valid session)
+	mux.HandleFunc("/api/me", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.CurrentUser(w, r, db)
+	})))
 
-	// Create/mutate routes - strict rate limiting + sanitization
-	mux.HandleFunc("/post/createpost", createLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.CreatePost(w, r, db)
+	mux.HandleFunc("/notifications", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.ShowNotifications(w, r, db, postQueries)
 	})))
+
+	mux.HandleFunc("/post/create", publicLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.GetPostCreationForm(w, r, db, cfg.Content)
+	})))
+
+	// Create/mutate routes - strict rate limiting + authentication + sanitization
+	mux.HandleFunc("/post/createpost", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.CreatePost(w, r, db, cfg.Content, wordFilter)
+	}))))
 	
-	mux.HandleFunc("/post/addcommentREQ", createLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.CreateComment(w, r, db)
+	// Renders the post form for a preview; nothing is saved
+	mux.HandleFunc("/post/preview", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.PreviewPost(w, r, cfg.Content, wordFilter)
+	}))))
+
+	// Multipart upload: Sanitize is left out, it would only see the post_id
+	mux.HandleFunc("/post/upload", createLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.UploadPostImage(w, r, db, cfg.Upload)
 	})))
 
-	mux.HandleFunc("/post/postreaction", createLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.ReactToPost(w, r, db)
+	mux.HandleFunc("/user/avatar", createLimit(auth(func(w http.ResponseWriter, r *http.Request) {
+		controllers.UploadAvatar(w, r, db, cfg.Upload)
 	})))
 
-	mux.HandleFunc("/post/commentreaction", createLimit(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
-		controllers.ReactToComment(w, r, db)
+	mux.HandleFunc("/post/publish", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.PublishPost(w, r, db)
+	}))))
+
+	// Close a post to new comments or reopen it (author or admin)
+	mux.HandleFunc("/post/lock", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.SetPostLock(w, r, posts, postQueries, true)
+	}))))
+
+	mux.HandleFunc("/post/unlock", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.SetPostLock(w, r, posts, postQueries, false)
+	}))))
+
+	mux.HandleFunc("/post/addcommentREQ", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.CreateComment(w, r, db, liveUpdates, cfg.Content, wordFilter)
+	}))))
+
+	mux.HandleFunc("/post/postreaction", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.ReactToPost(w, r, db, liveUpdates, cfg.Content)
+	}))))
+
+	mux.HandleFunc("/post/commentreaction", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.ReactToComment(w, r, db, liveUpdates, cfg.Content)
+	}))))
+
+	mux.HandleFunc("/myaccount/delete", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.DeleteAccount(w, r, db, postQueries, cfg.Content, cfg.Upload, cfg.Session, clk)
+	}))))
+
+	mux.HandleFunc("/notifications/read", createLimit(auth(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.MarkNotificationRead(w, r, notifications)
+	}))))
+
+	// Admin routes - admin role required
+	mux.HandleFunc("/admin/category/create", createLimit(admin(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.CreateCategory(w, r, categories, postQueries)
+	}))))
+
+	mux.HandleFunc("/admin/category/rename", createLimit(admin(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.RenameCategory(w, r, categories, postQueries)
+	}))))
+
+	mux.HandleFunc("/admin/category/delete", createLimit(admin(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.DeleteCategory(w, r, categories, postQueries)
+	}))))
+
+	mux.HandleFunc("/admin/stats", publicLimit(admin(func(w http.ResponseWriter, r *http.Request) {
+		controllers.AdminStats(w, r, postQueries)
 	})))
 
-	return mux
+	// Moderation actions taken with admin rights, newest first
+	mux.HandleFunc("/admin/audit", publicLimit(admin(func(w http.ResponseWriter, r *http.Request) {
+		controllers.AuditLog(w, r, postQueries)
+	})))
+
+	// Keep a post at the top of the homepage and category listings
+	mux.HandleFunc("/admin/post/pin", createLimit(admin(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.SetPostPin(w, r, posts, postQueries, true)
+	}))))
+
+	mux.HandleFunc("/admin/post/unpin", createLimit(admin(middleware.Sanitize(func(w http.ResponseWriter, r *http.Request) {
+		controllers.SetPostPin(w, r, posts, postQueries, false)
+	}))))
+
+	// Wrap the whole mux so every route, including /health and /assets/,
+	// gets a request ID, request logging and panic recovery, and active
+	// sessions are extended. Requests for an unknown Host are refused, and
+	// maintenance turns visitors away before their session is touched.
+	// Order (outermost first): RequestID -> Logging -> Recovery -> TrustedHosts -> Deadline -> Maintenance -> SlidingSession -> handler
+	logging := middleware.Logging(logger, func(r *http.Request, status int, duration time.Duration) {
+		// Label by route pattern, not path, to keep the number of series bounded
+		_, pattern := mux.Handler(r)
+		metrics.ObserveRequest(pattern, r.Method, status, duration)
+	})
+	recovery := middleware.Recovery(logger)
+	hosts := middleware.TrustedHosts(cfg.Server.AllowedHosts)
+	deadline := middleware.Deadline(cfg.Database.QueryTimeout)
+	maintenance := middleware.Maintenance(db, cfg.Maintenance, clk)
+	sliding := middleware.SlidingSession(db, cfg.Session, clk)
+
+	return middleware.RequestID(logging(recovery(hosts(deadline(maintenance(sliding(mux.ServeHTTP)))))))
+}
+
+// countRejection is a RateLimit hook counting the requests the named
+// limiter turns away
+func countRejection(limiter string) func(r *http.Request) {
+	return func(r *http.Request) {
+		metrics.RateLimited(limiter)
+	}
+}
+
+// configuredLimit is the token bucket set by burst and refill, or fallback
+// (logged) when either is not positive
+func configuredLimit(logger *utils.Logger, policy string, burst int, refill time.Duration, fallback middleware.Limit) middleware.Limit {
+	if burst <= 0 || refill <= 0 {
+		logger.Warn("Invalid rate limit, using the default", "policy", policy, "burst", burst, "refill", refill.String())
+		return fallback
+	}
+	return middleware.Limit{Burst: burst, Refill: refill}
 }
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/server/clock"
+	"forum/server/config"
+	"forum/server/models"
+	"forum/server/queries"
+	"forum/server/testutil"
+	"forum/server/utils"
+)
+
+// newTestHandler builds the whole application handler over a migrated
+// database in a temporary file, logging to out
+func newTestHandler(t *testing.T, out *bytes.Buffer) http.Handler {
+	t.Helper()
+
+	db := testutil.NewDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return newTestStack(ctx, t, db, out, clock.Real)
+}
+
+// newTestStack wires the query cache and the routes as main does, telling
+// the time by clk; their background goroutines run until ctx is cancelled
+func newTestStack(ctx context.Context, t *testing.T, db *sql.DB, out *bytes.Buffer, clk clock.Clock) http.Handler {
+	t.Helper()
+
+	t.Setenv("BASE_PATH", "../../")
+	cfg := config.LoadConfig()
+	postQueries := queries.NewCachedPostQueryService(ctx, db, cfg.Cache, cfg.Content)
+	return Routes(ctx, db, cfg, utils.NewLogger(out, "info"), postQueries, nil, clk)
+}
+
+func TestRoutesLogEveryRequest(t *testing.T) {
+	var out bytes.Buffer
+	handler := newTestHandler(t, &out)
+
+	// The health check and static files skip rate limiting and auth, but
+	// not the logging middleware
+	for _, path := range []string{"/health", "/assets/css/app.css", "/assets/missing.css"} {
+		out.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if logged := out.String(); !strings.Contains(logged, "HTTP Request") || !strings.Contains(logged, path) {
+			t.Errorf("%s was not logged: %q", path, logged)
+		}
+	}
+}
+
+func TestShutdownStopsBackgroundGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// Start the stack the way main does and serve a few requests
+	db := testutil.NewDB(t)
+	background, stopBackground := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	server := httptest.NewServer(newTestStack(background, t, db, &out, clock.Real))
+	client := server.Client()
+	for _, path := range []string{"/health", "/api/posts", "/post/1"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// Then shut it down in main's order
+	client.CloseIdleConnections()
+	server.Close()
+	stopBackground()
+	if err := db.Close(); err != nil {
+		t.Fatalf("closing the database: %v", err)
+	}
+
+	// Goroutines take a moment to notice the cancellation
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines before starting, %d after stopping:\n%s", before, after, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestRateLimitsFollowConfig(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN_BURST", "2")
+	t.Setenv("RATE_LIMIT_LOGIN_REFILL", "1h")
+	var out bytes.Buffer
+	handler := newTestHandler(t, &out)
+
+	codes := make([]int, 3)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+		codes[i] = w.Code
+	}
+	if codes[0] == http.StatusTooManyRequests || codes[1] == http.StatusTooManyRequests || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses %v, want the third request limited", codes)
+	}
+}
+
+func TestPagesJudgeSessionsByTheRoutesClock(t *testing.T) {
+	utils.SetTemplatesDir("../../web/templates")
+	db := testutil.NewDB(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := clock.NewFake(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out bytes.Buffer
+	handler := newTestStack(ctx, t, db, &out, now)
+	// A fresh session, which the sliding expiry leaves alone at first
+	idle := config.LoadConfig().Session.IdleTimeout
+	if err := models.StoreSession(db, 1, "alice-session", start, start.Add(idle), false); err != nil {
+		t.Fatal(err)
+	}
+
+	loggedIn := func(path string) bool {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.AddCookie(&http.Cookie{Name: config.SessionCookieName, Value: "alice-session"})
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d", path, w.Code)
+		}
+		return strings.Contains(w.Body.String(), `href="/mycreatedposts"`)
+	}
+
+	// By the real clock the session ended in 2024, so only pages that read
+	// the fake one show alice as logged in here
+	for _, path := range []string{"/", "/post/1"} {
+		if !loggedIn(path) {
+			t.Errorf("GET %s before expiry: page shows a logged-out visitor", path)
+		}
+	}
+	now.Advance(idle)
+	for _, path := range []string{"/", "/post/1"} {
+		if loggedIn(path) {
+			t.Errorf("GET %s after expiry: page shows alice as logged in", path)
+		}
+	}
+}
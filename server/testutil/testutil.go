@@ -0,0 +1,64 @@
+// Package testutil holds what the tests of several packages share: a
+// migrated database opened the way the server opens it, and a Publisher
+// that drops events.
+package testutil
+
+import (
+	"database/sql"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"forum/server/config"
+	"forum/server/migrations"
+	"forum/server/realtime"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewDB returns a database in a temporary file with every migration, and
+// so the demo data, applied. It is closed when the test ends.
+func NewDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return NewDBAt(t, filepath.Join(t.TempDir(), "forum.db"))
+}
+
+// NewDBAt is NewDB with the database file at path. The database is opened
+// by config.Connect, so tests run with the foreign keys, WAL journal and
+// busy timeout of production; DB_PATH points at path for the rest of the
+// test.
+func NewDBAt(t *testing.T, path string) *sql.DB {
+	t.Helper()
+
+	t.Setenv("ENV", "development")
+	t.Setenv("DB_DRIVER", "sqlite3")
+	t.Setenv("BASE_PATH", "")
+	t.Setenv("DB_DSN", "")
+	t.Setenv("DB_PATH", path)
+	db, err := config.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator := migrations.NewMigrator(db, migrationsDir())
+	if err := migrator.InitMigrationsTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// migrationsDir finds server/database/migrations from this file, so the
+// tests of any package can use it
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "database", "migrations")
+}
+
+// NopPublisher drops live update events
+type NopPublisher struct{}
+
+func (NopPublisher) Publish(realtime.Event) {}
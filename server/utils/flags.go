@@ -1,33 +1,96 @@
 package utils
 
 import (
+	"bufio"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 
 	"forum/server/config"
+	"forum/server/health"
 	"forum/server/migrations"
 )
 
-var ValidFlags = []string{"--migrate", "--seed", "--drop", "--migrate-up", "--migrate-down", "--migrate-status"}
+var ValidFlags = []string{"--migrate", "--seed", "--drop", "--migrate-up", "--migrate-down", "--migrate-status", "--migrate-to", "--migrate-create", "--seed-migrations", "--healthcheck"}
 
+// ErrUnhealthy is returned by --healthcheck when a check failed. The
+// report has been printed already, so callers only need to exit non-zero.
+var ErrUnhealthy = errors.New("health check failed")
+
+// HandleFlags runs the given flags in order, e.g. --migrate-up --seed.
+// Every flag is validated before the first one runs, and the sequence
+// stops at the first flag that fails.
 func HandleFlags(flags []string, db *sql.DB) error {
-	if len(flags) != 1 {
-		return fmt.Errorf("expected a single flag, got %d", len(flags))
+	if len(flags) == 0 {
+		return errors.New("expected at least one flag")
+	}
+
+	for i, arg := range flags {
+		if err := validateFlag(arg); err != nil {
+			return err
+		}
+		// The open connection still points at the deleted file afterwards
+		if strings.HasPrefix(arg, "--drop") && i < len(flags)-1 {
+			return errors.New("--drop must be the last flag")
+		}
+	}
+	for _, arg := range flags {
+		flag, value, _ := strings.Cut(arg, "=")
+		if err := runFlag(flag, value, db); err != nil {
+			if len(flags) > 1 {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			return err
+		}
 	}
+	return nil
+}
 
-	flag := flags[0]
+// validateFlag checks that arg is one of ValidFlags and carries a value
+// exactly when that flag allows one
+func validateFlag(arg string) error {
+	// Flags such as --migrate-to=NNN carry their value after "="
+	flag, value, hasValue := strings.Cut(arg, "=")
 	if !slices.Contains(ValidFlags, flag) {
-		return fmt.Errorf("invalid flag: '%s'", flag)
+		return fmt.Errorf("invalid flag: '%s'", arg)
+	}
+	takesValue := flag == "--migrate-to" || flag == "--migrate-create"
+	if takesValue && (!hasValue || value == "") {
+		return fmt.Errorf("flag '%s' requires a value, e.g. %s=<value>", flag, flag)
+	}
+	// A few flags take an optional value
+	optional := map[string]string{"--healthcheck": "json", "--seed": "force", "--drop": "force"}
+	if allowed, ok := optional[flag]; ok && hasValue && value != allowed {
+		return fmt.Errorf("flag '%s' only accepts the value %s", flag, allowed)
+	}
+	if _, ok := optional[flag]; !takesValue && hasValue && !ok {
+		return fmt.Errorf("flag '%s' does not take a value", flag)
 	}
+	return nil
+}
 
+// runFlag runs one validated flag
+func runFlag(flag, value string, db *sql.DB) error {
 	switch flag {
 	case "--migrate":
 		return config.CreateTables(db)
 	case "--seed":
-		return config.CreateDemoData(db)
+		results, err := config.CreateDemoData(db, value == "force")
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			fmt.Printf("  %-18s %3d inserted, %3d skipped\n", r.Table, r.Inserted, r.Skipped)
+		}
+		return nil
 	case "--drop":
-		return config.Drop()
+		return drop(value == "force", os.Stdin)
 	case "--migrate-up":
 		cfg := config.LoadConfig()
 		migrationsDir := cfg.App.BasePath + "server/database/migrations"
@@ -49,18 +112,115 @@ func HandleFlags(flags []string, db *sql.DB) error {
 			return err
 		}
 		return migrator.Status()
+	case "--migrate-to":
+		cfg := config.LoadConfig()
+		migrationsDir := cfg.App.BasePath + "server/database/migrations"
+		migrator := migrations.NewMigrator(db, migrationsDir)
+		if err := migrator.InitMigrationsTable(); err != nil {
+			return err
+		}
+		return migrator.MigrateTo(value)
+	case "--migrate-create":
+		cfg := config.LoadConfig()
+		migrationsDir := cfg.App.BasePath + "server/database/migrations"
+		migrator := migrations.NewMigrator(db, migrationsDir)
+		_, err := migrator.Create(value)
+		return err
+	case "--seed-migrations":
+		cfg := config.LoadConfig()
+		migrationsDir := cfg.App.BasePath + "server/database/migrations"
+		migrator := migrations.NewMigrator(db, migrationsDir)
+		if err := migrator.InitMigrationsTable(); err != nil {
+			return err
+		}
+		return migrator.Seed()
+	case "--healthcheck":
+		cfg := config.LoadConfig()
+		migrationsDir := cfg.App.BasePath + "server/database/migrations"
+		return runHealthCheck(db, migrationsDir, value == "json")
+	}
+	return nil
+}
+
+// drop deletes the database after showing which file that is and, unless
+// force is set, asking the operator to type "yes". Production databases
+// are never dropped (see config.Drop).
+func drop(force bool, in io.Reader) error {
+	if config.LoadConfig().App.IsProduction {
+		return config.ErrDropInProduction
+	}
+	path, err := config.DatabaseFile()
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	fmt.Printf("About to drop the database %s\n", path)
+	if !force {
+		fmt.Print("All data will be lost. Type \"yes\" to continue: ")
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			return errors.New("drop cancelled")
+		}
+	}
+	return config.Drop()
+}
+
+// runHealthCheck runs the /health checks and prints the report, as JSON or
+// as one line per check. It returns ErrUnhealthy when a check failed.
+func runHealthCheck(db *sql.DB, migrationsDir string, asJSON bool) error {
+	report := health.Run(db, migrationsDir)
+
+	if asJSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Status: %s (version %s)\n", report.Status, report.Version)
+		for _, name := range health.CheckNames {
+			check, ok := report.Checks[name]
+			if !ok {
+				continue
+			}
+			line := fmt.Sprintf("  %-14s %-5s %s", name, check.Status, check.Message)
+			if check.Time != "" {
+				line += " (" + check.Time + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if report.Status == "unhealthy" {
+		return ErrUnhealthy
 	}
 	return nil
 }
 
 func Usage() {
-	fmt.Println(`Usage: go run main.go [option]
+	fmt.Println(`Usage: go run main.go [option...]
+Options can be combined and run in the order given, e.g.
+  go run main.go --migrate-up --seed
 Options:
   --migrate         Create database tables (legacy)
-  --seed            Insert demo data into the database
-  --drop            Drop all tables
+  --seed            Insert demo data into the database (skips rows already there;
+                    refused with ENV=production, use --seed=force to override)
+  --drop            Drop all tables, after typing "yes" to confirm (never
+                    allowed with ENV=production)
+  --drop=force      The same, without asking. --drop must come last.
   
   --migrate-up      Apply all pending migrations
   --migrate-down    Rollback last applied migration
-  --migrate-status  Show migration status`)
+  --migrate-status  Show migration status
+  --migrate-to=NNN  Apply or rollback migrations to reach version NNN
+  --migrate-create=name
+                    Create empty up/down files for a new migration
+  --seed-migrations Apply pending NNN_name.seed.sql files (tracked, run once)
+
+  --healthcheck     Run the /health checks, print them and exit 1 if unhealthy
+  --healthcheck=json
+                    The same, printing the report as JSON`)
 }
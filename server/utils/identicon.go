@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// identiconCells is the width and height of the identicon grid
+const identiconCells = 5
+
+// Identicon returns a deterministic SVG avatar for seed: a horizontally
+// mirrored 5x5 pattern in a colour taken from the seed's hash, like the
+// default avatars on GitHub.
+func Identicon(seed string) []byte {
+	sum := sha256.Sum256([]byte(seed))
+
+	// Hue from the first bytes; fixed saturation and lightness keep every
+	// colour readable on a light background
+	hue := (int(sum[0])<<8 | int(sum[1])) % 360
+	color := fmt.Sprintf("hsl(%d, 55%%, 50%%)", hue)
+
+	const cell = 10
+	var b strings.Builder
+	size := identiconCells*cell + 2*cell
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size, size, size, size)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#f0f0f0"/>`, size, size)
+
+	half := (identiconCells + 1) / 2
+	for row := 0; row < identiconCells; row++ {
+		for col := 0; col < half; col++ {
+			// One bit per cell, skipping the bytes used for the colour
+			bit := row*half + col
+			if sum[2+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, identiconCells - 1 - col} {
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+					cell+c*cell, cell+row*cell, cell, cell, color)
+				if c == identiconCells-1-c {
+					break // middle column
+				}
+			}
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrImageTooLarge is returned for uploads above the configured size
+	ErrImageTooLarge = errors.New("image is too large")
+	// ErrImageType is returned for anything but the allowed image types
+	ErrImageType = errors.New("only JPEG, PNG, GIF and WebP images are allowed")
+	// ErrImageEmpty is returned for an empty upload
+	ErrImageEmpty = errors.New("image is empty")
+)
+
+// AllowedImageTypes maps the accepted MIME types to the file extension
+// stored images get
+var AllowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// SaveImage validates an uploaded image and stores it in dir under a
+// random name, which it returns. The type is sniffed from the content, not
+// taken from the client's Content-Type or file name, and anything larger
+// than maxSize bytes is rejected with ErrImageTooLarge.
+func SaveImage(src io.Reader, dir string, maxSize int64) (string, error) {
+	// Read one byte past the limit to tell "exactly maxSize" from "more"
+	data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) == 0 {
+		return "", ErrImageEmpty
+	}
+	if int64(len(data)) > maxSize {
+		return "", ErrImageTooLarge
+	}
+
+	ext, ok := AllowedImageTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrImageType
+	}
+
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", fmt.Errorf("failed to name image: %w", err)
+	}
+	name := hex.EncodeToString(random[:]) + ext
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create image file: %w", err)
+	}
+	if _, err := io.Copy(file, bytes.NewReader(data)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+
+	return name, nil
+}
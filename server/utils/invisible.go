@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF8 is returned for text that is not valid UTF-8
+var ErrInvalidUTF8 = errors.New("text is not valid UTF-8")
+
+const zeroWidthJoiner = '\u200d'
+
+// blankLetters render as nothing but are classed as letters or marks, so
+// the category checks below do not catch them
+var blankLetters = map[rune]bool{
+	'\u034f': true, // combining grapheme joiner
+	'\u115f': true, // hangul choseong filler
+	'\u1160': true, // hangul jungseong filler
+	'\u3164': true, // hangul filler
+	'\uffa0': true, // halfwidth hangul filler
+}
+
+// CleanText checks that text is valid UTF-8 and removes the invisible
+// characters in it: control characters other than tab and newlines,
+// format characters such as zero-width spaces and bidi overrides, and
+// blank fillers. A zero-width joiner between two emoji is kept, since
+// it is what builds emoji like families and flags. found reports
+// whether anything was removed.
+func CleanText(text string) (cleaned string, found bool, err error) {
+	if !utf8.ValidString(text) {
+		return "", false, ErrInvalidUTF8
+	}
+
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	for i, r := range runes {
+		if isInvisible(runes, i) {
+			found = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if !found {
+		return text, false, nil
+	}
+	return b.String(), true, nil
+}
+
+// isInvisible reports whether runes[i] should be removed
+func isInvisible(runes []rune, i int) bool {
+	r := runes[i]
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return false
+	case r == zeroWidthJoiner:
+		return !(i > 0 && i+1 < len(runes) && isEmojiPart(runes[i-1]) && isEmojiPart(runes[i+1]))
+	case unicode.Is(unicode.Cc, r) || unicode.Is(unicode.Cf, r):
+		return true
+	}
+	return blankLetters[r]
+}
+
+// isEmojiPart reports whether r can sit next to a zero-width joiner in an
+// emoji sequence: a symbol or the emoji variation selector
+func isEmojiPart(r rune) bool {
+	return r == '\ufe0f' || unicode.Is(unicode.So, r)
+}
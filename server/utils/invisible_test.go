@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		wantFound bool
+	}{
+		{"plain text", "hello world", "hello world", false},
+		{"tabs and newlines kept", "a\tb\r\nc", "a\tb\r\nc", false},
+		{"zero-width space", "da\u200bmn", "damn", true},
+		{"zero-width non-joiner and BOM", "\ufeffda\u200cmn", "damn", true},
+		{"bidi override", "abc\u202edcba", "abcdcba", true},
+		{"control character", "a\x00b\x1bc", "abc", true},
+		{"soft hyphen", "da\u00admn", "damn", true},
+		{"hangul filler", "\u3164\u3164", "", true},
+		{"combining grapheme joiner", "d\u034fa", "da", true},
+		{"joiner between letters", "da\u200dmn", "damn", true},
+		{"emoji family kept", "👩\u200d👩\u200d👧", "👩\u200d👩\u200d👧", false},
+		{"joiner after emoji and letter", "👩\u200da", "👩a", true},
+		{"accents kept", "café naïve", "café naïve", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := CleanText(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("CleanText(%q) = %q, %v; want %q, %v", tt.text, got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCleanTextRejectsInvalidUTF8(t *testing.T) {
+	for _, text := range []string{"\xff", "ab\xc3", "\xed\xa0\x80"} {
+		if _, _, err := CleanText(text); !errors.Is(err, ErrInvalidUTF8) {
+			t.Errorf("CleanText(%q) error = %v, want ErrInvalidUTF8", text, err)
+		}
+	}
+}
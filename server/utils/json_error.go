@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// APIError is the body of every JSON error response, sent as
+// {"error": {"code": ..., "message": ..., "fields": {...}}}. Code is a
+// stable reason for scripts to switch on, Message is fit to show to the
+// user, and Fields maps input fields to what is wrong with each.
+type APIError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// ErrorCodeFor returns the APIError code for a status when nothing more
+// specific is known
+func ErrorCodeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	if status >= 500 {
+		return "internal"
+	}
+	return "error"
+}
+
+// WriteJSONError answers with apiErr in the error envelope. A missing
+// code or message is filled in from status.
+func WriteJSONError(w http.ResponseWriter, status int, apiErr APIError) {
+	if apiErr.Code == "" {
+		apiErr.Code = ErrorCodeFor(status)
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]APIError{"error": apiErr})
+}
+
+// JSONError answers with an error envelope holding just message, which
+// may be empty to use the status text
+func JSONError(w http.ResponseWriter, status int, message string) {
+	WriteJSONError(w, status, APIError{Message: message})
+}
@@ -2,45 +2,128 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
+
+	"forum/server/config"
 )
 
+// Level is the severity of a log message
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ParseLevel converts a level name (debug, info, warn, error) to a Level,
+// defaulting to info for unknown values
+func ParseLevel(name string) Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
 // Logger provides structured logging
 type Logger struct {
-	logger *log.Logger
+	logger   *log.Logger
+	minLevel Level
+	closer   io.Closer
 }
 
-// NewLogger creates a new logger instance
-func NewLogger() *Logger {
+// NewLogger creates a new logger instance writing to out that drops
+// messages below level
+func NewLogger(out io.Writer, level string) *Logger {
 	return &Logger{
-		logger: log.New(os.Stdout, "", log.LstdFlags),
+		logger:   log.New(out, "", log.LstdFlags),
+		minLevel: ParseLevel(level),
+	}
+}
+
+// NewLoggerFromConfig creates a logger writing to stdout, a rotating file,
+// or both, as selected by cfg.Output
+func NewLoggerFromConfig(cfg config.LogConfig) (*Logger, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return NewLogger(os.Stdout, cfg.Level), nil
+	case "file", "both":
+		file, err := NewRotatingFile(config.BasePath+cfg.FilePath, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		var out io.Writer = file
+		if cfg.Output == "both" {
+			out = io.MultiWriter(os.Stdout, file)
+		}
+		logger := NewLogger(out, cfg.Level)
+		logger.closer = file
+		return logger, nil
+	default:
+		return nil, fmt.Errorf("invalid log output %q (expected stdout, file or both)", cfg.Output)
+	}
+}
+
+// Close releases the log file, if the logger writes to one
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
 	}
+	return l.closer.Close()
 }
 
 // Info logs informational messages
 func (l *Logger) Info(msg string, fields ...interface{}) {
-	l.log("INFO", msg, fields...)
+	l.log(LevelInfo, msg, fields...)
 }
 
 // Error logs error messages
 func (l *Logger) Error(msg string, fields ...interface{}) {
-	l.log("ERROR", msg, fields...)
+	l.log(LevelError, msg, fields...)
 }
 
 // Warn logs warning messages
 func (l *Logger) Warn(msg string, fields ...interface{}) {
-	l.log("WARN", msg, fields...)
+	l.log(LevelWarn, msg, fields...)
 }
 
 // Debug logs debug messages
 func (l *Logger) Debug(msg string, fields ...interface{}) {
-	l.log("DEBUG", msg, fields...)
+	l.log(LevelDebug, msg, fields...)
+}
+
+// String returns the label printed for the level
+func (lv Level) String() string {
+	switch lv {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
 }
 
 // log formats and outputs the log message with structured fields
-func (l *Logger) log(level, msg string, fields ...interface{}) {
+func (l *Logger) log(level Level, msg string, fields ...interface{}) {
+	// Skip messages below the configured minimum level
+	if level < l.minLevel {
+		return
+	}
+
 	timestamp := time.Now().Format("2006/01/02 15:04:05")
 	output := fmt.Sprintf("[%s] %s: %s", level, timestamp, msg)
 	
@@ -57,8 +140,9 @@ func (l *Logger) log(level, msg string, fields ...interface{}) {
 }
 
 // HTTPLog logs HTTP request/response information
-func (l *Logger) HTTPLog(method, path, ip string, statusCode int, duration time.Duration) {
+func (l *Logger) HTTPLog(requestID, method, path, ip string, statusCode int, duration time.Duration) {
 	l.Info("HTTP Request",
+		"request_id", requestID,
 		"method", method,
 		"path", path,
 		"ip", ip,
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoggerDropsMessagesBelowLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(&out, "info")
+
+	logger.Debug("debug message")
+	if out.Len() != 0 {
+		t.Fatalf("Debug at level info wrote %q", out.String())
+	}
+
+	logger.Info("info message")
+	if !strings.Contains(out.String(), "info message") {
+		t.Errorf("Info at level info wrote %q", out.String())
+	}
+}
+
+func TestLoggerWarnLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewLogger(&out, "warn")
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Warn("warn message")
+	logger.Error("error message")
+
+	got := out.String()
+	if strings.Contains(got, "debug message") || strings.Contains(got, "info message") {
+		t.Errorf("level warn let through lower messages: %q", got)
+	}
+	if !strings.Contains(got, "warn message") || !strings.Contains(got, "error message") {
+		t.Errorf("level warn dropped warnings or errors: %q", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]Level{
+		"debug":   LevelDebug,
+		" WARN ":  LevelWarn,
+		"warning": LevelWarn,
+		"error":   LevelError,
+		"":        LevelInfo,
+		"verbose": LevelInfo,
+	}
+	for name, want := range tests {
+		if got := ParseLevel(name); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
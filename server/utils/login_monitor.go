@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoginMonitor logs failed and throttled sign-in attempts at warn level so
+// brute-force attacks show up in the logs. It keeps a per-IP count of
+// recent failures; the count is reset after a successful login or once
+// window has passed without a failure.
+type LoginMonitor struct {
+	logger    *Logger
+	window    time.Duration
+	mu        sync.Mutex
+	failures  map[string]*loginFailures
+	lastSweep time.Time
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// NewLoginMonitor creates a monitor logging to logger
+func NewLoginMonitor(logger *Logger, window time.Duration) *LoginMonitor {
+	return &LoginMonitor{
+		logger:    logger,
+		window:    window,
+		failures:  make(map[string]*loginFailures),
+		lastSweep: time.Now(),
+	}
+}
+
+// Failed records a rejected login from ip. The password is never passed in
+// and the username is quoted so it cannot forge extra log fields.
+func (m *LoginMonitor) Failed(ip, username, reason string) {
+	m.logger.Warn("Failed login",
+		"ip", ip,
+		"username", strconv.Quote(username),
+		"reason", reason,
+		"failures", m.record(ip),
+	)
+}
+
+// Throttled records a login request from ip rejected by the rate limiter
+func (m *LoginMonitor) Throttled(ip, username, path string) {
+	m.logger.Warn("Login rate limited",
+		"ip", ip,
+		"username", strconv.Quote(username),
+		"path", path,
+		"failures", m.record(ip),
+	)
+}
+
+// Succeeded clears the failure count for ip
+func (m *LoginMonitor) Succeeded(ip string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.failures, ip)
+}
+
+// record bumps the failure count for ip and returns the new value
+func (m *LoginMonitor) record(ip string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	// Drop stale entries now and then so the map cannot grow without bound
+	if now.Sub(m.lastSweep) > m.window {
+		for key, f := range m.failures {
+			if now.Sub(f.last) > m.window {
+				delete(m.failures, key)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	f, ok := m.failures[ip]
+	if !ok || now.Sub(f.last) > m.window {
+		f = &loginFailures{}
+		m.failures[ip] = f
+	}
+	f.count++
+	f.last = now
+	return f.count
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// RenderMarkdown converts a small Markdown subset to HTML: paragraphs,
+// headings, block quotes, lists, fenced code, **bold**, *italic*, `code`
+// and [links](url). All text is escaped and the result is passed through
+// SanitizeHTML, so the output is safe to write into a page unescaped.
+//
+// Content may arrive already HTML-escaped by the Sanitize middleware, so it
+// is unescaped first to make sure every character is escaped exactly once.
+func RenderMarkdown(text string) string {
+	text = strings.ReplaceAll(html.UnescapeString(text), "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var out strings.Builder
+	var paragraph []string
+	var list string // "ul", "ol" or "" when not inside a list
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + strings.Join(paragraph, "<br>") + "</p>")
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			out.WriteString("</" + list + ">")
+			list = ""
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+
+		case strings.HasPrefix(trimmed, "```"):
+			flushParagraph()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			out.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>")
+
+		case headingPattern.MatchString(trimmed):
+			flushParagraph()
+			closeList()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			tag := "h" + string(rune('0'+len(m[1])))
+			out.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flushParagraph()
+			closeList()
+			out.WriteString("<blockquote>" + renderInline(strings.TrimSpace(trimmed[1:])) + "</blockquote>")
+
+		case unorderedItemPattern.MatchString(trimmed), orderedItemPattern.MatchString(trimmed):
+			flushParagraph()
+			kind, item := "ol", orderedItemPattern.FindStringSubmatch(trimmed)
+			if item == nil {
+				kind, item = "ul", unorderedItemPattern.FindStringSubmatch(trimmed)
+			}
+			if list != kind {
+				closeList()
+				out.WriteString("<" + kind + ">")
+				list = kind
+			}
+			out.WriteString("<li>" + renderInline(item[1]) + "</li>")
+
+		default:
+			closeList()
+			paragraph = append(paragraph, renderInline(trimmed))
+		}
+	}
+	flushParagraph()
+	closeList()
+
+	return SanitizeHTML(out.String())
+}
+
+var (
+	headingPattern       = regexp.MustCompile(`^(#{1,6})\s+(.+)$`)
+	unorderedItemPattern = regexp.MustCompile(`^[-*+]\s+(.+)$`)
+	orderedItemPattern   = regexp.MustCompile(`^\d+[.)]\s+(.+)$`)
+
+	codeSpanPattern = regexp.MustCompile("`([^`]+)`")
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicPattern   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// renderInline escapes raw text and applies inline formatting. Code spans
+// are cut out first so their content is shown literally.
+func renderInline(raw string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range codeSpanPattern.FindAllStringSubmatchIndex(raw, -1) {
+		out.WriteString(formatInline(raw[last:m[0]]))
+		out.WriteString("<code>" + html.EscapeString(raw[m[2]:m[3]]) + "</code>")
+		last = m[1]
+	}
+	out.WriteString(formatInline(raw[last:]))
+	return out.String()
+}
+
+func formatInline(raw string) string {
+	text := html.EscapeString(raw)
+	text = boldPattern.ReplaceAllString(text, "<strong>$1</strong>")
+	text = italicPattern.ReplaceAllString(text, "<em>$1</em>")
+	return linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := linkPattern.FindStringSubmatch(link)
+		href := html.UnescapeString(m[2])
+		if !safeURL(href) {
+			return m[1]
+		}
+		return `<a href="` + html.EscapeString(href) + `">` + m[1] + `</a>`
+	})
+}
+
+// allowedTags lists the only elements SanitizeHTML lets through
+var allowedTags = map[string]bool{
+	"p": true, "br": true, "strong": true, "em": true, "code": true, "pre": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "a": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)([^<>]*)>`)
+	hrefPattern = regexp.MustCompile(`(?i)\bhref\s*=\s*"([^"]*)"`)
+)
+
+// SanitizeHTML keeps only allowlisted tags, rebuilt without attributes
+// (links keep a vetted href), and escapes every other '<' and '>'.
+func SanitizeHTML(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			m := tagPattern.FindStringSubmatch(s[i:])
+			if m == nil || !allowedTags[strings.ToLower(m[2])] {
+				out.WriteString("&lt;")
+				i++
+				continue
+			}
+			out.WriteString(cleanTag(m[1] == "/", strings.ToLower(m[2]), m[3]))
+			i += len(m[0])
+		case '>':
+			out.WriteString("&gt;")
+			i++
+		default:
+			out.WriteByte(s[i])
+			i++
+		}
+	}
+	return out.String()
+}
+
+func cleanTag(closing bool, name, attrs string) string {
+	if closing {
+		return "</" + name + ">"
+	}
+	if name != "a" {
+		return "<" + name + ">"
+	}
+
+	href := ""
+	if m := hrefPattern.FindStringSubmatch(attrs); m != nil && safeURL(html.UnescapeString(m[1])) {
+		href = html.EscapeString(html.UnescapeString(m[1]))
+	}
+	return `<a href="` + href + `" rel="nofollow noopener" target="_blank">`
+}
+
+// safeURL allows web and mail links and site-relative paths only, which
+// rules out javascript:, data: and similar schemes.
+func safeURL(raw string) bool {
+	u := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw))
+	if strings.HasPrefix(u, "/") || strings.HasPrefix(u, "#") {
+		return !strings.HasPrefix(u, "//")
+	}
+	return strings.HasPrefix(u, "http://") ||
+		strings.HasPrefix(u, "https://") ||
+		strings.HasPrefix(u, "mailto:")
+}
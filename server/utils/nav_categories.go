@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"forum/server/models"
+)
+
+// navCategoryCache holds the category sidebar every page renders, so a
+// page view does not query the categories and their post counts each time
+type navCategoryCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	list      []models.Category
+	expiresAt time.Time
+}
+
+// navCategories is reused for 30 seconds until SetNavCategoriesTTL says
+// otherwise
+var navCategories = &navCategoryCache{ttl: 30 * time.Second}
+
+// SetNavCategoriesTTL sets how long the sidebar categories are reused
+// (config CacheConfig.CountTTL, since the sidebar shows post counts). A
+// ttl of 0 or less queries them on every render.
+func SetNavCategoriesTTL(ttl time.Duration) {
+	navCategories.mu.Lock()
+	defer navCategories.mu.Unlock()
+
+	navCategories.ttl = ttl
+	navCategories.list = nil
+	navCategories.expiresAt = time.Time{}
+}
+
+// InvalidateNavCategories drops the cached sidebar, so the next render
+// shows a category change at once
+func InvalidateNavCategories() {
+	navCategories.mu.Lock()
+	defer navCategories.mu.Unlock()
+
+	navCategories.list = nil
+	navCategories.expiresAt = time.Time{}
+}
+
+// fetchNavCategories returns the sidebar categories, from the cache while
+// it is fresh. The lock is held across the query, so when the entry
+// expires one render refreshes it while the others wait for the result.
+func fetchNavCategories(db *sql.DB) ([]models.Category, error) {
+	navCategories.mu.Lock()
+	defer navCategories.mu.Unlock()
+
+	if navCategories.list != nil && time.Now().Before(navCategories.expiresAt) {
+		return navCategories.list, nil
+	}
+
+	categories, err := models.FetchCategories(db)
+	if err != nil {
+		return nil, err
+	}
+	if navCategories.ttl > 0 {
+		if categories == nil {
+			categories = []models.Category{} // cache "no categories" too
+		}
+		navCategories.list = categories
+		navCategories.expiresAt = time.Now().Add(navCategories.ttl)
+	}
+	return categories, nil
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify turns a label into a URL path segment: "Web Dev" -> "web-dev".
+// Letters and digits from any script are kept (lowercased), so non-ASCII
+// labels such as "Café" or "日本語" still get a readable slug; every other
+// run of characters becomes a single hyphen. The result may be empty when
+// the label has no letters or digits at all.
+func Slugify(label string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(label) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+		} else {
+			pendingHyphen = true
+		}
+	}
+	return b.String()
+}
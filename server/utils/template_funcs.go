@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// timestampLayouts are the formats timestamps reach the templates in:
+// the models package formats them in SQL, the queries package scans them.
+var timestampLayouts = []string{
+	"01/02/2006 03:04 PM",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// TemplateFuncs returns the helpers available in every template
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"timeago":    TimeAgo,
+		"formatDate": FormatDate,
+		"pluralize":  Pluralize,
+		"markdown":   RenderMarkdown,
+	}
+}
+
+// TimeAgo renders a timestamp relative to now, e.g. "3 hours ago".
+// Anything older than a month falls back to FormatDate.
+func TimeAgo(value any) string {
+	t, ok := toTime(value)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return Pluralize(int(elapsed/time.Minute), "minute") + " ago"
+	case elapsed < 24*time.Hour:
+		return Pluralize(int(elapsed/time.Hour), "hour") + " ago"
+	case elapsed < 30*24*time.Hour:
+		return Pluralize(int(elapsed/(24*time.Hour)), "day") + " ago"
+	default:
+		return FormatDate(t)
+	}
+}
+
+// FormatDate renders a timestamp as a short date, e.g. "Jan 2, 2006"
+func FormatDate(value any) string {
+	t, ok := toTime(value)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// Pluralize prefixes word with count, adding an "s" unless count is one
+func Pluralize(count int, word string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, word)
+	}
+	return fmt.Sprintf("%d %ss", count, word)
+}
+
+// toTime accepts a time.Time or one of the timestamp strings stored by
+// SQLite, which are always UTC.
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, !v.IsZero()
+	case string:
+		for _, layout := range timestampLayouts {
+			if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
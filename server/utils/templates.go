@@ -2,13 +2,15 @@ package utils
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"text/template"
 
-	"forum/server/config"
 	"forum/server/models"
 )
 
@@ -18,6 +20,19 @@ var (
 	cacheMutex    sync.RWMutex
 )
 
+// templatesDir holds the page templates; see SetTemplatesDir
+var templatesDir = "web/templates"
+
+// SetTemplatesDir makes the templates load from dir (config WebConfig.TemplatesDir).
+// Call it at startup, before PrewarmTemplates; cached templates are dropped.
+func SetTemplatesDir(dir string) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	templatesDir = dir
+	templateCache = make(map[string]*template.Template)
+}
+
 type GlobalData struct {
 	IsAuthenticated bool
 	Data            any
@@ -25,31 +40,59 @@ type GlobalData struct {
 	Categories      []models.Category
 }
 
+// Error is the data of the error page. Templates are rendered with
+// text/template, which does not escape anything, so error.html passes
+// Message and Details through the html function. Details must still be
+// treated as untrusted: it may carry user input, and any other template
+// showing it has to escape it the same way.
 type Error struct {
 	Code    int
 	Message string
 	Details string
 }
 
-// RenderError handles error responses
+// RenderError handles error responses: the themed error page for
+// browsers, or the JSON error envelope for clients that asked for JSON
 func RenderError(db *sql.DB, w http.ResponseWriter, r *http.Request, statusCode int, isauth bool, username string) {
 	typeError := Error{
 		Code:    statusCode,
 		Message: http.StatusText(statusCode),
 	}
+	if WantsJSON(r) {
+		WriteJSONError(w, statusCode, APIError{Message: typeError.Message})
+		return
+	}
 	if err := RenderTemplate(db, w, r, "error", statusCode, typeError, isauth, username); err != nil {
 		http.Error(w, "500 | Internal Server Error", http.StatusInternalServerError)
 		log.Println(err)
 	}
 }
 
+// MethodNotAllowed answers a request with the wrong method, listing the
+// accepted ones in the Allow header. Behind RequireAuth the page is
+// rendered for the logged-in user.
+func MethodNotAllowed(db *sql.DB, w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	user, ok := UserFromContext(r.Context())
+	RenderError(db, w, r, http.StatusMethodNotAllowed, ok, user.Username)
+}
+
+// WantsJSON reports whether r comes from a script or API client that
+// expects JSON rather than an HTML page
+func WantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		r.Header.Get("X-Requested-With") != ""
+}
+
 func ParseTemplates(tmpl string) (*template.Template, error) {
-	// Parse the template files
-	t, err := template.ParseFiles(
-		config.BasePath+"web/templates/partials/header.html",
-		config.BasePath+"web/templates/partials/footer.html",
-		config.BasePath+"web/templates/partials/navbar.html",
-		config.BasePath+"web/templates/"+tmpl+".html",
+	// Parse the template files; functions must be registered before parsing
+	t, err := template.New(tmpl + ".html").Funcs(TemplateFuncs()).ParseFiles(
+		filepath.Join(templatesDir, "partials", "header.html"),
+		filepath.Join(templatesDir, "partials", "footer.html"),
+		filepath.Join(templatesDir, "partials", "navbar.html"),
+		filepath.Join(templatesDir, "partials", "post-list.html"),
+		filepath.Join(templatesDir, "partials", "pagination.html"),
+		filepath.Join(templatesDir, tmpl+".html"),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing template files: %w", err)
@@ -57,6 +100,39 @@ func ParseTemplates(tmpl string) (*template.Template, error) {
 	return t, nil
 }
 
+// PrewarmTemplates parses every page template in the templates directory and fills the
+// template cache, so a broken template is reported at startup instead of on
+// the first request that needs it. All failures are returned together.
+func PrewarmTemplates() error {
+	pages, err := filepath.Glob(filepath.Join(templatesDir, "*.html"))
+	if err != nil {
+		return fmt.Errorf("error listing templates: %w", err)
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no templates found in %s", templatesDir)
+	}
+
+	var errs []error
+	parsed := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		tmpl := strings.TrimSuffix(filepath.Base(page), ".html")
+		t, err := ParseTemplates(tmpl)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tmpl, err))
+			continue
+		}
+		parsed[tmpl] = t
+	}
+
+	cacheMutex.Lock()
+	for tmpl, t := range parsed {
+		templateCache[tmpl] = t
+	}
+	cacheMutex.Unlock()
+
+	return errors.Join(errs...)
+}
+
 func RenderTemplate(db *sql.DB, w http.ResponseWriter, r *http.Request, tmpl string, statusCode int, data any, isauth bool, username string) error {
 	// Try to get cached template first
 	cacheMutex.RLock()
@@ -80,9 +156,14 @@ func RenderTemplate(db *sql.DB, w http.ResponseWriter, r *http.Request, tmpl str
 		cacheMutex.Unlock()
 	}
 	
-	categories, err := models.FetchCategories(db)
-	if err != nil {
-		categories = nil
+	// Handlers without a database (static files) render without the
+	// category sidebar
+	var categories []models.Category
+	var err error
+	if db != nil {
+		if categories, err = fetchNavCategories(db); err != nil {
+			categories = nil
+		}
 	}
 
 	globalData := GlobalData{
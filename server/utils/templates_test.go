@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorPageEscapesMessageAndDetails(t *testing.T) {
+	SetTemplatesDir("../../web/templates")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	err := RenderTemplate(nil, w, r, "error", http.StatusBadRequest, Error{
+		Code:    http.StatusBadRequest,
+		Message: `<img src=x onerror="alert(1)">`,
+		Details: `<script>alert(document.cookie)</script>`,
+	}, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := w.Body.String()
+	for _, raw := range []string{"<script>alert", "<img src=x"} {
+		if strings.Contains(body, raw) {
+			t.Errorf("error page contains unescaped %q", raw)
+		}
+	}
+	for _, escaped := range []string{"&lt;script&gt;alert(document.cookie)&lt;/script&gt;", "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"} {
+		if !strings.Contains(body, escaped) {
+			t.Errorf("error page does not contain %q", escaped)
+		}
+	}
+}
+
+func TestRenderErrorPage(t *testing.T) {
+	SetTemplatesDir("../../web/templates")
+
+	w := httptest.NewRecorder()
+	RenderError(nil, w, httptest.NewRequest(http.MethodGet, "/missing", nil), http.StatusNotFound, false, "")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>404</h1>") || !strings.Contains(body, http.StatusText(http.StatusNotFound)) {
+		t.Errorf("body does not show the 404 page: %s", body)
+	}
+	if strings.Contains(body, `class="error-details"`) {
+		t.Error("an error without details shows the details paragraph")
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// userKey is the context key under which the authenticated user is stored
+type userKey struct{}
+
+// CurrentUser is the authenticated user attached to a request by RequireAuth
+type CurrentUser struct {
+	ID        int
+	Username  string
+	ExpiresAt time.Time // when the session expires
+}
+
+// WithUser returns a copy of ctx carrying the given user
+func WithUser(ctx context.Context, user CurrentUser) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user stored in ctx and whether one was found
+func UserFromContext(ctx context.Context) (CurrentUser, bool) {
+	user, ok := ctx.Value(userKey{}).(CurrentUser)
+	return user, ok
+}
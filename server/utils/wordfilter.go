@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"forum/server/config"
+)
+
+// Word filter modes
+const (
+	FilterModeReject = "reject"
+	FilterModeMask   = "mask"
+)
+
+// WordFilter finds banned words in user content. Matching is
+// case-insensitive, works on whole words only (so "class" does not match
+// "ass"), and also catches words spelled out with separators in between,
+// such as "b a d" or "b.a.d".
+type WordFilter struct {
+	words map[string]bool
+	mode  string
+}
+
+// NewWordFilter creates a filter for the given words and mode
+func NewWordFilter(words []string, mode string) (*WordFilter, error) {
+	if mode == "" {
+		mode = FilterModeReject
+	}
+	if mode != FilterModeReject && mode != FilterModeMask {
+		return nil, fmt.Errorf("invalid word filter mode %q (expected %s or %s)", mode, FilterModeReject, FilterModeMask)
+	}
+
+	f := &WordFilter{
+		words: make(map[string]bool),
+		mode:  mode,
+	}
+	for _, word := range words {
+		if normalized := normalizeWord(word); normalized != "" {
+			f.words[normalized] = true
+		}
+	}
+	return f, nil
+}
+
+// LoadWordFilter builds a filter from the comma separated list in config
+// plus, if set, a word list file with one word per line ("#" starts a comment)
+func LoadWordFilter(cfg config.ModerationConfig) (*WordFilter, error) {
+	words := strings.Split(cfg.BannedWords, ",")
+
+	if cfg.BannedWordsFile != "" {
+		file, err := os.Open(config.BasePath + cfg.BannedWordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open banned words file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			words = append(words, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read banned words file: %w", err)
+		}
+	}
+
+	return NewWordFilter(words, cfg.FilterMode)
+}
+
+// Mode returns the filter mode (reject or mask)
+func (f *WordFilter) Mode() string {
+	return f.mode
+}
+
+// Apply checks text for banned words. It returns the text to store (masked
+// with asterisks in mask mode, unchanged otherwise) and whether any banned
+// word was found.
+func (f *WordFilter) Apply(text string) (string, bool) {
+	if f == nil || len(f.words) == 0 {
+		return text, false
+	}
+
+	runes := []rune(text)
+	matches := f.find(runes)
+	if len(matches) == 0 {
+		return text, false
+	}
+	if f.mode != FilterModeMask {
+		return text, true
+	}
+
+	for _, match := range matches {
+		for _, i := range match {
+			runes[i] = '*'
+		}
+	}
+	return string(runes), true
+}
+
+// find returns, for each banned word found, the rune indexes of its letters
+func (f *WordFilter) find(runes []rune) [][]int {
+	type token struct {
+		indexes []int
+	}
+
+	// Split into runs of letters/digits
+	var tokens []token
+	var current []int
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			current = append(current, i)
+			continue
+		}
+		if len(current) > 0 {
+			tokens = append(tokens, token{indexes: current})
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		tokens = append(tokens, token{indexes: current})
+	}
+
+	word := func(indexes []int) string {
+		var b strings.Builder
+		for _, i := range indexes {
+			b.WriteRune(unicode.ToLower(runes[i]))
+		}
+		return b.String()
+	}
+
+	var matches [][]int
+	for i := 0; i < len(tokens); i++ {
+		if f.words[word(tokens[i].indexes)] {
+			matches = append(matches, tokens[i].indexes)
+			continue
+		}
+
+		// Join consecutive single-character tokens ("b a d") and check every
+		// run starting here so a spelled-out word is caught
+		if len(tokens[i].indexes) != 1 {
+			continue
+		}
+		joined := append([]int(nil), tokens[i].indexes...)
+		for j := i + 1; j < len(tokens) && len(tokens[j].indexes) == 1; j++ {
+			joined = append(joined, tokens[j].indexes[0])
+			if f.words[word(joined)] {
+				matches = append(matches, append([]int(nil), joined...))
+				i = j
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// normalizeWord lowercases a word and drops everything but letters and digits
+func normalizeWord(word string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(word) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
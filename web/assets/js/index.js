@@ -18,6 +18,16 @@ function throttle(fn, delay) {
 
 const addcomment = throttle(addcomm, 5000)
 
+// errorMessage returns the message of a JSON error response
+// ({"error": {"code", "message", "fields"}}), or fallback without one
+function errorMessage(xhr, fallback) {
+    try {
+        return JSON.parse(xhr.responseText).error.message || fallback
+    } catch (e) {
+        return fallback
+    }
+}
+
 function postreaction(postId, reaction) {
     document.getElementById("errorlogin" + postId).innerText = ``
     const xhr = new XMLHttpRequest();
@@ -41,6 +51,11 @@ function postreaction(postId, reaction) {
                 setTimeout(() => {
                     document.getElementById("errorlogin" + postId).innerText = ``
                 }, 1000);
+            } else if (xhr.status === 404) {
+                document.getElementById("errorlogin" + postId).innerText = `This post is no longer available!`
+                setTimeout(() => {
+                    document.getElementById("errorlogin" + postId).innerText = ``
+                }, 1000);
             } else if (xhr.status === 500) {
                 document.getElementById("errorlogin" + postId).innerText = `Try again later!`
                 setTimeout(() => {
@@ -75,6 +90,11 @@ function commentreaction(commentid, reaction) {
                 setTimeout(() => {
                     document.getElementById("commenterrorlogin" + commentid).innerText = ``
                 }, 1000);
+            } else if (xhr.status === 404) {
+                document.getElementById("commenterrorlogin" + commentid).innerText = `This comment is no longer available!`
+                setTimeout(() => {
+                    document.getElementById("commenterrorlogin" + commentid).innerText = ``
+                }, 1000);
             } else if (xhr.status === 500) {
                 document.getElementById("commenterrorlogin" + commentid).innerText = `Try again later!`
                 setTimeout(() => {
@@ -87,40 +107,52 @@ function commentreaction(commentid, reaction) {
 }
 
 
-function addcomm(postId) {
-    const content = document.getElementById("comment-content");
-    const xhr = new XMLHttpRequest();
-    xhr.open("POST", "/post/addcommentREQ", true);
-    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
-    xhr.onreadystatechange = function () {
-        if (xhr.readyState === 4) {
-            if (xhr.status === 200) {
-                const response = JSON.parse(xhr.responseText);
-                const comment = document.createElement("div")
-                comment.innerHTML = `
+function renderComment(c) {
+    const comment = document.createElement("div")
+    comment.innerHTML = `
                  <div class="comment">
             <div class="comment-header">
-                <p class="comment-user">`+ response.username + `</p>
+                <p class="comment-user">`+ c.username + `</p>
                 <span></span>
-                <p class="comment-time">`+ response.created_at + ` </p>
+                <p class="comment-time">`+ c.created_at + ` </p>
             </div>
             <div class="comment-body">
-                <p class="comment-content">`+ response.content + ` </p>
+                <p class="comment-content">`+ c.content + ` </p>
             </div>
             <div class="comment-footer">
-                <button id="commentlikescount`+ response.ID + `" onclick="commentreaction('` + response.ID + `','like')"
-                    class="comment-like"><i class="fa-regular fa-thumbs-up"></i>`+ response.likes + `</button>
-                <button id="commentdislikescount`+ response.ID + `" onclick="commentreaction('` + response.ID + `','dislike')"
-                    class="comment-dislike"><i class="fa-regular fa-thumbs-down"></i>`+ response.dislikes + `</button>
+                <button id="commentlikescount`+ c.ID + `" onclick="commentreaction('` + c.ID + `','like')"
+                    class="comment-like"><i class="fa-regular fa-thumbs-up"></i>`+ c.likes + `</button>
+                <button id="commentdislikescount`+ c.ID + `" onclick="commentreaction('` + c.ID + `','dislike')"
+                    class="comment-dislike"><i class="fa-regular fa-thumbs-down"></i>`+ c.dislikes + `</button>
             </div>
-            <span style="color:red" id="commenterrorlogin`+ response.ID + `"></span>
+            <span style="color:red" id="commenterrorlogin`+ c.ID + `"></span>
         </div>
                 `
-                document.getElementsByClassName("comments")[0].prepend(comment)
+    return comment
+}
+
+function addcomm(postId) {
+    const content = document.getElementById("comment-content");
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/post/addcommentREQ", true);
+    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                const response = JSON.parse(xhr.responseText);
+                // The live update for this comment may have arrived first
+                if (!document.getElementById("commentlikescount" + response.ID)) {
+                    document.getElementsByClassName("comments")[0].prepend(renderComment(response))
+                }
                 document.getElementsByClassName("post-comments")[0].innerHTML = `<i class="fa-regular fa-comment"></i>` + response.commentscount
                 content.value = ""
+            } else if (xhr.status === 409 || xhr.status === 403) {
+                document.getElementById("errorlogin" + postId).innerText = errorMessage(xhr, `Cannot add comment now!`)
+                setTimeout(() => {
+                    document.getElementById("errorlogin" + postId).innerText = ``
+                }, 1000);
             } else if (xhr.status === 400) {
-                document.getElementById("errorlogin" + postId).innerText = `Invalid comment!`
+                document.getElementById("errorlogin" + postId).innerText = errorMessage(xhr, `Invalid comment!`)
                 setTimeout(() => {
                     document.getElementById("errorlogin" + postId).innerText = ``
                 }, 1000);
@@ -207,7 +239,7 @@ async function pagination(dir, data) {
 
 
 
-function CreatPost() {
+function CreatPost(draft) {
     const title = document.querySelector(".create-post-title")
     const content = document.querySelector(".content")
     const categories = document.querySelector(".selected-categories")
@@ -221,16 +253,23 @@ function CreatPost() {
         return;
     }
 
-    if (title.value.length > 100) {
-        logerror.innerText = 'Title is too long. Please keep it under 100 characters.';
-        setTimeout(() => {
-            logerror.innerText = '';
-        }, 3000);
-        return;
+    // The bounds come from the server config; it checks them again anyway
+    const tooShortOrLong = (field, name) => {
+        const length = Array.from(field.value.trim()).length
+        const min = Number(field.dataset.min) || 0
+        const max = Number(field.dataset.max) || 0
+        if (length < min) {
+            return `${name} is too short. Please use at least ${min} characters.`
+        }
+        if (max > 0 && length > max) {
+            return `${name} is too long. Please keep it under ${max} characters.`
+        }
+        return ''
     }
 
-    if (content.value.length > 3000) {
-        logerror.innerText = 'Content is too long. Please keep it under 3000 characters.';
+    const lengthError = tooShortOrLong(title, 'Title') || tooShortOrLong(content, 'Content')
+    if (lengthError) {
+        logerror.innerText = lengthError;
         setTimeout(() => {
             logerror.innerText = '';
         }, 3000);
@@ -249,18 +288,23 @@ function CreatPost() {
     xml.onreadystatechange = function () {
         if (xml.readyState === 4) {
             if (xml.status === 200) {
-                const btn = document.getElementById("create-post-btn")
                 document.getElementById("publish-post-icon").style.display = "none"
                 document.getElementById("publish-post-circle").style.display = "inline-block"
-                btn.disabled = true
-                btn.style.background = "grey"
-                btn.style.cursor = "not-allowed"
-
+                for (const id of ["create-post-btn", "save-draft-btn"]) {
+                    const btn = document.getElementById(id)
+                    btn.disabled = true
+                    btn.style.background = "grey"
+                    btn.style.cursor = "not-allowed"
+                }
 
-                logerror.innerText = 'Post created successfully, redirect to home page in 2s ...'
+                // drafts are only visible to their author, so send them to their posts
+                const target = draft ? '/mycreatedposts' : '/'
+                logerror.innerText = draft
+                    ? 'Draft saved, redirect to your posts in 2s ...'
+                    : 'Post created successfully, redirect to home page in 2s ...'
                 logerror.style.color = "green"
                 setTimeout(() => {
-                    window.location.href = '/'
+                    window.location.href = target
                 }, 2000)
 
             } else if (xml.status === 401) {
@@ -270,7 +314,9 @@ function CreatPost() {
                 }, 2000)
 
             } else {
-                logerror.innerText = 'Error: check your entries and try again!'
+                logerror.innerText = xml.status === 400 && xml.responseText
+                    ? 'Error: ' + xml.responseText
+                    : 'Error: check your entries and try again!'
                 setTimeout(() => {
                     logerror.innerText = ''
                 }, 1500)
@@ -279,9 +325,202 @@ function CreatPost() {
     }
 
     // Get form data
-    xml.send(`title=${encodeURIComponent(title.value)}&content=${encodeURIComponent(content.value)}&categories=${cateris}`)
+    xml.send(`title=${encodeURIComponent(title.value)}&content=${encodeURIComponent(content.value)}&categories=${cateris}&draft=${draft === true}`)
+}
+
+// previewPost shows the post as it will be rendered, without saving it
+function previewPost() {
+    const title = document.querySelector(".create-post-title")
+    const content = document.querySelector(".content")
+    const logerror = document.querySelector(".errorarea")
+    const preview = document.querySelector(".post-preview")
+
+    const xml = new XMLHttpRequest();
+    xml.open("POST", "/post/preview", true)
+    xml.setRequestHeader("Content-Type", "application/x-www-form-urlencoded")
+
+    xml.onreadystatechange = function () {
+        if (xml.readyState !== 4) {
+            return
+        }
+        if (xml.status === 200) {
+            const data = JSON.parse(xml.responseText)
+            // the title is plain text; the content is HTML sanitized by the server
+            preview.querySelector(".post-preview-title").textContent = data.title
+            preview.querySelector(".post-preview-content").innerHTML = data.html
+            preview.style.display = "block"
+            return
+        }
+        preview.style.display = "none"
+        if (xml.status === 401) {
+            logerror.innerText = 'You are loged out, redirect to login page in 2s...'
+            setTimeout(() => {
+                window.location.href = '/login'
+            }, 2000)
+            return
+        }
+        logerror.innerText = xml.status === 400
+            ? 'Error: ' + errorMessage(xml, 'check your entries and try again!')
+            : 'Error: could not preview the post, try again later!'
+        setTimeout(() => {
+            logerror.innerText = ''
+        }, 3000)
+    }
+
+    xml.send(`title=${encodeURIComponent(title.value)}&content=${encodeURIComponent(content.value)}`)
+}
+
+function publishPost(postId) {
+    const logerror = document.getElementById("errorlogin" + postId)
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/post/publish", true);
+    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                window.location.reload()
+            } else if (xhr.status === 401) {
+                logerror.innerText = `You must login first!`
+            } else {
+                logerror.innerText = `Could not publish the draft, try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 1500);
+        }
+    };
+    xhr.send(`postid=${postId}`);
 }
 
+// setPostLock closes a post to new comments (locked = true) or reopens it
+function setPostLock(postId, locked) {
+    const logerror = document.getElementById("errorlogin" + postId)
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", locked ? "/post/lock" : "/post/unlock", true);
+    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                window.location.reload()
+            } else if (xhr.status === 401) {
+                logerror.innerText = `You must login first!`
+            } else {
+                logerror.innerText = `Could not change the comment lock, try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 1500);
+        }
+    };
+    xhr.send(`postid=${postId}`);
+}
+
+function markNotificationRead(notificationId) {
+    const logerror = document.getElementById("notificationerror" + notificationId)
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/notifications/read", true);
+    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200 || xhr.status === 404) {
+                document.getElementById("notification" + notificationId).remove()
+                return
+            } else if (xhr.status === 401) {
+                logerror.innerText = `You must login first!`
+            } else {
+                logerror.innerText = `Try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 1500);
+        }
+    };
+    xhr.send(`id=${notificationId}`);
+}
+
+
+function uploadPostImage(postId) {
+    const input = document.getElementById("post-image" + postId)
+    const logerror = document.getElementById("errorlogin" + postId)
+    if (!input.files.length) return
+    const form = new FormData()
+    form.append("post_id", postId)
+    form.append("image", input.files[0])
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/post/upload", true);
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                window.location.reload()
+                return
+            } else if (xhr.status === 401) {
+                logerror.innerText = `You must login first!`
+            } else if (xhr.status === 400 || xhr.status === 413 || xhr.status === 415) {
+                logerror.innerText = errorMessage(xhr, `Invalid image!`)
+            } else {
+                logerror.innerText = `Could not upload the image, try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 2000);
+        }
+    };
+    xhr.send(form);
+}
+
+function uploadAvatar() {
+    const input = document.getElementById("avatar-file")
+    const logerror = document.getElementById("avatar-error")
+    if (!input.files.length) return
+    const form = new FormData()
+    form.append("avatar", input.files[0])
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/user/avatar", true);
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                window.location.reload()
+                return
+            } else if (xhr.status === 401) {
+                logerror.innerText = `You must login first!`
+            } else if (xhr.status === 400 || xhr.status === 413 || xhr.status === 415) {
+                logerror.innerText = errorMessage(xhr, `Invalid image!`)
+            } else {
+                logerror.innerText = `Could not upload the avatar, try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 2000);
+        }
+    };
+    xhr.send(form);
+}
+
+function deleteAccount(username) {
+    const password = document.getElementById("delete-password")
+    const logerror = document.getElementById("delete-error")
+    const confirm = prompt(`This deletes your account for good. Type your username (${username}) to confirm:`)
+    if (confirm === null) return
+    const xhr = new XMLHttpRequest();
+    xhr.open("POST", "/myaccount/delete", true);
+    xhr.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
+    xhr.onreadystatechange = function () {
+        if (xhr.readyState === 4) {
+            if (xhr.status === 200) {
+                window.location.href = "/"
+                return
+            } else if (xhr.status === 400 || xhr.status === 401) {
+                logerror.innerText = errorMessage(xhr, `You must login first!`)
+            } else {
+                logerror.innerText = `Could not delete the account, try again later!`
+            }
+            setTimeout(() => {
+                logerror.innerText = ``
+            }, 2000);
+        }
+    };
+    xhr.send(`password=${encodeURIComponent(password.value)}&confirm=${encodeURIComponent(confirm)}`);
+}
 
 function register() {
     const email = document.querySelector("#email")
@@ -311,13 +550,15 @@ function register() {
                 }, 2000)
 
             } else if (xml.status === 400) {
-                logerror.innerText = 'Error: verify your data and try again!'
+                logerror.innerText = xml.responseText
+                    ? 'Error: ' + xml.responseText
+                    : 'Error: verify your data and try again!'
                 logerror.style.color = "red"
                 setTimeout(() => {
                     logerror.innerText = ''
-                }, 1500)
-            } else if (xml.status === 304) {
-                logerror.innerText = 'User already exists!'
+                }, 3000)
+            } else if (xml.status === 409) {
+                logerror.innerText = 'Error: ' + xml.responseText
                 logerror.style.color = "red"
                 setTimeout(() => {
                     logerror.innerText = ''
@@ -343,6 +584,7 @@ function register() {
 function login() {
     const username = document.querySelector("#username")
     const password = document.querySelector("#password")
+    const remember = document.querySelector("#remember")
 
     const xml = new XMLHttpRequest();
     xml.open("POST", "/signin", true)
@@ -371,12 +613,6 @@ function login() {
                 setTimeout(() => {
                     logerror.innerText = ''
                 }, 1500)
-            } else if (xml.status === 404) {
-                logerror.innerText = 'User not found!'
-                logerror.style.color = "red"
-                setTimeout(() => {
-                    logerror.innerText = ''
-                }, 1500)
             } else if (xml.status === 401) {
                 logerror.innerText = 'Invalid username or password!'
                 logerror.style.color = "red"
@@ -394,7 +630,7 @@ function login() {
     }
 
     // Get form data
-    xml.send(`username=${encodeURIComponent(username.value)}&password=${encodeURIComponent(password.value)}`)
+    xml.send(`username=${encodeURIComponent(username.value)}&password=${encodeURIComponent(password.value)}&remember=${remember.checked}`)
 }
 
 const displayMobileNav = (e) => {
@@ -427,4 +663,46 @@ const closeMobileNav = (e) => {
 //             element.textContent = formatTime(time);
 //         }
 //     });
-// });
\ No newline at end of file
+// });
+
+// Live updates on the post page: new comments and reaction counts from
+// other readers arrive over a WebSocket
+function setReactionCounts(prefix, id, likes, dislikes) {
+    const like = document.getElementById(prefix + "likescount" + id)
+    const dislike = document.getElementById(prefix + "dislikescount" + id)
+    if (like && dislike) {
+        like.innerHTML = `<i class="fa-regular fa-thumbs-up"></i>${likes}`
+        dislike.innerHTML = `<i class="fa-regular fa-thumbs-down"></i>${dislikes}`
+    }
+}
+
+function connectLiveUpdates(postId) {
+    const scheme = location.protocol === "https:" ? "wss" : "ws"
+    const socket = new WebSocket(`${scheme}://${location.host}/ws/post/${postId}`)
+    socket.onmessage = (message) => {
+        const event = JSON.parse(message.data)
+        const data = event.data
+        if (event.type === "comment") {
+            // Our own comments were already added by addcomm
+            if (document.getElementById("commentlikescount" + data.id)) return
+            document.getElementsByClassName("comments")[0].prepend(renderComment({
+                ID: data.id,
+                username: data.author_username,
+                created_at: data.created_at,
+                content: data.content,
+                likes: 0,
+                dislikes: 0,
+            }))
+            document.getElementsByClassName("post-comments")[0].innerHTML = `<i class="fa-regular fa-comment"></i>` + data.comment_count
+        } else if (event.type === "post_reaction") {
+            setReactionCounts("", data.id, data.like_count, data.dislike_count)
+        } else if (event.type === "comment_reaction") {
+            setReactionCounts("comment", data.id, data.like_count, data.dislike_count)
+        }
+    }
+}
+
+const livePost = document.querySelector(".post-detail[data-post-id]")
+if (livePost && "WebSocket" in window) {
+    connectLiveUpdates(livePost.dataset.postId)
+}